@@ -37,6 +37,22 @@ type IPBlockSpec struct {
 	Unblock  bool     `json:"unblock,omitempty"`  // 用户显式解封
 	Trigger  bool     `json:"trigger,omitempty"`  // 用户显式请求重新封禁
 
+	// 处置动作：block、ratelimit、tarpit、log-only，为空时使用引擎的默认动作
+	// （iptables 引擎为 ratelimit，其余为 block）
+	// +kubebuilder:validation:Enum=block;ratelimit;tarpit;log-only
+	Action string `json:"action,omitempty"`
+	// action 为 ratelimit 时的限速参数，只需设置引擎支持的一种单位
+	RateLimit *RateLimitSpec `json:"rateLimit,omitempty"`
+}
+
+// RateLimitSpec 限速参数
+type RateLimitSpec struct {
+	// +kubebuilder:validation:Minimum=1
+	PPS int64 `json:"pps,omitempty"` // 每秒包数
+	// +kubebuilder:validation:Minimum=1
+	BPS int64 `json:"bps,omitempty"` // 每秒字节数
+	// +kubebuilder:validation:Minimum=1
+	RPS int64 `json:"rps,omitempty"` // 每秒请求数
 }
 
 // IPBlockStatus defines the observed state of IPBlock.
@@ -51,6 +67,25 @@ type IPBlockStatus struct {
 	Message      string `json:"message,omitempty"`
 	LastSpecHash string `json:"lastSpecHash,omitempty"`
 	BanCount     int64  `json:"banCount,omitempty"`
+	// 最近一次成功封禁时的处置动作，为空表示引擎的默认动作；解封时据此判断是否需要调用引擎
+	Action string `json:"action,omitempty"`
+	// 组合引擎下每个后端的处理结果
+	Backends []BackendStatus `json:"backends,omitempty"`
+	// 状态条件，目前只有 BackendReachable
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ConditionBackendReachable 封禁引擎的后端是否可达，不可达期间的封禁会排队等待
+const ConditionBackendReachable = "BackendReachable"
+
+// BackendStatus 组合引擎中单个后端的处理结果
+type BackendStatus struct {
+	Engine  string `json:"engine"`
+	Result  string `json:"result"` // success, failed
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
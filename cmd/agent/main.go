@@ -0,0 +1,113 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// agent 运行在网关上的封禁后端，提供 xdp、iptables 引擎调用的 HTTP 接口
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/agent"
+)
+
+func main() {
+	var listen, backendName, table, statePath string
+	var tokenFile, certFile, keyFile, clientCAFile string
+	var expireInterval time.Duration
+	flag.StringVar(&listen, "listen", ":9521", "HTTP 接口监听地址")
+	flag.StringVar(&backendName, "backend", agent.NftablesBackend, "封禁后端: nftables, ipset, memory")
+	flag.StringVar(&table, "table", "ipblock", "nftables 表名或 ipset 集合名前缀")
+	flag.StringVar(&statePath, "state", "/var/lib/ipblock-agent/state.json", "封禁表持久化文件，为空时不持久化")
+	flag.DurationVar(&expireInterval, "expire-interval", 5*time.Second, "检查封禁到期的间隔")
+	flag.StringVar(&tokenFile, "token-file", "", "Bearer Token 文件，设置后所有接口都要求认证")
+	flag.StringVar(&certFile, "tls-cert", "", "TLS 证书文件，与 -tls-key 同时设置时启用 HTTPS")
+	flag.StringVar(&keyFile, "tls-key", "", "TLS 私钥文件")
+	flag.StringVar(&clientCAFile, "client-ca", "", "校验客户端证书的 CA 文件，设置后要求双向 TLS")
+	flag.Parse()
+
+	var token string
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			log.Fatalf("读取 Token 失败: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if (certFile == "") != (keyFile == "") {
+		log.Fatalf("-tls-cert 与 -tls-key 需要同时设置")
+	}
+	if clientCAFile != "" && certFile == "" {
+		log.Fatalf("-client-ca 需要启用 TLS")
+	}
+
+	backend, err := agent.NewBackend(backendName, table)
+	if err != nil {
+		log.Fatalf("创建后端失败: %v", err)
+	}
+	defer backend.Close()
+
+	tbl, err := agent.NewTable(backend, statePath)
+	if err != nil {
+		log.Fatalf("初始化封禁表失败: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go tbl.Run(ctx, expireInterval)
+
+	srv := &http.Server{Addr: listen, Handler: agent.NewServer(tbl, token), ReadHeaderTimeout: 10 * time.Second}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			log.Fatalf("读取客户端 CA 失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Fatalf("客户端 CA 中没有有效的证书")
+		}
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("agent 启动，后端 %s，监听 %s", backendName, listen)
+	if certFile != "" {
+		err = srv.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("HTTP 服务异常退出: %v", err)
+		os.Exit(1)
+	}
+	// 退出时保留内核中的规则，重启后由持久化文件重新下发
+	log.Printf("agent 已退出")
+}
@@ -19,10 +19,12 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"github/Beatrueman/ipblock-operator/internal/config"
 	"github/Beatrueman/ipblock-operator/internal/engine"
-	"github/Beatrueman/ipblock-operator/internal/notify/lark"
+	"github/Beatrueman/ipblock-operator/internal/notify"
 	"github/Beatrueman/ipblock-operator/internal/trigger"
 	"github/Beatrueman/ipblock-operator/internal/utils"
 	"k8s.io/apimachinery/pkg/util/yaml"
@@ -30,13 +32,15 @@ import (
 	"os"
 	"path/filepath"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"strings"
 	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	// distroless 镜像中没有时区数据库，内置 tzdata 以支持通知时区配置
+	_ "time/tzdata"
+
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -70,35 +74,120 @@ type TriggerConfig struct {
 	Name string `yaml:"name"`
 	Addr string `yaml:"addr,omitempty"`
 	Path string `yaml:"path,omitempty"`
+
+	// 完整的配置项，由各触发器解析自己的字段
+	Raw json.RawMessage `yaml:"-" json:"-"`
 }
 
 // 解析 trigger 字符串为 YAML 列表
 func parseTriggers(yamlStr string) ([]TriggerConfig, error) {
-	var triggers []TriggerConfig
-	err := yaml.Unmarshal([]byte(yamlStr), &triggers)
+	var items []json.RawMessage
+	err := yaml.Unmarshal([]byte(yamlStr), &items)
 	if err != nil {
 		return nil, err
 	}
+	triggers := make([]TriggerConfig, 0, len(items))
+	for _, raw := range items {
+		var cfg TriggerConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Raw = raw
+		triggers = append(triggers, cfg)
+	}
 	return triggers, nil
 }
 
-// 选择触发器
-func CreateTriggerByConfig(cfg TriggerConfig, mgr ctrl.Manager) trigger.Trigger {
+// 选择触发器，所有触发器共享同一个 pipeline，同一 IP 经不同触发器上报时也会被防抖
+func CreateTriggerByConfig(cfg TriggerConfig, mgr ctrl.Manager, reconciler *controller.IPBlockReconciler, pipeline *trigger.Pipeline) (trigger.Trigger, error) {
 	switch cfg.Name {
 	case "grafana":
 		return &trigger.GrafanaTrigger{
-			Client: mgr.GetClient(),
-			Addr:   cfg.Addr,
-			Path:   cfg.Path,
-			// 1000 个 IP， 60 秒防抖
-			// LRU中最多保存1000个IP，达到1000个后会自动淘汰最近最少使用的IP
-			// 对于同一个IP，如果最近60s内发生过一次封禁，那么这60s内再次收到该IP的相同请求时，会被防抖识别为重复
-			Debouncer: utils.NewLRUDebouncer(1000, 60*time.Second),
-			IPLocker:  utils.NewIPLock(),
+			Addr:     cfg.Addr,
+			Path:     cfg.Path,
+			Pipeline: pipeline,
+		}, nil
+	case "prometheus":
+		var promCfg trigger.PrometheusConfig
+		if err := json.Unmarshal(cfg.Raw, &promCfg); err != nil {
+			return nil, err
+		}
+		return trigger.NewPrometheusTrigger(promCfg, pipeline)
+	case "loki":
+		var lokiCfg trigger.LokiConfig
+		if err := json.Unmarshal(cfg.Raw, &lokiCfg); err != nil {
+			return nil, err
+		}
+		return trigger.NewLokiTrigger(lokiCfg, pipeline)
+	case "feed":
+		var feedCfg trigger.FeedConfig
+		if err := json.Unmarshal(cfg.Raw, &feedCfg); err != nil {
+			return nil, err
+		}
+		t, err := trigger.NewFeedTrigger(feedCfg, pipeline)
+		if err != nil {
+			return nil, err
+		}
+		t.Whitelist = reconciler.CurrentWhitelist
+		return t, nil
+	case "crowdsec":
+		var csCfg trigger.CrowdSecConfig
+		if err := json.Unmarshal(cfg.Raw, &csCfg); err != nil {
+			return nil, err
+		}
+		if csCfg.APIKeySecret != nil {
+			key, err := config.ReadSecretKey(mgr.GetAPIReader(), *csCfg.APIKeySecret, reconciler.CmNamespace)
+			if err != nil {
+				return nil, fmt.Errorf("read crowdsec api key failed: %w", err)
+			}
+			csCfg.APIKey = key
+		}
+		return trigger.NewCrowdSecTrigger(csCfg, pipeline)
+	case "logtail":
+		var logCfg trigger.LogTailConfig
+		if err := json.Unmarshal(cfg.Raw, &logCfg); err != nil {
+			return nil, err
+		}
+		return trigger.NewLogTailTrigger(logCfg, pipeline)
+	case "suricata":
+		var suricataCfg trigger.SuricataConfig
+		if err := json.Unmarshal(cfg.Raw, &suricataCfg); err != nil {
+			return nil, err
 		}
+		return trigger.NewSuricataTrigger(suricataCfg, pipeline)
+	case "falco":
+		var falcoCfg trigger.FalcoConfig
+		if err := json.Unmarshal(cfg.Raw, &falcoCfg); err != nil {
+			return nil, err
+		}
+		return trigger.NewFalcoTrigger(falcoCfg, pipeline)
+	case "syslog":
+		var syslogCfg trigger.SyslogConfig
+		if err := json.Unmarshal(cfg.Raw, &syslogCfg); err != nil {
+			return nil, err
+		}
+		if tlsCfg := syslogCfg.TLS; tlsCfg != nil {
+			cert, key, err := config.ReadTLSSecret(mgr.GetAPIReader(), tlsCfg.CertSecret, reconciler.CmNamespace)
+			if err != nil {
+				return nil, fmt.Errorf("read syslog tls certificate failed: %w", err)
+			}
+			tlsCfg.Cert, tlsCfg.Key = cert, key
+			if tlsCfg.ClientCASecret != nil {
+				ref := *tlsCfg.ClientCASecret
+				if ref.Key == "" {
+					ref.Key = "ca.crt"
+				}
+				ca, err := config.ReadSecretKey(mgr.GetAPIReader(), ref, reconciler.CmNamespace)
+				if err != nil {
+					return nil, fmt.Errorf("read syslog client CA failed: %w", err)
+				}
+				tlsCfg.ClientCA = []byte(ca)
+			}
+		}
+		return trigger.NewSyslogTrigger(syslogCfg, pipeline)
 	// TODO 其他触发器 ...
 	default:
-		return nil
+		return nil, nil
 	}
 }
 
@@ -110,37 +199,51 @@ func watchConfigMap(ctx context.Context, mgr ctrl.Manager, reconciler *controlle
 			return
 		}
 
+		// 1000 个 IP， 60 秒防抖
+		// LRU中最多保存1000个IP，达到1000个后会自动淘汰最近最少使用的IP
+		// 对于同一个IP，如果最近60s内发生过一次封禁，那么这60s内再次收到该IP的相同请求时，会被防抖识别为重复
+		// pipeline 在重载触发器时保留，防抖状态不会因 ConfigMap 变更而清空
+		pipeline := &trigger.Pipeline{
+			Client:    mgr.GetClient(),
+			Debouncer: utils.NewLRUDebouncer(1000, 60*time.Second),
+			IPLocker:  utils.NewIPLock(),
+		}
+
 		// 加载通知中心
 		loadNotify := func(cm *corev1.ConfigMap) {
-			notifyType := cm.Data["notifyType"]
-			webhookURL := cm.Data["notifyWebhookURL"]
-
-			templates := make(map[string]string)
-			for k, v := range cm.Data {
-				if strings.HasPrefix(k, "notifyTemplate_") {
-					eventType := strings.TrimPrefix(k, "notifyTemplate_")
-					templates[eventType] = v
-				}
+			notifier, err := config.LoadNotifierFromConfigMap(cm)
+			if err != nil {
+				log.Log.Error(err, "Failed to load notify config, notifications disabled")
+				reconciler.NotifyQueue.SetNotifier(nil)
+				return
 			}
-
-			if notifyType == "lark" && webhookURL != "" && len(templates) > 0 {
-				larkNotify, err := lark.NewLarkNotify(webhookURL, templates)
-				if err != nil {
-					log.Log.Error(err, "Failed to create LarkNotify instance")
-					reconciler.Notifier = nil
-					return
-				}
-				reconciler.Notifier = larkNotify
-				log.Log.Info("LarkNotify has been initialized")
+			if notifier == nil {
+				reconciler.NotifyQueue.SetNotifier(nil)
+				log.Log.Info("No valid notify config found, notifications disabled")
 				return
 			}
 
-			//TODO 其他通知方式...
-
-			reconciler.Notifier = nil
+			reconciler.NotifyQueue.SetNotifier(notifier)
+			if router, ok := notifier.(*notify.Router); ok {
+				log.Log.Info("Notifier has been initialized", "channels", router.Channels())
+			}
+		}
 
-			log.Log.Info("notifyType: " + notifyType)
-			log.Log.Info("No valid notify config found, notifications disabled")
+		// 加载封禁引擎，配置有误时保留当前引擎
+		loadAdapter := func(cm *corev1.ConfigMap) {
+			name := cm.Data["engine"]
+			if name == "" {
+				return
+			}
+			adapter, err := config.LoadAdapterFromConfigMap(cm, reconciler.GatewayHost, mgr.GetClient(), mgr.GetAPIReader())
+			if err != nil {
+				log.Log.Error(err, "Failed to load engine config, keep current adapter", "name", name)
+				return
+			}
+			reconciler.UpdateAdapter(name, adapter)
+			log.Log.Info("Adapter has been loaded", "name", name)
+			// 引擎切换后立即探测新后端
+			reconciler.Health.Trigger()
 		}
 
 		// 加载触发中心
@@ -160,8 +263,10 @@ func watchConfigMap(ctx context.Context, mgr ctrl.Manager, reconciler *controlle
 			trigger.StopAll(ctx)
 
 			for _, cfg := range triggerConfigs {
-				t := CreateTriggerByConfig(cfg, mgr)
-				if t != nil {
+				t, err := CreateTriggerByConfig(cfg, mgr, reconciler, pipeline)
+				if err != nil {
+					log.Log.Error(err, "Invalid trigger config, skipping", "name", cfg.Name)
+				} else if t != nil {
 					trigger.Register(ctx, t)
 					log.Log.Info("Registered trigger", "name", cfg.Name)
 				} else {
@@ -181,15 +286,12 @@ func watchConfigMap(ctx context.Context, mgr ctrl.Manager, reconciler *controlle
 						log.Log.Info("ConfigMap has been created", "newGatewayHost", host)
 						reconciler.UpdateGatewayHost(host)
 					}
+					reconciler.UpdateClusterName(newCm.Data["clusterName"])
 					if wl := config.LoadWhitelistFromConfigMap(newCm); wl != nil {
 						reconciler.UpdateWhitelist(wl)
 						log.Log.Info("Whitelist has been initialized", "whitelist", wl.StringSlice())
 					}
-					if name := newCm.Data["engine"]; name != "" {
-						reconciler.AdapterName = name
-						reconciler.Adapter = engine.NewAdapter(name, reconciler.GatewayHost)
-						log.Log.Info("Adapter has been initialized", "name", name)
-					}
+					loadAdapter(newCm)
 					// 加载触发器
 					loadTriggers(newCm)
 					// 加载 Notify 相关配置
@@ -206,16 +308,13 @@ func watchConfigMap(ctx context.Context, mgr ctrl.Manager, reconciler *controlle
 						reconciler.UpdateGatewayHost(newHost)
 					}
 
+					reconciler.UpdateClusterName(newCm.Data["clusterName"])
 					if wl := config.LoadWhitelistFromConfigMap(newCm); wl != nil {
 						reconciler.UpdateWhitelist(wl)
 						log.Log.Info("whitelist has been updated", "whitelist", wl.StringSlice())
 					}
 
-					if name := newCm.Data["engine"]; name != "" {
-						reconciler.AdapterName = name
-						reconciler.Adapter = engine.NewAdapter(name, reconciler.GatewayHost)
-						log.Log.Info("Adapter has been updated", "name", name)
-					}
+					loadAdapter(newCm)
 					// 加载触发器
 					loadTriggers(newCm)
 					loadNotify(newCm)
@@ -234,6 +333,10 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var engineHealthInterval time.Duration
+	var engineBackendReadyz bool
+	var banBatchWindow time.Duration
+	var maxBatchSize, maxConcurrentReconciles int
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -252,6 +355,16 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.DurationVar(&engineHealthInterval, "engine-health-interval", 15*time.Second,
+		"The interval between health checks of the ban engine backend.")
+	flag.BoolVar(&engineBackendReadyz, "engine-backend-readyz", false,
+		"If set, readyz includes the engine-backend check and fails while the ban engine backend is unreachable. "+
+			"Trigger ports are removed from the Service while the pod is not ready.")
+	flag.DurationVar(&banBatchWindow, "ban-batch-window", 100*time.Millisecond,
+		"Bans and unbans arriving within this window are sent to the engine in one batch. Set to 0 to disable.")
+	flag.IntVar(&maxBatchSize, "max-batch-size", 200, "The maximum number of requests in one engine batch.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 16,
+		"The number of IPBlocks reconciled concurrently, which bounds how many bans can share a batch.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -380,6 +493,31 @@ func main() {
 		Recorder:    mgr.GetEventRecorderFor("ipblock-operator"),
 		CmName:      "ipblock-operator-config",
 		CmNamespace: "default",
+		NotifyQueue: notify.NewQueue(notify.QueueOptions{}),
+
+		BatchWindow:             banBatchWindow,
+		MaxBatchSize:            maxBatchSize,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+	}
+	reconciler.Health = controller.NewBackendHealth(func() engine.Adapter {
+		adapter, _ := reconciler.CurrentAdapter()
+		return adapter
+	}, engineHealthInterval)
+
+	// 通知队列随 Manager 启停，停止时排空未发送的通知
+	if err := mgr.Add(reconciler.NotifyQueue); err != nil {
+		setupLog.Error(err, "unable to add notify queue to manager")
+		os.Exit(1)
+	}
+	// 所有副本都探测后端，结果用于 ipblock_engine_backend_up 指标、IPBlock 的 BackendReachable 条件和可选的 readyz 检查
+	if err := mgr.Add(reconciler.Health); err != nil {
+		setupLog.Error(err, "unable to add engine health check to manager")
+		os.Exit(1)
+	}
+	// BGP speaker 只在 leader 上运行
+	if err := mgr.Add(config.BGPLeaderRunnable()); err != nil {
+		setupLog.Error(err, "unable to add bgp speaker to manager")
+		os.Exit(1)
 	}
 
 	ctx := context.Background()
@@ -399,12 +537,6 @@ func main() {
 	//// 3.启动 Trigger
 	//trigger.StartAll(ctx)
 
-	// 4.启动 Manager， 阻塞主线程
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
-		setupLog.Error(err, "problem running manager")
-		os.Exit(1)
-	}
-
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -431,6 +563,13 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	// 封禁后端不可达时报告未就绪；触发器与 Operator 同在一个 Pod，开启后后端故障期间 Service 会摘除触发器端口，默认关闭
+	if engineBackendReadyz {
+		if err := mgr.AddReadyzCheck("engine-backend", reconciler.Health.Check); err != nil {
+			setupLog.Error(err, "unable to set up engine backend ready check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -0,0 +1,54 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// reference-plugin 参考引擎插件，在内存中记录封禁，用于演示插件协议和运行一致性测试
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github/Beatrueman/ipblock-operator/internal/plugin"
+	"github/Beatrueman/ipblock-operator/internal/plugin/reference"
+)
+
+func main() {
+	var listen, tokenFile string
+	flag.StringVar(&listen, "listen", ":9000", "gRPC 监听地址")
+	flag.StringVar(&tokenFile, "token-file", "", "Bearer Token 文件，设置后所有调用都要求认证")
+	flag.Parse()
+
+	var token string
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			log.Fatalf("读取 Token 失败: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	lis, err := net.Listen("tcp", listen)
+	if err != nil {
+		log.Fatalf("监听 %s 失败: %v", listen, err)
+	}
+	log.Printf("参考插件启动，监听 %s", listen)
+	if err := plugin.NewServer(reference.NewServer(), token).Serve(lis); err != nil {
+		log.Fatalf("gRPC 服务异常退出: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// 后端名称，对应 agent 的 -backend 参数
+const (
+	NftablesBackend = "nftables"
+	IPSetBackend    = "ipset"
+	MemoryBackend   = "memory"
+)
+
+// Limit 限速参数，PPS、BPS 都为 0 时使用默认限速：每个来源每分钟最多 10 个新连接（可突发 20 个）
+type Limit struct {
+	PPS int64 `json:"pps,omitempty"` // 每秒包数，超出部分丢弃
+	BPS int64 `json:"bps,omitempty"` // 每秒字节数，超出部分丢弃
+}
+
+// Backend 在本机执行封禁和限速。到期由 Table 负责，后端只维护当前生效的集合
+type Backend interface {
+	// Init 创建所需的表、集合和规则，并清空上次运行遗留的条目，随后由 Table 重新下发
+	Init() error
+	Block(p netip.Prefix) error
+	Unblock(p netip.Prefix) error
+	Limit(p netip.Prefix, l Limit) error
+	Unlimit(p netip.Prefix) error
+	Close() error
+}
+
+// NewBackend 按名称创建后端
+func NewBackend(name, table string) (Backend, error) {
+	switch name {
+	case NftablesBackend:
+		return NewNftables(table), nil
+	case IPSetBackend:
+		return NewIPSet(table), nil
+	case MemoryBackend:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown backend '%s'", name)
+	}
+}
+
+// ParsePrefix 解析单个 IP 或 CIDR，单个 IP 视为 /32 或 /128，拒绝 /0
+func ParsePrefix(s string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		addr, addrErr := netip.ParseAddr(s)
+		if addrErr != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid IP or CIDR '%s'", s)
+		}
+		p = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	p = p.Masked()
+	if p.Bits() == 0 {
+		return netip.Prefix{}, fmt.Errorf("refusing to block '%s'", s)
+	}
+	return p, nil
+}
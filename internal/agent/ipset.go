@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// IPSet 把封禁写入 ipset 的 hash:net 集合，由 iptables / ip6tables 规则引用集合丢弃；
+// 限速使用 hashlimit 规则。所有规则放在独立的链中，从 INPUT 和 FORWARD 跳转
+type IPSet struct {
+	Name string // 集合名前缀，链名为其大写形式
+
+	// Run 执行命令，测试时可替换
+	Run func(name string, args ...string) error
+
+	mu      sync.Mutex
+	limited map[netip.Prefix]Limit
+}
+
+// NewIPSet 创建 ipset 后端，name 为空时使用 ipblock
+func NewIPSet(name string) *IPSet {
+	if name == "" {
+		name = "ipblock"
+	}
+	return &IPSet{Name: name, Run: runCommand, limited: make(map[netip.Prefix]Limit)}
+}
+
+func (s *IPSet) Init() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limited = make(map[netip.Prefix]Limit)
+	chain := s.chain()
+	for _, is6 := range []bool{false, true} {
+		set, family, iptables := s.family(is6)
+		if err := s.Run("ipset", "create", set, "hash:net", "family", family, "-exist"); err != nil {
+			return err
+		}
+		if err := s.Run("ipset", "flush", set); err != nil {
+			return err
+		}
+		// 链已存在时 -N 失败，随后清空即可
+		_ = s.Run(iptables, "-N", chain)
+		if err := s.Run(iptables, "-F", chain); err != nil {
+			return err
+		}
+		for _, hook := range []string{"INPUT", "FORWARD"} {
+			if s.Run(iptables, "-C", hook, "-j", chain) != nil {
+				if err := s.Run(iptables, "-I", hook, "-j", chain); err != nil {
+					return err
+				}
+			}
+		}
+		if err := s.Run(iptables, "-A", chain, "-m", "set", "--match-set", set, "src", "-j", "DROP"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *IPSet) Block(p netip.Prefix) error {
+	set, _, _ := s.family(p.Addr().Is6())
+	return s.Run("ipset", "add", set, p.String(), "-exist")
+}
+
+func (s *IPSet) Unblock(p netip.Prefix) error {
+	set, _, _ := s.family(p.Addr().Is6())
+	return s.Run("ipset", "del", set, p.String(), "-exist")
+}
+
+// Limit 参数变化时先删除旧规则
+func (s *IPSet) Limit(p netip.Prefix, l Limit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, _, iptables := s.family(p.Addr().Is6())
+	if old, ok := s.limited[p]; ok {
+		if old == l {
+			return nil
+		}
+		if err := s.Run(iptables, append([]string{"-D", s.chain()}, s.limitSpec(p, old)...)...); err != nil {
+			return err
+		}
+		delete(s.limited, p)
+	}
+	// 插入到集合匹配规则之前
+	if err := s.Run(iptables, append([]string{"-I", s.chain(), "1"}, s.limitSpec(p, l)...)...); err != nil {
+		return err
+	}
+	s.limited[p] = l
+	return nil
+}
+
+func (s *IPSet) Unlimit(p netip.Prefix) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.limited[p]
+	if !ok {
+		return nil
+	}
+	_, _, iptables := s.family(p.Addr().Is6())
+	if err := s.Run(iptables, append([]string{"-D", s.chain()}, s.limitSpec(p, old)...)...); err != nil {
+		return err
+	}
+	delete(s.limited, p)
+	return nil
+}
+
+func (s *IPSet) Close() error { return nil }
+
+func (s *IPSet) chain() string {
+	return strings.ToUpper(s.Name)
+}
+
+func (s *IPSet) family(is6 bool) (set, family, iptables string) {
+	if is6 {
+		return s.Name + "6", "inet6", "ip6tables"
+	}
+	return s.Name + "4", "inet", "iptables"
+}
+
+// 默认对新连接按每分钟 10 个（突发 20）限速，指定 pps / bps 时按包数或字节数限速
+func (s *IPSet) limitSpec(p netip.Prefix, l Limit) []string {
+	// hashlimit 名称最长 15 个字符
+	sum := sha1.Sum([]byte(p.String()))
+	name := "ipbl_" + hex.EncodeToString(sum[:4])
+
+	spec := []string{"-s", p.String()}
+	var above, burst string
+	switch {
+	case l.PPS > 0:
+		above = strconv.FormatInt(l.PPS, 10) + "/sec"
+	case l.BPS > 0:
+		above = strconv.FormatInt(l.BPS, 10) + "b/s"
+	default:
+		spec = append(spec, "-m", "conntrack", "--ctstate", "NEW")
+		above, burst = "10/min", "20"
+	}
+	spec = append(spec, "-m", "hashlimit", "--hashlimit-above", above)
+	if burst != "" {
+		spec = append(spec, "--hashlimit-burst", burst)
+	}
+	return append(spec, "--hashlimit-mode", "srcip", "--hashlimit-name", name, "-j", "DROP")
+}
+
+func runCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
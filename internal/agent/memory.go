@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"net/netip"
+	"sort"
+	"sync"
+)
+
+// Memory 只在内存中记录集合的后端，用于测试和演练
+type Memory struct {
+	mu      sync.Mutex
+	blocked map[netip.Prefix]struct{}
+	limited map[netip.Prefix]Limit
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		blocked: make(map[netip.Prefix]struct{}),
+		limited: make(map[netip.Prefix]Limit),
+	}
+}
+
+func (m *Memory) Init() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blocked = make(map[netip.Prefix]struct{})
+	m.limited = make(map[netip.Prefix]Limit)
+	return nil
+}
+
+func (m *Memory) Block(p netip.Prefix) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blocked[p] = struct{}{}
+	return nil
+}
+
+func (m *Memory) Unblock(p netip.Prefix) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blocked, p)
+	return nil
+}
+
+func (m *Memory) Limit(p netip.Prefix, l Limit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limited[p] = l
+	return nil
+}
+
+func (m *Memory) Unlimit(p netip.Prefix) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.limited, p)
+	return nil
+}
+
+func (m *Memory) Close() error { return nil }
+
+// Blocked 返回当前封禁的前缀，已排序
+func (m *Memory) Blocked() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]string, 0, len(m.blocked))
+	for p := range m.blocked {
+		list = append(list, p.String())
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Limited 返回当前限速的前缀及参数
+func (m *Memory) Limited() map[string]Limit {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	limited := make(map[string]Limit, len(m.limited))
+	for p, l := range m.limited {
+		limited[p.String()] = l
+	}
+	return limited
+}
@@ -0,0 +1,157 @@
+//go:build linux
+
+package agent
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// 最小化的 nfnetlink 客户端，只实现 nf_tables 批量事务所需的部分，
+// 常量取自 linux/netfilter/nfnetlink.h 与 nf_tables.h
+const (
+	nfnlSubsysNftables = 10
+	nfnlMsgBatchBegin  = 0x10
+	nfnlMsgBatchEnd    = 0x11
+
+	nlaFNested = 0x8000
+	nlmsgAlign = 4
+)
+
+// nlMsg 一条 nf_tables 消息
+type nlMsg struct {
+	typ    uint16 // NFT_MSG_*
+	flags  uint16 // 除 NLM_F_REQUEST、NLM_F_ACK 外的标志
+	family uint8
+	attrs  attrs
+}
+
+type nlConn struct {
+	fd  int
+	seq uint32
+}
+
+func dialNetfilter() (*nlConn, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_NETFILTER)
+	if err != nil {
+		return nil, fmt.Errorf("open netlink socket failed: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bind netlink socket failed: %w", err)
+	}
+	// 应答中不回带原始消息，避免大批量写入时应答溢出接收缓冲区
+	_ = unix.SetsockoptInt(fd, unix.SOL_NETLINK, unix.NETLINK_CAP_ACK, 1)
+	_ = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_SNDBUF, 4<<20)
+	tv := unix.NsecToTimeval((5 * time.Second).Nanoseconds())
+	_ = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+	return &nlConn{fd: fd, seq: uint32(time.Now().Unix())}, nil
+}
+
+func (c *nlConn) Close() error {
+	return unix.Close(c.fd)
+}
+
+// batch 在一个事务中提交所有消息，任一消息失败时内核回滚整个事务
+func (c *nlConn) batch(msgs []nlMsg) error {
+	var buf []byte
+	buf = c.appendMsg(buf, nfnlMsgBatchBegin, unix.NLM_F_REQUEST, unix.AF_UNSPEC, nfnlSubsysNftables, nil)
+	first := c.seq + 1
+	for _, m := range msgs {
+		typ := uint16(nfnlSubsysNftables<<8) | m.typ
+		buf = c.appendMsg(buf, typ, unix.NLM_F_REQUEST|unix.NLM_F_ACK|m.flags, m.family, 0, m.attrs)
+	}
+	buf = c.appendMsg(buf, nfnlMsgBatchEnd, unix.NLM_F_REQUEST, unix.AF_UNSPEC, nfnlSubsysNftables, nil)
+
+	if err := unix.Sendto(c.fd, buf, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("send netlink batch failed: %w", err)
+	}
+	return c.waitAcks(first, len(msgs))
+}
+
+func (c *nlConn) appendMsg(buf []byte, typ, flags uint16, family uint8, resID uint16, data []byte) []byte {
+	c.seq++
+	length := unix.NLMSG_HDRLEN + 4 + len(data)
+	hdr := make([]byte, unix.NLMSG_HDRLEN+4)
+	binary.NativeEndian.PutUint32(hdr[0:4], uint32(length))
+	binary.NativeEndian.PutUint16(hdr[4:6], typ)
+	binary.NativeEndian.PutUint16(hdr[6:8], flags)
+	binary.NativeEndian.PutUint32(hdr[8:12], c.seq)
+	// nfgenmsg
+	hdr[16] = family
+	hdr[17] = 0 // NFNETLINK_V0
+	binary.BigEndian.PutUint16(hdr[18:20], resID)
+	buf = append(buf, hdr...)
+	buf = append(buf, data...)
+	return append(buf, make([]byte, nlAlign(length)-length)...)
+}
+
+// 等待每条消息的应答，返回第一个错误
+func (c *nlConn) waitAcks(first uint32, count int) error {
+	rb := make([]byte, 1<<16)
+	var firstErr error
+	for acked := 0; acked < count; {
+		n, _, err := unix.Recvfrom(c.fd, rb, 0)
+		if err != nil {
+			return fmt.Errorf("receive netlink ack failed: %w", err)
+		}
+		for b := rb[:n]; len(b) >= unix.NLMSG_HDRLEN; {
+			length := int(binary.NativeEndian.Uint32(b[0:4]))
+			if length < unix.NLMSG_HDRLEN || length > len(b) {
+				break
+			}
+			typ := binary.NativeEndian.Uint16(b[4:6])
+			seq := binary.NativeEndian.Uint32(b[8:12])
+			if typ == unix.NLMSG_ERROR && seq >= first && seq < first+uint32(count) && length >= unix.NLMSG_HDRLEN+4 {
+				acked++
+				if code := int32(binary.NativeEndian.Uint32(b[unix.NLMSG_HDRLEN:])); code != 0 && firstErr == nil {
+					firstErr = fmt.Errorf("nf_tables message %d: %w", seq-first, unix.Errno(-code))
+				}
+			}
+			b = b[min(nlAlign(length), len(b)):]
+		}
+		// 事务失败时内核只应答出错的消息
+		if firstErr != nil {
+			return firstErr
+		}
+	}
+	return nil
+}
+
+func nlAlign(n int) int {
+	return (n + nlmsgAlign - 1) &^ (nlmsgAlign - 1)
+}
+
+// attrs netlink 属性序列，nf_tables 的整数属性使用网络字节序
+type attrs []byte
+
+func (a *attrs) bytes(typ uint16, data []byte) {
+	length := 4 + len(data)
+	hdr := make([]byte, 4)
+	binary.NativeEndian.PutUint16(hdr[0:2], uint16(length))
+	binary.NativeEndian.PutUint16(hdr[2:4], typ)
+	*a = append(*a, hdr...)
+	*a = append(*a, data...)
+	*a = append(*a, make([]byte, nlAlign(length)-length)...)
+}
+
+func (a *attrs) str(typ uint16, s string) {
+	a.bytes(typ, append([]byte(s), 0))
+}
+
+func (a *attrs) u32(typ uint16, v uint32) {
+	a.bytes(typ, binary.BigEndian.AppendUint32(nil, v))
+}
+
+func (a *attrs) u64(typ uint16, v uint64) {
+	a.bytes(typ, binary.BigEndian.AppendUint64(nil, v))
+}
+
+func (a *attrs) nested(typ uint16, fill func(*attrs)) {
+	var inner attrs
+	fill(&inner)
+	a.bytes(typ|nlaFNested, inner)
+}
@@ -0,0 +1,465 @@
+//go:build linux
+
+package agent
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"sort"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// nf_tables 常量，取自 linux/netfilter/nf_tables.h
+const (
+	nftMsgNewTable   = 0
+	nftMsgDelTable   = 2
+	nftMsgNewChain   = 3
+	nftMsgNewRule    = 6
+	nftMsgDelRule    = 8
+	nftMsgNewSet     = 9
+	nftMsgNewSetElem = 12
+	nftMsgDelSetElem = 14
+
+	nftaTableName = 1
+
+	nftaChainTable  = 1
+	nftaChainName   = 3
+	nftaChainHook   = 4
+	nftaChainPolicy = 5
+	nftaChainType   = 7
+	nftaHookHooknum = 1
+	nftaHookPrio    = 2
+
+	nftaRuleTable = 1
+	nftaRuleChain = 2
+	nftaRuleExprs = 4
+
+	nftaSetTable   = 1
+	nftaSetName    = 2
+	nftaSetFlags   = 3
+	nftaSetKeyType = 4
+	nftaSetKeyLen  = 5
+	nftaSetID      = 10
+
+	nftaSetElemListTable    = 1
+	nftaSetElemListSet      = 2
+	nftaSetElemListElements = 3
+	nftaSetElemKey          = 1
+	nftaSetElemFlags        = 3
+
+	nftaListElem = 1
+	nftaExprName = 1
+	nftaExprData = 2
+	nftaDataVal  = 1
+	nftaDataVerd = 2
+	nftaVerdCode = 1
+	nftaVerdChn  = 2
+
+	nftSetInterval        = 0x4
+	nftSetElemIntervalEnd = 0x1
+
+	nftRegVerdict = 0
+	nftReg1       = 1
+
+	nftCmpEq  = 0
+	nftCmpNeq = 1
+
+	nftMetaNfproto          = 15
+	nftPayloadNetworkHeader = 1
+	nftCtState              = 0
+	nftLimitPkts            = 0
+	nftLimitPktBytes        = 1
+	nftLimitFInv            = 1
+
+	nfDrop   = 0
+	nfAccept = 1
+	nftJump  = 0xfffffffd // NFT_JUMP (-3)
+
+	nfInetPreRouting = 0
+	ctStateNew       = 1 << 3 // NF_CT_STATE_BIT(IP_CT_NEW)
+
+	// nft 的 ipv4_addr、ipv6_addr 数据类型
+	nftTypeIPv4 = 7
+	nftTypeIPv6 = 8
+
+	// 单条 NEWSETELEM 消息中的元素数
+	nftElemChunk = 512
+)
+
+// Nftables 通过 netlink 维护一张 inet 表：prerouting 钩子中按 block4 / block6 区间集合丢弃，
+// 限速条目渲染为 ratelimit 链中的规则。每次变更在一个事务中重建对应的集合或链，不依赖 nft 命令
+type Nftables struct {
+	Table string
+
+	mu      sync.Mutex
+	conn    *nlConn
+	blocked map[netip.Prefix]struct{}
+	limited map[netip.Prefix]Limit
+}
+
+// NewNftables 创建 nftables 后端，table 为空时使用 ipblock
+func NewNftables(table string) *Nftables {
+	if table == "" {
+		table = "ipblock"
+	}
+	return &Nftables{
+		Table:   table,
+		blocked: make(map[netip.Prefix]struct{}),
+		limited: make(map[netip.Prefix]Limit),
+	}
+}
+
+func (n *Nftables) Init() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		conn, err := dialNetfilter()
+		if err != nil {
+			return err
+		}
+		n.conn = conn
+	}
+	n.blocked = make(map[netip.Prefix]struct{})
+	n.limited = make(map[netip.Prefix]Limit)
+
+	// 先创建再删除，表不存在时也不会报错
+	if err := n.conn.batch([]nlMsg{n.tableMsg(nftMsgNewTable), n.tableMsg(nftMsgDelTable)}); err != nil {
+		return fmt.Errorf("reset nftables table %s failed: %w", n.Table, err)
+	}
+	objects := []nlMsg{
+		n.tableMsg(nftMsgNewTable),
+		n.setMsg("block4", nftTypeIPv4, 4, 1),
+		n.setMsg("block6", nftTypeIPv6, 16, 2),
+		n.chainMsg("ratelimit", false),
+		n.chainMsg("prerouting", true),
+	}
+	if err := n.conn.batch(objects); err != nil {
+		return fmt.Errorf("create nftables objects failed: %w", err)
+	}
+	rules := []nlMsg{
+		n.ruleMsg("prerouting", n.blockRule(unix.NFPROTO_IPV4, "block4")),
+		n.ruleMsg("prerouting", n.blockRule(unix.NFPROTO_IPV6, "block6")),
+		n.ruleMsg("prerouting", func(e *exprs) { e.jump("ratelimit") }),
+	}
+	if err := n.conn.batch(rules); err != nil {
+		return fmt.Errorf("create nftables rules failed: %w", err)
+	}
+	return nil
+}
+
+func (n *Nftables) Block(p netip.Prefix) error {
+	return n.updateBlocked(p, func() { n.blocked[p] = struct{}{} })
+}
+
+func (n *Nftables) Unblock(p netip.Prefix) error {
+	return n.updateBlocked(p, func() { delete(n.blocked, p) })
+}
+
+func (n *Nftables) Limit(p netip.Prefix, l Limit) error {
+	return n.updateLimited(func() { n.limited[p] = l })
+}
+
+func (n *Nftables) Unlimit(p netip.Prefix) error {
+	return n.updateLimited(func() { delete(n.limited, p) })
+}
+
+func (n *Nftables) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn = nil
+	return err
+}
+
+// 重建地址族对应的区间集合：区间集合不允许重叠，先聚合再整体替换
+func (n *Nftables) updateBlocked(p netip.Prefix, mutate func()) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return fmt.Errorf("nftables backend is not initialized")
+	}
+
+	previous := make(map[netip.Prefix]struct{}, len(n.blocked))
+	for k := range n.blocked {
+		previous[k] = struct{}{}
+	}
+	mutate()
+
+	set := "block4"
+	if p.Addr().Is6() {
+		set = "block6"
+	}
+	var family []netip.Prefix
+	for b := range n.blocked {
+		if b.Addr().Is6() == p.Addr().Is6() {
+			family = append(family, b)
+		}
+	}
+
+	msgs := []nlMsg{n.setElemMsg(nftMsgDelSetElem, set, nil)}
+	elems := intervalElements(aggregatePrefixes(family))
+	for len(elems) > 0 {
+		chunk := elems[:min(nftElemChunk, len(elems))]
+		elems = elems[len(chunk):]
+		msgs = append(msgs, n.setElemMsg(nftMsgNewSetElem, set, chunk))
+	}
+	if err := n.conn.batch(msgs); err != nil {
+		n.blocked = previous
+		return fmt.Errorf("update nftables set %s failed: %w", set, err)
+	}
+	return nil
+}
+
+// 清空并重建 ratelimit 链，每个限速前缀一条规则
+func (n *Nftables) updateLimited(mutate func()) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return fmt.Errorf("nftables backend is not initialized")
+	}
+
+	previous := make(map[netip.Prefix]Limit, len(n.limited))
+	for k, v := range n.limited {
+		previous[k] = v
+	}
+	mutate()
+
+	prefixes := make([]netip.Prefix, 0, len(n.limited))
+	for p := range n.limited {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return prefixes[i].String() < prefixes[j].String() })
+
+	var flush attrs
+	flush.str(nftaRuleTable, n.Table)
+	flush.str(nftaRuleChain, "ratelimit")
+	msgs := []nlMsg{{typ: nftMsgDelRule, family: unix.NFPROTO_INET, attrs: flush}}
+	for _, p := range prefixes {
+		msgs = append(msgs, n.ruleMsg("ratelimit", n.limitRule(p, n.limited[p])))
+	}
+	if err := n.conn.batch(msgs); err != nil {
+		n.limited = previous
+		return fmt.Errorf("update nftables ratelimit chain failed: %w", err)
+	}
+	return nil
+}
+
+func (n *Nftables) tableMsg(typ uint16) nlMsg {
+	var a attrs
+	a.str(nftaTableName, n.Table)
+	flags := uint16(0)
+	if typ == nftMsgNewTable {
+		flags = unix.NLM_F_CREATE
+	}
+	return nlMsg{typ: typ, flags: flags, family: unix.NFPROTO_INET, attrs: a}
+}
+
+func (n *Nftables) setMsg(name string, keyType, keyLen, id uint32) nlMsg {
+	var a attrs
+	a.str(nftaSetTable, n.Table)
+	a.str(nftaSetName, name)
+	a.u32(nftaSetFlags, nftSetInterval)
+	a.u32(nftaSetKeyType, keyType)
+	a.u32(nftaSetKeyLen, keyLen)
+	a.u32(nftaSetID, id)
+	return nlMsg{typ: nftMsgNewSet, flags: unix.NLM_F_CREATE, family: unix.NFPROTO_INET, attrs: a}
+}
+
+// base 为 true 时创建挂在 prerouting 上的基础链，优先级 -150 位于连接跟踪之后
+func (n *Nftables) chainMsg(name string, base bool) nlMsg {
+	var a attrs
+	a.str(nftaChainTable, n.Table)
+	a.str(nftaChainName, name)
+	if base {
+		a.nested(nftaChainHook, func(h *attrs) {
+			h.u32(nftaHookHooknum, nfInetPreRouting)
+			prio := int32(-150)
+			h.u32(nftaHookPrio, uint32(prio))
+		})
+		a.u32(nftaChainPolicy, nfAccept)
+		a.str(nftaChainType, "filter")
+	}
+	return nlMsg{typ: nftMsgNewChain, flags: unix.NLM_F_CREATE, family: unix.NFPROTO_INET, attrs: a}
+}
+
+func (n *Nftables) ruleMsg(chain string, build func(*exprs)) nlMsg {
+	var e exprs
+	build(&e)
+	var a attrs
+	a.str(nftaRuleTable, n.Table)
+	a.str(nftaRuleChain, chain)
+	a.nested(nftaRuleExprs, func(list *attrs) { *list = append(*list, e.attrs...) })
+	return nlMsg{typ: nftMsgNewRule, flags: unix.NLM_F_CREATE | unix.NLM_F_APPEND, family: unix.NFPROTO_INET, attrs: a}
+}
+
+func (n *Nftables) setElemMsg(typ uint16, set string, elems []setElem) nlMsg {
+	var a attrs
+	a.str(nftaSetElemListTable, n.Table)
+	a.str(nftaSetElemListSet, set)
+	// 不带元素的 DELSETELEM 清空集合
+	if len(elems) > 0 {
+		a.nested(nftaSetElemListElements, func(list *attrs) {
+			for _, el := range elems {
+				list.nested(nftaListElem, func(e *attrs) {
+					e.nested(nftaSetElemKey, func(k *attrs) { k.bytes(nftaDataVal, el.key) })
+					if el.end {
+						e.u32(nftaSetElemFlags, nftSetElemIntervalEnd)
+					}
+				})
+			}
+		})
+	}
+	flags := uint16(0)
+	if typ == nftMsgNewSetElem {
+		flags = unix.NLM_F_CREATE
+	}
+	return nlMsg{typ: typ, flags: flags, family: unix.NFPROTO_INET, attrs: a}
+}
+
+// meta nfproto <proto> <saddr> @set drop
+func (n *Nftables) blockRule(proto uint8, set string) func(*exprs) {
+	return func(e *exprs) {
+		e.matchNfproto(proto)
+		e.loadSaddr(proto == unix.NFPROTO_IPV6)
+		e.expr("lookup", func(a *attrs) {
+			a.str(1, set)     // NFTA_LOOKUP_SET
+			a.u32(2, nftReg1) // NFTA_LOOKUP_SREG
+		})
+		e.verdict(nfDrop)
+	}
+}
+
+// 默认：ct state new 超过每分钟 10 个（突发 20）时丢弃；指定 pps / bps 时按包数或字节数限速
+func (n *Nftables) limitRule(p netip.Prefix, l Limit) func(*exprs) {
+	return func(e *exprs) {
+		is6 := p.Addr().Is6()
+		proto := uint8(unix.NFPROTO_IPV4)
+		if is6 {
+			proto = unix.NFPROTO_IPV6
+		}
+		e.matchNfproto(proto)
+		e.loadSaddr(is6)
+		if p.Bits() < p.Addr().BitLen() {
+			e.bitwise(prefixMask(p), p.Addr().BitLen()/8)
+		}
+		e.cmp(nftCmpEq, p.Addr().AsSlice())
+
+		rate, unit, burst, typ := uint64(10), uint64(60), uint32(20), uint32(nftLimitPkts)
+		switch {
+		case l.PPS > 0:
+			rate, unit, burst = uint64(l.PPS), 1, 5
+		case l.BPS > 0:
+			rate, unit, burst, typ = uint64(l.BPS), 1, 0, nftLimitPktBytes
+		default:
+			e.expr("ct", func(a *attrs) {
+				a.u32(1, nftReg1)    // NFTA_CT_DREG
+				a.u32(2, nftCtState) // NFTA_CT_KEY
+			})
+			e.bitwise(binary.NativeEndian.AppendUint32(nil, ctStateNew), 4)
+			e.cmp(nftCmpNeq, make([]byte, 4))
+		}
+		e.expr("limit", func(a *attrs) {
+			a.u64(1, rate)  // NFTA_LIMIT_RATE
+			a.u64(2, unit)  // NFTA_LIMIT_UNIT
+			a.u32(3, burst) // NFTA_LIMIT_BURST
+			a.u32(4, typ)   // NFTA_LIMIT_TYPE
+			a.u32(5, nftLimitFInv)
+		})
+		e.verdict(nfDrop)
+	}
+}
+
+// exprs 规则的表达式列表
+type exprs struct{ attrs attrs }
+
+func (e *exprs) expr(name string, data func(*attrs)) {
+	e.attrs.nested(nftaListElem, func(a *attrs) {
+		a.str(nftaExprName, name)
+		a.nested(nftaExprData, data)
+	})
+}
+
+func (e *exprs) matchNfproto(proto uint8) {
+	e.expr("meta", func(a *attrs) {
+		a.u32(1, nftReg1)        // NFTA_META_DREG
+		a.u32(2, nftMetaNfproto) // NFTA_META_KEY
+	})
+	e.cmp(nftCmpEq, []byte{proto})
+}
+
+// 把源地址读入 reg1：IPv4 头偏移 12 长 4，IPv6 头偏移 8 长 16
+func (e *exprs) loadSaddr(is6 bool) {
+	offset, length := uint32(12), uint32(4)
+	if is6 {
+		offset, length = 8, 16
+	}
+	e.expr("payload", func(a *attrs) {
+		a.u32(1, nftReg1)                 // NFTA_PAYLOAD_DREG
+		a.u32(2, nftPayloadNetworkHeader) // NFTA_PAYLOAD_BASE
+		a.u32(3, offset)                  // NFTA_PAYLOAD_OFFSET
+		a.u32(4, length)                  // NFTA_PAYLOAD_LEN
+	})
+}
+
+func (e *exprs) cmp(op uint32, data []byte) {
+	e.expr("cmp", func(a *attrs) {
+		a.u32(1, nftReg1) // NFTA_CMP_SREG
+		a.u32(2, op)      // NFTA_CMP_OP
+		a.nested(3, func(d *attrs) { d.bytes(nftaDataVal, data) })
+	})
+}
+
+func (e *exprs) bitwise(mask []byte, length int) {
+	e.expr("bitwise", func(a *attrs) {
+		a.u32(1, nftReg1)        // NFTA_BITWISE_SREG
+		a.u32(2, nftReg1)        // NFTA_BITWISE_DREG
+		a.u32(3, uint32(length)) // NFTA_BITWISE_LEN
+		a.nested(4, func(d *attrs) { d.bytes(nftaDataVal, mask) })
+		a.nested(5, func(d *attrs) { d.bytes(nftaDataVal, make([]byte, length)) })
+	})
+}
+
+func (e *exprs) verdict(code uint32) {
+	e.expr("immediate", func(a *attrs) {
+		a.u32(1, nftRegVerdict) // NFTA_IMMEDIATE_DREG
+		a.nested(2, func(d *attrs) {
+			d.nested(nftaDataVerd, func(v *attrs) { v.u32(nftaVerdCode, code) })
+		})
+	})
+}
+
+func (e *exprs) jump(chain string) {
+	e.expr("immediate", func(a *attrs) {
+		a.u32(1, nftRegVerdict)
+		a.nested(2, func(d *attrs) {
+			d.nested(nftaDataVerd, func(v *attrs) {
+				v.u32(nftaVerdCode, nftJump)
+				v.str(nftaVerdChn, chain)
+			})
+		})
+	})
+}
+
+type setElem struct {
+	key []byte
+	end bool
+}
+
+// 每个前缀展开为区间起点和（最后一个地址 + 1）的终点；终点溢出地址空间时省略
+func intervalElements(prefixes []netip.Prefix) []setElem {
+	var elems []setElem
+	for _, p := range prefixes {
+		elems = append(elems, setElem{key: p.Addr().AsSlice()})
+		if next := lastAddr(p).Next(); next.IsValid() {
+			elems = append(elems, setElem{key: next.AsSlice(), end: true})
+		}
+	}
+	return elems
+}
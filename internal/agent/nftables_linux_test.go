@@ -0,0 +1,81 @@
+//go:build linux
+
+package agent
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+)
+
+// 需要 CAP_NET_ADMIN，设置 IPBLOCK_NFT_TEST=1 时对真实内核运行
+func TestNftablesKernel(t *testing.T) {
+	if os.Getenv("IPBLOCK_NFT_TEST") != "1" {
+		t.Skip("set IPBLOCK_NFT_TEST=1 to run against the kernel")
+	}
+
+	n := NewNftables("ipblock_test")
+	if err := n.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = n.conn.batch([]nlMsg{n.tableMsg(nftMsgDelTable)})
+		_ = n.Close()
+	})
+
+	for _, s := range []string{"127.0.0.2/32", "10.0.0.0/8", "10.1.0.0/16", "2001:db8::/32"} {
+		if err := n.Block(netip.MustParsePrefix(s)); err != nil {
+			t.Fatalf("block %s: %v", s, err)
+		}
+	}
+	for s, l := range map[string]Limit{"127.0.0.3/32": {}, "127.0.0.4/32": {PPS: 10}, "2001:db8:1::/48": {BPS: 1 << 20}} {
+		if err := n.Limit(netip.MustParsePrefix(s), l); err != nil {
+			t.Fatalf("limit %s: %v", s, err)
+		}
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	dial := func(from string) error {
+		d := net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(from)}, Timeout: 500 * time.Millisecond}
+		c, err := d.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			c.Close()
+		}
+		return err
+	}
+
+	if dial("127.0.0.2") == nil {
+		t.Error("blocked source connected")
+	}
+	if err := dial("127.0.0.6"); err != nil {
+		t.Errorf("unblocked source: %v", err)
+	}
+	if err := n.Unblock(netip.MustParsePrefix("127.0.0.2/32")); err != nil {
+		t.Fatal(err)
+	}
+	if err := dial("127.0.0.2"); err != nil {
+		t.Errorf("source after unblock: %v", err)
+	}
+	if err := n.Unlimit(netip.MustParsePrefix("127.0.0.4/32")); err != nil {
+		t.Fatal(err)
+	}
+	// 重新初始化清空遗留条目
+	if err := n.Init(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package agent
+
+import (
+	"errors"
+	"net/netip"
+)
+
+var errNftablesUnsupported = errors.New("nftables backend is only supported on linux")
+
+// Nftables 非 Linux 平台不可用
+type Nftables struct {
+	Table string
+}
+
+func NewNftables(table string) *Nftables {
+	return &Nftables{Table: table}
+}
+
+func (n *Nftables) Init() error                         { return errNftablesUnsupported }
+func (n *Nftables) Block(p netip.Prefix) error          { return errNftablesUnsupported }
+func (n *Nftables) Unblock(p netip.Prefix) error        { return errNftablesUnsupported }
+func (n *Nftables) Limit(p netip.Prefix, l Limit) error { return errNftablesUnsupported }
+func (n *Nftables) Unlimit(p netip.Prefix) error        { return errNftablesUnsupported }
+func (n *Nftables) Close() error                        { return nil }
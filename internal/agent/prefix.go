@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// 去掉被其他前缀包含的前缀，结果互不重叠，按地址排序
+func aggregatePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	sorted := append([]netip.Prefix(nil), prefixes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := sorted[i].Addr().Compare(sorted[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return sorted[i].Bits() < sorted[j].Bits()
+	})
+
+	var result []netip.Prefix
+	for _, p := range sorted {
+		// 排序后包含 p 的前缀只可能是上一个保留的前缀
+		if n := len(result); n > 0 && result[n-1].Overlaps(p) {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// 前缀中的最后一个地址
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// 前缀的网络掩码
+func prefixMask(p netip.Prefix) []byte {
+	mask := make([]byte, p.Addr().BitLen()/8)
+	for i := 0; i < p.Bits(); i++ {
+		mask[i/8] |= 1 << (7 - i%8)
+	}
+	return mask
+}
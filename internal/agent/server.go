@@ -0,0 +1,375 @@
+package agent
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// RequestIDHeader 与引擎一致，POST 请求体中未带 requestId 时从该请求头读取
+	RequestIDHeader = "X-Request-ID"
+
+	requestCacheTTL  = 10 * time.Minute
+	requestCacheSize = 4096
+	maxBatchItems    = 1000
+)
+
+// Server 提供封禁引擎（xdp、iptables）调用的 HTTP 接口。
+//
+// 旧协议与原 control.py 兼容：
+//
+//	/update?cidr=&ban_type=&ban_time=  封禁，ban_type=1 为永久，否则按 ban_time 秒到期
+//	/remove?cidr=                      解封
+//	/limit?ip=[&pps=&bps=]             限速
+//	/unlimit?ip=                       解除限速
+//	/list                              列出当前封禁表
+//	/healthz                           健康检查，后端可用时返回 200
+//
+// JSON 协议：POST /v1/ban、/v1/unban、/v1/limit、/v1/unlimit，请求体见 Request，
+// 相同 requestId 的重试直接返回第一次的结果。
+// POST /v1/batch 一次提交多个同类操作，请求体见 BatchRequest
+type Server struct {
+	table *Table
+	token string
+	mux   *http.ServeMux
+
+	mu       sync.Mutex
+	requests map[string]cachedResponse
+}
+
+// Request JSON 协议的请求体
+type Request struct {
+	RequestID       string `json:"requestId"`
+	CIDR            string `json:"cidr"`
+	Permanent       bool   `json:"permanent,omitempty"`
+	DurationSeconds int64  `json:"durationSeconds,omitempty"`
+	PPS             int64  `json:"pps,omitempty"`
+	BPS             int64  `json:"bps,omitempty"`
+}
+
+// Response JSON 协议的应答，Status 为 ok 或 error
+type Response struct {
+	RequestID string `json:"requestId"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+}
+
+// BatchRequest 批量请求，Op 为 ban、unban、limit、unlimit，Items 按顺序逐个执行
+type BatchRequest struct {
+	RequestID string    `json:"requestId"`
+	Op        string    `json:"op"`
+	Items     []Request `json:"items"`
+}
+
+// BatchResponse 批量应答，Results 与 Items 一一对应，单个条目失败不影响其他条目
+type BatchResponse struct {
+	RequestID string     `json:"requestId"`
+	Results   []Response `json:"results"`
+}
+
+type cachedResponse struct {
+	code     int
+	response any
+	at       time.Time
+}
+
+// NewServer token 不为空时所有接口都要求 Authorization: Bearer <token>
+func NewServer(table *Table, token string) *Server {
+	s := &Server{table: table, token: token, mux: http.NewServeMux(), requests: make(map[string]cachedResponse)}
+	s.mux.HandleFunc("/update", s.handleUpdate)
+	s.mux.HandleFunc("/remove", s.handleRemove)
+	s.mux.HandleFunc("/limit", s.handleLimit)
+	s.mux.HandleFunc("/unlimit", s.handleUnlimit)
+	s.mux.HandleFunc("/list", s.handleList)
+	s.mux.HandleFunc("/healthz", s.handleHealthz)
+	s.mux.HandleFunc("/v1/ban", s.handleJSON(s.ban))
+	s.mux.HandleFunc("/v1/unban", s.handleJSON(s.unban))
+	s.mux.HandleFunc("/v1/limit", s.handleJSON(s.limit))
+	s.mux.HandleFunc("/v1/unlimit", s.handleJSON(s.unlimit))
+	s.mux.HandleFunc("/v1/batch", s.handleBatch)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" {
+		want := "Bearer " + s.token
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"status": "error", "message": "unauthorized"})
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// 旧协议的处理函数
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var seconds int64
+	permanent := q.Get("ban_type") == "1"
+	if !permanent {
+		seconds, _ = strconv.ParseInt(q.Get("ban_time"), 10, 64)
+	}
+	code, msg := s.ban(Request{CIDR: q.Get("cidr"), Permanent: permanent, DurationSeconds: seconds})
+	writeJSON(w, code, map[string]string{"message": msg})
+}
+
+func (s *Server) handleRemove(w http.ResponseWriter, r *http.Request) {
+	code, msg := s.unban(Request{CIDR: r.URL.Query().Get("cidr")})
+	writeJSON(w, code, map[string]string{"message": msg})
+}
+
+// 重复限速同一地址视为成功，只更新参数
+func (s *Server) handleLimit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ip := q.Get("ip")
+	req := Request{CIDR: ip}
+	for name, dst := range map[string]*int64{"pps": &req.PPS, "bps": &req.BPS} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"ip": ip, "status": "failed", "error": name + " must be a positive integer"})
+			return
+		}
+		*dst = n
+	}
+	code, msg := s.limit(req)
+	if code != http.StatusOK {
+		writeJSON(w, code, map[string]string{"ip": ip, "status": "failed", "error": msg})
+		return
+	}
+	writeJSON(w, code, map[string]string{"ip": ip, "status": "limited"})
+}
+
+func (s *Server) handleUnlimit(w http.ResponseWriter, r *http.Request) {
+	ip := r.URL.Query().Get("ip")
+	code, msg := s.unlimit(Request{CIDR: ip})
+	if code != http.StatusOK {
+		writeJSON(w, code, map[string]string{"ip": ip, "status": "failed", "error": msg})
+		return
+	}
+	writeJSON(w, code, map[string]string{"ip": ip, "status": "unlimited"})
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]Entry{"entries": s.table.List()})
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Response{Status: "ok", Message: fmt.Sprintf("%d entries", len(s.table.List()))})
+}
+
+// JSON 协议：按 requestId 去重，服务端错误不缓存，以便客户端重试
+func (s *Server) handleJSON(op func(Request) (int, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, Response{Status: "error", Message: "method not allowed"})
+			return
+		}
+		var req Request
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, Response{Status: "error", Message: "invalid request body: " + err.Error()})
+			return
+		}
+		if req.RequestID == "" {
+			req.RequestID = r.Header.Get(RequestIDHeader)
+		}
+
+		key := r.URL.Path + " " + req.RequestID
+		if req.RequestID != "" {
+			if cached, ok := s.cachedResponse(key); ok {
+				log.Printf("重复请求 %s，返回上次结果", req.RequestID)
+				writeJSON(w, cached.code, cached.response)
+				return
+			}
+		}
+
+		code, msg := op(req)
+		resp := Response{RequestID: req.RequestID, Status: "ok", Message: msg}
+		if code != http.StatusOK {
+			resp.Status = "error"
+		}
+		if req.RequestID != "" && code < http.StatusInternalServerError {
+			s.cacheResponse(key, cachedResponse{code: code, response: resp, at: time.Now()})
+		}
+		writeJSON(w, code, resp)
+	}
+}
+
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, Response{Status: "error", Message: "method not allowed"})
+		return
+	}
+	var req BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<22)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{Status: "error", Message: "invalid request body: " + err.Error()})
+		return
+	}
+	ops := map[string]func(Request) (int, string){"ban": s.ban, "unban": s.unban, "limit": s.limit, "unlimit": s.unlimit}
+	op, ok := ops[req.Op]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, Response{RequestID: req.RequestID, Status: "error", Message: fmt.Sprintf("unknown op '%s'", req.Op)})
+		return
+	}
+	if len(req.Items) > maxBatchItems {
+		writeJSON(w, http.StatusBadRequest, Response{RequestID: req.RequestID, Status: "error", Message: fmt.Sprintf("at most %d items per batch", maxBatchItems)})
+		return
+	}
+	if req.RequestID == "" {
+		req.RequestID = r.Header.Get(RequestIDHeader)
+	}
+
+	key := r.URL.Path + " " + req.RequestID
+	if req.RequestID != "" {
+		if cached, ok := s.cachedResponse(key); ok {
+			log.Printf("重复请求 %s，返回上次结果", req.RequestID)
+			writeJSON(w, cached.code, cached.response)
+			return
+		}
+	}
+
+	resp := BatchResponse{RequestID: req.RequestID, Results: make([]Response, len(req.Items))}
+	cacheable := true
+	for i, item := range req.Items {
+		code, msg := op(item)
+		resp.Results[i] = Response{RequestID: item.RequestID, Status: "ok", Message: msg}
+		if code != http.StatusOK {
+			resp.Results[i].Status = "error"
+		}
+		// 有条目遇到服务端错误时不缓存，重试时重新执行（封禁和解封都是幂等的）
+		if code >= http.StatusInternalServerError {
+			cacheable = false
+		}
+	}
+	log.Printf("批量 %s %d 条 (request %s)", req.Op, len(req.Items), req.RequestID)
+	if req.RequestID != "" && cacheable {
+		s.cacheResponse(key, cachedResponse{code: http.StatusOK, response: resp, at: time.Now()})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// 以下操作返回 HTTP 状态码和消息，消息沿用 control.py 的格式，旧版引擎据此判断结果
+
+func (s *Server) ban(req Request) (int, string) {
+	p, err := ParsePrefix(req.CIDR)
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	var ttl time.Duration
+	if !req.Permanent {
+		if req.DurationSeconds <= 0 {
+			return http.StatusBadRequest, "ban_time must be a positive number of seconds for temporary bans"
+		}
+		ttl = time.Duration(req.DurationSeconds) * time.Second
+	}
+
+	updated, err := s.table.Block(p, ttl)
+	if err != nil {
+		log.Printf("封禁 %s 失败: %v", req.CIDR, err)
+		return http.StatusInternalServerError, fmt.Sprintf("Failed to add %s: %v", req.CIDR, err)
+	}
+	log.Printf("已封禁 %s，时长 %s", p, ttlString(ttl))
+	if updated {
+		return http.StatusOK, fmt.Sprintf("%s have been updated", req.CIDR)
+	}
+	return http.StatusOK, fmt.Sprintf("Successfully added %s to banned list", req.CIDR)
+}
+
+func (s *Server) unban(req Request) (int, string) {
+	p, err := ParsePrefix(req.CIDR)
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	existed, err := s.table.Unblock(p)
+	if err != nil {
+		log.Printf("解封 %s 失败: %v", req.CIDR, err)
+		return http.StatusInternalServerError, fmt.Sprintf("Failed to remove %s: %v", req.CIDR, err)
+	}
+	if !existed {
+		return http.StatusOK, fmt.Sprintf("%s not exists", req.CIDR)
+	}
+	log.Printf("已解封 %s", p)
+	return http.StatusOK, fmt.Sprintf("Successfully removed %s", req.CIDR)
+}
+
+func (s *Server) limit(req Request) (int, string) {
+	p, err := ParsePrefix(req.CIDR)
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	if req.PPS < 0 || req.BPS < 0 {
+		return http.StatusBadRequest, "pps and bps must not be negative"
+	}
+	if _, err := s.table.Limit(p, Limit{PPS: req.PPS, BPS: req.BPS}, 0); err != nil {
+		log.Printf("限速 %s 失败: %v", req.CIDR, err)
+		return http.StatusInternalServerError, err.Error()
+	}
+	log.Printf("已限速 %s", p)
+	return http.StatusOK, "limited"
+}
+
+func (s *Server) unlimit(req Request) (int, string) {
+	p, err := ParsePrefix(req.CIDR)
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	if _, err := s.table.Unlimit(p); err != nil {
+		log.Printf("解除限速 %s 失败: %v", req.CIDR, err)
+		return http.StatusInternalServerError, err.Error()
+	}
+	log.Printf("已解除限速 %s", p)
+	return http.StatusOK, "unlimited"
+}
+
+func (s *Server) cachedResponse(key string) (cachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached, ok := s.requests[key]
+	if !ok || time.Since(cached.at) > requestCacheTTL {
+		return cachedResponse{}, false
+	}
+	return cached, true
+}
+
+func (s *Server) cacheResponse(key string, resp cachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) >= requestCacheSize {
+		for k, v := range s.requests {
+			if time.Since(v.at) > requestCacheTTL {
+				delete(s.requests, k)
+			}
+		}
+		// 仍然超出时丢弃任意条目，去重只是尽力而为
+		for k := range s.requests {
+			if len(s.requests) < requestCacheSize {
+				break
+			}
+			delete(s.requests, k)
+		}
+	}
+	s.requests[key] = resp
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func ttlString(ttl time.Duration) string {
+	if ttl == 0 {
+		return "永久"
+	}
+	return ttl.String()
+}
@@ -0,0 +1,296 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+)
+
+func newTestServer(t *testing.T) (*Memory, *Table, string) {
+	t.Helper()
+	backend := NewMemory()
+	table, err := NewTable(backend, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(table, ""))
+	t.Cleanup(srv.Close)
+	return backend, table, strings.TrimPrefix(srv.URL, "http://")
+}
+
+// 现有引擎无需修改即可对接 agent
+func TestServerCompatibleWithEngines(t *testing.T) {
+	backend, table, host := newTestServer(t)
+	xdp := &engine.XDPAdapter{GatewayHost: host}
+	iptables := &engine.IptablesAdapter{GatewayHost: host}
+
+	if _, err := xdp.Ban(engine.BanRequest{IP: "192.0.2.1", DurationSeconds: 600}); err != nil {
+		t.Fatal(err)
+	}
+	// 重复封禁只更新到期时间
+	if _, err := xdp.Ban(engine.BanRequest{IP: "192.0.2.1", Permanent: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := xdp.Ban(engine.BanRequest{IP: "198.51.100.0/24", Permanent: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := backend.Blocked(); !reflect.DeepEqual(got, []string{"192.0.2.1/32", "198.51.100.0/24"}) {
+		t.Errorf("blocked = %v", got)
+	}
+	for _, e := range table.List() {
+		if e.ExpiresAt != nil {
+			t.Errorf("%s should be permanent after update", e.CIDR)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := iptables.Ban(engine.BanRequest{IP: "203.0.113.7"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := backend.Limited(); !reflect.DeepEqual(got, map[string]Limit{"203.0.113.7/32": {}}) {
+		t.Errorf("limited = %v", got)
+	}
+
+	if _, err := xdp.UnBan(engine.BanRequest{IP: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	// 不存在的条目视为已解封
+	if _, err := xdp.UnBan(engine.BanRequest{IP: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := iptables.UnBan(engine.BanRequest{IP: "203.0.113.7"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := backend.Blocked(); !reflect.DeepEqual(got, []string{"198.51.100.0/24"}) {
+		t.Errorf("blocked = %v", got)
+	}
+	if got := backend.Limited(); len(got) != 0 {
+		t.Errorf("limited = %v", got)
+	}
+}
+
+func TestServerList(t *testing.T) {
+	_, _, host := newTestServer(t)
+	get := func(path string) *http.Response {
+		t.Helper()
+		resp, err := http.Get("http://" + host + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	get("/update?cidr=192.0.2.1&ban_type=0&ban_time=60")
+	get("/limit?ip=2001:db8::1&pps=100")
+
+	var result struct {
+		Entries []Entry `json:"entries"`
+	}
+	if err := json.NewDecoder(get("/list").Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 {
+		t.Fatalf("entries = %+v", result.Entries)
+	}
+	block, limit := result.Entries[0], result.Entries[1]
+	if block.Kind != KindBlock || block.CIDR != "192.0.2.1/32" || block.ExpiresAt == nil {
+		t.Errorf("block entry = %+v", block)
+	}
+	if limit.Kind != KindLimit || limit.CIDR != "2001:db8::1/128" || limit.Limit == nil || limit.Limit.PPS != 100 {
+		t.Errorf("limit entry = %+v", limit)
+	}
+}
+
+func TestServerRejectsBadInput(t *testing.T) {
+	backend, _, host := newTestServer(t)
+	for _, path := range []string{
+		"/update?cidr=not-an-ip&ban_type=1",
+		"/update?cidr=0.0.0.0/0&ban_type=1",
+		"/update?cidr=192.0.2.1&ban_type=0",
+		"/update?cidr=192.0.2.1&ban_type=0&ban_time=-1",
+		"/remove?cidr=",
+		"/limit?ip=192.0.2.1&pps=abc",
+		"/unlimit?ip=",
+	} {
+		resp, err := http.Get("http://" + host + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d", path, resp.StatusCode)
+		}
+	}
+	if len(backend.Blocked()) != 0 || len(backend.Limited()) != 0 {
+		t.Error("rejected requests reached the backend")
+	}
+}
+
+func TestServerJSONProtocol(t *testing.T) {
+	backend := NewMemory()
+	table, err := NewTable(backend, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(table, "s3cret"))
+	defer srv.Close()
+
+	client, err := engine.NewGatewayClient(srv.URL, engine.GatewayConfig{Protocol: engine.GatewayProtocolPost}, engine.GatewayCredentials{Token: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	xdp, _ := engine.NewGatewayAdapter(engine.XDPEngine, client)
+	iptables, _ := engine.NewGatewayAdapter(engine.IptablesEngine, client)
+
+	if _, err := xdp.Ban(engine.BanRequest{IP: "2001:db8::/48", Permanent: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := iptables.Ban(engine.BanRequest{IP: "192.0.2.9"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := backend.Blocked(); !reflect.DeepEqual(got, []string{"2001:db8::/48"}) {
+		t.Errorf("blocked = %v", got)
+	}
+	if got := backend.Limited(); len(got) != 1 {
+		t.Errorf("limited = %v", got)
+	}
+	if _, err := xdp.Ban(engine.BanRequest{IP: "192.0.2.1"}); err == nil {
+		t.Error("temporary ban without duration should be rejected")
+	}
+
+	// 没有 Token 的请求被拒绝，旧协议同样需要认证
+	for _, c := range []*engine.GatewayClient{
+		{Host: srv.URL, Protocol: engine.GatewayProtocolPost},
+		{Host: srv.URL},
+	} {
+		if _, err := (&engine.XDPAdapter{Client: c}).UnBan(engine.BanRequest{IP: "2001:db8::/48"}); err == nil {
+			t.Error("unauthenticated unban succeeded")
+		}
+	}
+	if got := backend.Blocked(); len(got) != 1 {
+		t.Errorf("blocked = %v", got)
+	}
+}
+
+func TestServerDeduplicatesRequestID(t *testing.T) {
+	backend := NewMemory()
+	table, err := NewTable(backend, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(table, ""))
+	defer srv.Close()
+
+	post := func(path, body string) Response {
+		t.Helper()
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var r Response
+		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	first := post("/v1/ban", `{"requestId":"a1","cidr":"192.0.2.1","permanent":true}`)
+	if first.Status != "ok" || !strings.Contains(first.Message, "Successfully added") {
+		t.Fatalf("first = %+v", first)
+	}
+	// 请求之间被解封，重试同一个请求 ID 不会再次封禁
+	if _, err := table.Unblock(netip.MustParsePrefix("192.0.2.1/32")); err != nil {
+		t.Fatal(err)
+	}
+	if retry := post("/v1/ban", `{"requestId":"a1","cidr":"192.0.2.1","permanent":true}`); retry != first {
+		t.Errorf("retry = %+v", retry)
+	}
+	if got := backend.Blocked(); len(got) != 0 {
+		t.Errorf("blocked = %v", got)
+	}
+	if r := post("/v1/ban", `{"requestId":"a2","cidr":"192.0.2.1","permanent":true}`); r.Status != "ok" {
+		t.Errorf("new request = %+v", r)
+	}
+	if got := backend.Blocked(); len(got) != 1 {
+		t.Errorf("blocked = %v", got)
+	}
+}
+
+// 引擎的健康检查请求 /healthz，Token 错误时视为不可达
+func TestServerHealthz(t *testing.T) {
+	table, err := NewTable(NewMemory(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(table, "secret"))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	ok := &engine.XDPAdapter{Client: &engine.GatewayClient{Host: host, Token: "secret"}}
+	if err := engine.CheckHealth(context.Background(), ok); err != nil {
+		t.Errorf("health = %v", err)
+	}
+	bad := &engine.XDPAdapter{Client: &engine.GatewayClient{Host: host, Token: "wrong"}}
+	if err := engine.CheckHealth(context.Background(), bad); err == nil {
+		t.Error("health with wrong token should fail")
+	}
+	srv.Close()
+	if err := engine.CheckHealth(context.Background(), ok); err == nil {
+		t.Error("health of stopped agent should fail")
+	}
+}
+
+// 批量封禁只发起一次请求，单个条目失败不影响其他条目
+func TestServerBatch(t *testing.T) {
+	backend := NewMemory()
+	table, err := NewTable(backend, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	handler := NewServer(table, "")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	client, err := engine.NewGatewayClient(srv.URL, engine.GatewayConfig{Protocol: engine.GatewayProtocolPost}, engine.GatewayCredentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	xdp := &engine.XDPAdapter{Client: client}
+	results := xdp.BanBatch([]engine.BanRequest{
+		{IP: "192.0.2.1", Permanent: true},
+		{IP: "bogus", Permanent: true},
+		{IP: "198.51.100.0/24", DurationSeconds: 600},
+	})
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if len(results) != 3 || results[0].Err != nil || results[1].Err == nil || results[2].Err != nil {
+		t.Fatalf("results = %+v", results)
+	}
+	if got := backend.Blocked(); !reflect.DeepEqual(got, []string{"192.0.2.1/32", "198.51.100.0/24"}) {
+		t.Errorf("blocked = %v", got)
+	}
+
+	for _, r := range xdp.UnBanBatch([]engine.BanRequest{{IP: "192.0.2.1"}, {IP: "198.51.100.0/24"}}) {
+		if r.Err != nil {
+			t.Error(r.Err)
+		}
+	}
+	if got := backend.Blocked(); len(got) != 0 {
+		t.Errorf("blocked after unban = %v", got)
+	}
+}
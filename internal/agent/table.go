@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 条目类型
+const (
+	KindBlock = "block"
+	KindLimit = "limit"
+)
+
+// Entry 封禁表中的一条记录
+type Entry struct {
+	CIDR      string     `json:"cidr"`
+	Kind      string     `json:"kind"`
+	Limit     *Limit     `json:"limit,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"` // 为空表示永久
+}
+
+type entryKey struct {
+	kind   string
+	prefix netip.Prefix
+}
+
+// Table 封禁表：记录当前生效的封禁和限速，下发到后端并持久化到磁盘，
+// 启动时重新下发未过期的条目，到期后自动撤销
+type Table struct {
+	backend Backend
+	path    string // 持久化文件，为空时不持久化
+
+	mu      sync.Mutex
+	entries map[entryKey]*Entry
+	now     func() time.Time
+}
+
+// NewTable 初始化后端，加载持久化文件并重新下发其中未过期的条目
+func NewTable(backend Backend, path string) (*Table, error) {
+	t := &Table{
+		backend: backend,
+		path:    path,
+		entries: make(map[entryKey]*Entry),
+		now:     time.Now,
+	}
+	if err := backend.Init(); err != nil {
+		return nil, fmt.Errorf("init backend failed: %w", err)
+	}
+
+	saved, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	now := t.now()
+	for _, e := range saved {
+		if e.ExpiresAt != nil && !e.ExpiresAt.After(now) {
+			continue
+		}
+		p, err := ParsePrefix(e.CIDR)
+		if err != nil {
+			log.Printf("跳过无效的持久化条目 %s: %v", e.CIDR, err)
+			continue
+		}
+		// 单个条目下发失败不影响其余条目，失败的条目不再保留
+		if err := t.apply(e.Kind, p, e.Limit); err != nil {
+			log.Printf("重新下发 %s %s 失败: %v", e.Kind, e.CIDR, err)
+			continue
+		}
+		entry := e
+		t.entries[entryKey{e.Kind, p}] = &entry
+	}
+	log.Printf("已恢复 %d 条封禁记录", len(t.entries))
+	return t, t.save()
+}
+
+// Block 封禁前缀，ttl 为 0 表示永久。已存在时只更新到期时间，返回 true
+func (t *Table) Block(p netip.Prefix, ttl time.Duration) (bool, error) {
+	return t.put(KindBlock, p, nil, ttl)
+}
+
+// Unblock 解除封禁，不存在时返回 false
+func (t *Table) Unblock(p netip.Prefix) (bool, error) {
+	return t.remove(KindBlock, p)
+}
+
+// Limit 限速前缀，已存在时更新参数和到期时间，返回 true
+func (t *Table) Limit(p netip.Prefix, l Limit, ttl time.Duration) (bool, error) {
+	return t.put(KindLimit, p, &l, ttl)
+}
+
+// Unlimit 解除限速，不存在时返回 false
+func (t *Table) Unlimit(p netip.Prefix) (bool, error) {
+	return t.remove(KindLimit, p)
+}
+
+// List 返回所有条目，按类型和 CIDR 排序
+func (t *Table) List() []Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]Entry, 0, len(t.entries))
+	for _, e := range t.entries {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Kind != list[j].Kind {
+			return list[i].Kind < list[j].Kind
+		}
+		return list[i].CIDR < list[j].CIDR
+	})
+	return list
+}
+
+// Expire 撤销所有已到期的条目
+func (t *Table) Expire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	changed := false
+	for key, e := range t.entries {
+		if e.ExpiresAt == nil || e.ExpiresAt.After(now) {
+			continue
+		}
+		if err := t.revoke(key.kind, key.prefix); err != nil {
+			// 保留条目，下次再试
+			log.Printf("到期撤销 %s %s 失败: %v", key.kind, e.CIDR, err)
+			continue
+		}
+		log.Printf("%s %s 已到期撤销", key.kind, e.CIDR)
+		delete(t.entries, key)
+		changed = true
+	}
+	if changed {
+		if err := t.save(); err != nil {
+			log.Printf("保存封禁表失败: %v", err)
+		}
+	}
+}
+
+// Run 定期撤销到期条目，直到 ctx 结束
+func (t *Table) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.Expire()
+		}
+	}
+}
+
+func (t *Table) put(kind string, p netip.Prefix, l *Limit, ttl time.Duration) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	key := entryKey{kind, p}
+	existing, ok := t.entries[key]
+	// 限速参数变化时需要重新下发
+	if !ok || (l != nil && (existing.Limit == nil || *existing.Limit != *l)) {
+		if err := t.apply(kind, p, l); err != nil {
+			return false, err
+		}
+	}
+
+	entry := &Entry{CIDR: p.String(), Kind: kind, Limit: l, CreatedAt: now}
+	if ok {
+		entry.CreatedAt = existing.CreatedAt
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		entry.ExpiresAt = &expires
+	}
+	t.entries[key] = entry
+	return ok, t.save()
+}
+
+func (t *Table) remove(kind string, p netip.Prefix) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := entryKey{kind, p}
+	if _, ok := t.entries[key]; !ok {
+		return false, nil
+	}
+	if err := t.revoke(kind, p); err != nil {
+		return true, err
+	}
+	delete(t.entries, key)
+	return true, t.save()
+}
+
+func (t *Table) apply(kind string, p netip.Prefix, l *Limit) error {
+	if kind == KindLimit {
+		var limit Limit
+		if l != nil {
+			limit = *l
+		}
+		return t.backend.Limit(p, limit)
+	}
+	return t.backend.Block(p)
+}
+
+func (t *Table) revoke(kind string, p netip.Prefix) error {
+	if kind == KindLimit {
+		return t.backend.Unlimit(p)
+	}
+	return t.backend.Unblock(p)
+}
+
+func (t *Table) load() ([]Entry, error) {
+	if t.path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state file failed: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse state file %s failed: %w", t.path, err)
+	}
+	return entries, nil
+}
+
+// 先写临时文件再重命名，避免写入中断导致文件损坏
+func (t *Table) save() error {
+	if t.path == "" {
+		return nil
+	}
+	list := make([]Entry, 0, len(t.entries))
+	for _, e := range t.entries {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CIDR < list[j].CIDR })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write state file failed: %w", err)
+	}
+	return os.Rename(tmp, t.path)
+}
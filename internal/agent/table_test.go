@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTableRestoresState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	table, err := NewTable(NewMemory(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 写入时间提前一分钟，重启时短期封禁已到期
+	table.now = func() time.Time { return time.Now().Add(-time.Minute) }
+
+	mustBlock := func(s string, ttl time.Duration) {
+		t.Helper()
+		if _, err := table.Block(netip.MustParsePrefix(s), ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustBlock("192.0.2.0/24", 0)
+	mustBlock("198.51.100.1/32", time.Hour)
+	mustBlock("203.0.113.1/32", time.Second)
+	if _, err := table.Limit(netip.MustParsePrefix("2001:db8::/64"), Limit{BPS: 1 << 20}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟重启：新的后端为空，由持久化文件重新下发，期间到期的条目不再下发
+	backend := NewMemory()
+	if _, err := NewTable(backend, path); err != nil {
+		t.Fatal(err)
+	}
+	if got := backend.Blocked(); !reflect.DeepEqual(got, []string{"192.0.2.0/24", "198.51.100.1/32"}) {
+		t.Errorf("blocked after restart = %v", got)
+	}
+	if got := backend.Limited(); !reflect.DeepEqual(got, map[string]Limit{"2001:db8::/64": {BPS: 1 << 20}}) {
+		t.Errorf("limited after restart = %v", got)
+	}
+}
+
+func TestTableExpire(t *testing.T) {
+	backend := NewMemory()
+	table, err := NewTable(backend, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	table.now = func() time.Time { return now }
+
+	if _, err := table.Block(netip.MustParsePrefix("192.0.2.1/32"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.Block(netip.MustParsePrefix("192.0.2.2/32"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.Limit(netip.MustParsePrefix("192.0.2.3/32"), Limit{}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(30 * time.Second)
+	// 重新封禁会延长到期时间
+	if updated, err := table.Block(netip.MustParsePrefix("192.0.2.1/32"), time.Minute); err != nil || !updated {
+		t.Fatalf("updated = %v, err = %v", updated, err)
+	}
+	now = now.Add(45 * time.Second)
+	table.Expire()
+	if got := backend.Blocked(); !reflect.DeepEqual(got, []string{"192.0.2.1/32", "192.0.2.2/32"}) {
+		t.Errorf("blocked = %v", got)
+	}
+	if got := backend.Limited(); len(got) != 0 {
+		t.Errorf("limited = %v", got)
+	}
+
+	now = now.Add(time.Minute)
+	table.Expire()
+	if got := backend.Blocked(); !reflect.DeepEqual(got, []string{"192.0.2.2/32"}) {
+		t.Errorf("blocked = %v", got)
+	}
+	if got := table.List(); len(got) != 1 || got[0].CIDR != "192.0.2.2/32" {
+		t.Errorf("entries = %+v", got)
+	}
+}
+
+func TestAggregatePrefixes(t *testing.T) {
+	got := aggregatePrefixes([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.5/32"),
+		netip.MustParsePrefix("10.0.0.0/24"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+		netip.MustParsePrefix("10.0.1.0/24"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+	})
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/24"),
+		netip.MustParsePrefix("10.0.1.0/24"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("aggregate = %v", got)
+	}
+}
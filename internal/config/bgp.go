@@ -0,0 +1,211 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	opsv1 "github/Beatrueman/ipblock-operator/api/v1"
+	"github/Beatrueman/ipblock-operator/internal/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// 重新宣告时列出 IPBlock 的超时
+const bgpRestoreTimeout = time.Minute
+
+// 进程内只运行一个 BGP speaker：配置和密码不变时复用，变化时停止旧 speaker 再启动新的。
+// 路由只存在于 speaker 中，新 speaker 启动后按现有的生效中 IPBlock 重新宣告。
+// 多副本部署时只有 leader 运行 speaker，其余副本只保存配置，当选后再启动
+var (
+	bgpMu      sync.Mutex
+	bgpKey     string
+	bgpSpeaker *engine.BGPAdapter
+	bgpWanted  *bgpDesired // 当前配置，引擎不使用 bgp 时为空
+	bgpLeader  bool
+)
+
+type bgpDesired struct {
+	key       string
+	cfg       engine.BGPConfig
+	passwords map[string]string
+	reader    client.Reader
+}
+
+func loadBGP(cm *corev1.ConfigMap, reader client.Reader) (engine.Adapter, error) {
+	var cfg engine.BGPConfig
+	if s := strings.TrimSpace(cm.Data["bgp"]); s != "" {
+		if err := yaml.Unmarshal([]byte(s), &cfg); err != nil {
+			return nil, fmt.Errorf("parse bgp failed: %w", err)
+		}
+	}
+	if err := engine.ValidateBGPConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	passwords := map[string]string{}
+	for _, n := range cfg.Neighbors {
+		if n.PasswordSecret == nil {
+			continue
+		}
+		password, err := ReadSecretKey(reader, *n.PasswordSecret, cm.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("read bgp password for %s failed: %w", n.Address, err)
+		}
+		passwords[n.Address] = password
+	}
+	data, err := json.Marshal(struct {
+		Config    engine.BGPConfig
+		Passwords map[string]string
+	}{cfg, passwords})
+	if err != nil {
+		return nil, err
+	}
+	key := string(data)
+
+	bgpMu.Lock()
+	defer bgpMu.Unlock()
+	bgpWanted = &bgpDesired{key: key, cfg: cfg, passwords: passwords, reader: reader}
+	if bgpLeader {
+		if err := startBGPLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return leaderBGP{}, nil
+}
+
+// 按 bgpWanted 启动 speaker，调用方需持有 bgpMu
+func startBGPLocked() error {
+	if bgpSpeaker != nil && bgpKey == bgpWanted.key {
+		return nil
+	}
+	// 监听端口和 router ID 不能同时被两个 speaker 使用，先停止旧的
+	stopBGPLocked()
+	speaker, err := engine.NewBGPAdapter(bgpWanted.cfg, bgpWanted.passwords)
+	if err != nil {
+		return err
+	}
+	bgpSpeaker, bgpKey = speaker, bgpWanted.key
+	go restoreBlackholes(speaker, bgpWanted.reader)
+	return nil
+}
+
+// 调用方需持有 bgpMu
+func stopBGPLocked() {
+	if bgpSpeaker != nil {
+		bgpSpeaker.Stop()
+		bgpSpeaker, bgpKey = nil, ""
+	}
+}
+
+// 引擎不再使用 bgp 时停止 speaker，撤回全部黑洞路由
+func releaseBGP() {
+	bgpMu.Lock()
+	defer bgpMu.Unlock()
+	bgpWanted = nil
+	stopBGPLocked()
+}
+
+// BGPLeaderRunnable 只在 leader 上运行：当选后按当前配置启动 BGP speaker，失去 leader 时停止。
+// 非 leader 副本不建立 BGP 会话，避免多个副本以同一 router ID 向上游宣告
+func BGPLeaderRunnable() manager.Runnable {
+	return bgpLeaderRunnable{}
+}
+
+type bgpLeaderRunnable struct{}
+
+func (bgpLeaderRunnable) Start(ctx context.Context) error {
+	bgpMu.Lock()
+	bgpLeader = true
+	if bgpWanted != nil {
+		if err := startBGPLocked(); err != nil {
+			logf.Log.WithName("bgp").Error(err, "当选 leader 后启动 BGP speaker 失败")
+		}
+	}
+	bgpMu.Unlock()
+
+	<-ctx.Done()
+	bgpMu.Lock()
+	bgpLeader = false
+	stopBGPLocked()
+	bgpMu.Unlock()
+	return nil
+}
+
+func (bgpLeaderRunnable) NeedLeaderElection() bool {
+	return true
+}
+
+var errBGPNotLeader = errors.New("bgp speaker only runs on the leader")
+
+// leaderBGP 转发到当前运行的 speaker，切换 speaker 后无需重新加载引擎
+type leaderBGP struct{}
+
+func currentBGP() *engine.BGPAdapter {
+	bgpMu.Lock()
+	defer bgpMu.Unlock()
+	return bgpSpeaker
+}
+
+func (leaderBGP) Ban(req engine.BanRequest) (string, error) {
+	speaker := currentBGP()
+	if speaker == nil {
+		return "", errBGPNotLeader
+	}
+	return speaker.Ban(req)
+}
+
+func (leaderBGP) UnBan(req engine.BanRequest) (string, error) {
+	speaker := currentBGP()
+	if speaker == nil {
+		return "", errBGPNotLeader
+	}
+	return speaker.UnBan(req)
+}
+
+// Health 非 leader 不运行 speaker，也不处理封禁，视为可达
+func (leaderBGP) Health(ctx context.Context) error {
+	speaker := currentBGP()
+	if speaker == nil {
+		return nil
+	}
+	return speaker.Health(ctx)
+}
+
+// 重新宣告生效中的 IPBlock，未带黑洞标签的由引擎自行跳过
+func restoreBlackholes(speaker *engine.BGPAdapter, reader client.Reader) {
+	logger := logf.Log.WithName("bgp")
+	ctx, cancel := context.WithTimeout(context.Background(), bgpRestoreTimeout)
+	defer cancel()
+
+	var list opsv1.IPBlockList
+	if err := reader.List(ctx, &list); err != nil {
+		logger.Error(err, "列出 IPBlock 失败，黑洞路由未重新宣告")
+		return
+	}
+	restored := 0
+	for _, ipblock := range list.Items {
+		if ipblock.Status.Phase != "active" || ipblock.Spec.Action == engine.ActionLogOnly {
+			continue
+		}
+		req := engine.BanRequest{
+			IP:     ipblock.Spec.IP,
+			Source: ipblock.Spec.Source,
+			Tags:   ipblock.Spec.Tags,
+			Action: ipblock.Spec.Action,
+		}
+		if _, err := speaker.Ban(req); err != nil {
+			logger.Error(err, "重新宣告黑洞路由失败", "ip", ipblock.Spec.IP)
+			continue
+		}
+		restored++
+	}
+	logger.Info("已重新宣告黑洞路由", "ipblocks", restored, "announced", len(speaker.Announced()))
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LoadAdapterFromConfigMap 根据 ConfigMap 的 engine 字段创建封禁适配器。
+// networkpolicy / cilium 引擎读取 networkPolicy 字段、istio 引擎读取 authorizationPolicy 字段、
+// nginx、cloudflare、awsWAF 引擎分别读取同名字段（YAML）作为配置，
+// xdp、iptables 引擎通过 gatewayHost 调用封禁后端，调用方式读取 gateway 字段；
+// bgp 引擎读取 bgp 字段，配置不变时复用已建立的 BGP 会话，切换到其他引擎后停止会话；
+// composite 引擎读取 composite 字段，按其中列出的引擎逐个创建后端；
+// 其他名称在 plugins 字段中查找同名的 gRPC 插件。
+// reader 用于直接读取 Secret 等凭据，避免为它们建立缓存
+func LoadAdapterFromConfigMap(cm *corev1.ConfigMap, gatewayHost string, c client.Client, reader client.Reader) (engine.Adapter, error) {
+	adapter, usesBGP, err := loadAdapter(cm, gatewayHost, c, reader)
+	if err == nil && !usesBGP {
+		releaseBGP()
+	}
+	return adapter, err
+}
+
+func loadAdapter(cm *corev1.ConfigMap, gatewayHost string, c client.Client, reader client.Reader) (engine.Adapter, bool, error) {
+	name := cm.Data["engine"]
+	if name != engine.CompositeEngine {
+		adapter, err := loadEngine(name, cm, gatewayHost, c, reader)
+		return adapter, name == engine.BGPEngine, err
+	}
+
+	var cfg engine.CompositeConfig
+	if s := strings.TrimSpace(cm.Data["composite"]); s != "" {
+		if err := yaml.Unmarshal([]byte(s), &cfg); err != nil {
+			return nil, false, fmt.Errorf("parse composite failed: %w", err)
+		}
+	}
+	usesBGP := false
+	backends := make([]engine.CompositeBackend, 0, len(cfg.Backends))
+	for _, b := range cfg.Backends {
+		if b.Engine == engine.CompositeEngine {
+			return nil, usesBGP, fmt.Errorf("composite engine cannot be nested")
+		}
+		adapter, err := loadEngine(b.Engine, cm, gatewayHost, c, reader)
+		if err != nil {
+			return nil, usesBGP, fmt.Errorf("load composite backend '%s' failed: %w", b.Engine, err)
+		}
+		usesBGP = usesBGP || b.Engine == engine.BGPEngine
+		backends = append(backends, engine.CompositeBackend{CompositeBackendConfig: b, Adapter: adapter})
+	}
+	adapter, err := engine.NewCompositeAdapter(cfg.Policy, backends)
+	return adapter, usesBGP, err
+}
+
+// 创建单个引擎
+func loadEngine(name string, cm *corev1.ConfigMap, gatewayHost string, c client.Client, reader client.Reader) (engine.Adapter, error) {
+	switch name {
+	case engine.NetworkPolicyEngine:
+		cfg, err := parsePolicyConfig(cm, "networkPolicy")
+		if err != nil {
+			return nil, err
+		}
+		return engine.NewNetworkPolicyAdapter(c, cfg)
+	case engine.CiliumEngine:
+		cfg, err := parsePolicyConfig(cm, "networkPolicy")
+		if err != nil {
+			return nil, err
+		}
+		return engine.NewCiliumAdapter(c, cfg), nil
+	case engine.IstioEngine:
+		cfg, err := parsePolicyConfig(cm, "authorizationPolicy")
+		if err != nil {
+			return nil, err
+		}
+		return engine.NewIstioAdapter(c, cfg), nil
+	case engine.NginxEngine:
+		var cfg engine.NginxConfig
+		if s := strings.TrimSpace(cm.Data["nginx"]); s != "" {
+			if err := yaml.Unmarshal([]byte(s), &cfg); err != nil {
+				return nil, fmt.Errorf("parse nginx failed: %w", err)
+			}
+		}
+		return engine.NewNginxAdapter(c, cfg)
+	case engine.CloudflareEngine:
+		var cfg engine.CloudflareConfig
+		if s := strings.TrimSpace(cm.Data["cloudflare"]); s != "" {
+			if err := yaml.Unmarshal([]byte(s), &cfg); err != nil {
+				return nil, fmt.Errorf("parse cloudflare failed: %w", err)
+			}
+		}
+		token, err := ReadSecretKey(reader, cfg.TokenSecret, cm.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("read cloudflare token failed: %w", err)
+		}
+		return engine.NewCloudflareAdapter(cfg, token)
+	case engine.AWSWAFEngine:
+		var cfg engine.AWSWAFConfig
+		if s := strings.TrimSpace(cm.Data["awsWAF"]); s != "" {
+			if err := yaml.Unmarshal([]byte(s), &cfg); err != nil {
+				return nil, fmt.Errorf("parse awsWAF failed: %w", err)
+			}
+		}
+		creds, err := loadAWSCredentials(reader, cfg.CredentialsSecret, cm.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("load aws credentials failed: %w", err)
+		}
+		return engine.NewAWSWAFAdapter(cfg, creds)
+	case engine.XDPEngine, engine.IptablesEngine:
+		client, err := loadGatewayClient(cm, gatewayHost, reader)
+		if err != nil {
+			return nil, err
+		}
+		return engine.NewGatewayAdapter(name, client)
+	case engine.BGPEngine:
+		return loadBGP(cm, reader)
+	default:
+		if cfg, ok, err := findPlugin(cm, name); err != nil {
+			return nil, err
+		} else if ok {
+			return loadPlugin(cfg, reader, cm.Namespace)
+		}
+		return engine.NewAdapter(name, gatewayHost)
+	}
+}
+
+// 网关客户端：按 gateway 字段选择协议，并从 Secret 读取 Token、CA 和客户端证书
+func loadGatewayClient(cm *corev1.ConfigMap, gatewayHost string, reader client.Reader) (*engine.GatewayClient, error) {
+	var cfg engine.GatewayConfig
+	if s := strings.TrimSpace(cm.Data["gateway"]); s != "" {
+		if err := yaml.Unmarshal([]byte(s), &cfg); err != nil {
+			return nil, fmt.Errorf("parse gateway failed: %w", err)
+		}
+	}
+
+	creds, err := loadCredentials(reader, cfg.TokenSecret, cfg.TLS, cm.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("gateway: %w", err)
+	}
+	return engine.NewGatewayClient(gatewayHost, cfg, creds)
+}
+
+// 从 Secret 读取 Bearer Token、CA 和客户端证书，网关和插件共用
+func loadCredentials(reader client.Reader, tokenRef *engine.SecretKeyRef, tlsConfig *engine.GatewayTLSConfig, namespace string) (engine.GatewayCredentials, error) {
+	var creds engine.GatewayCredentials
+	if tokenRef != nil {
+		token, err := ReadSecretKey(reader, *tokenRef, namespace)
+		if err != nil {
+			return creds, fmt.Errorf("read token failed: %w", err)
+		}
+		creds.Token = token
+	}
+	if tlsConfig == nil {
+		return creds, nil
+	}
+	if tlsConfig.CASecret != nil {
+		ref := *tlsConfig.CASecret
+		if ref.Key == "" {
+			ref.Key = "ca.crt"
+		}
+		ca, err := ReadSecretKey(reader, ref, namespace)
+		if err != nil {
+			return creds, fmt.Errorf("read CA failed: %w", err)
+		}
+		creds.CA = []byte(ca)
+	}
+	if tlsConfig.ClientCertSecret != nil {
+		cert, key, err := ReadTLSSecret(reader, *tlsConfig.ClientCertSecret, namespace)
+		if err != nil {
+			return creds, fmt.Errorf("read client certificate failed: %w", err)
+		}
+		creds.Cert, creds.Key = cert, key
+	}
+	return creds, nil
+}
+
+// ReadTLSSecret 读取 kubernetes.io/tls 类型 Secret 中的证书和私钥
+func ReadTLSSecret(reader client.Reader, ref engine.SecretRef, defaultNamespace string) ([]byte, []byte, error) {
+	secret, err := readSecret(reader, ref, defaultNamespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, key := secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]
+	if len(cert) == 0 || len(key) == 0 {
+		return nil, nil, fmt.Errorf("secret %s/%s must contain %s and %s", secret.Namespace, secret.Name, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+	return cert, key, nil
+}
+
+func parsePolicyConfig(cm *corev1.ConfigMap, key string) (engine.PolicyConfig, error) {
+	var cfg engine.PolicyConfig
+	if s := strings.TrimSpace(cm.Data[key]); s != "" {
+		if err := yaml.Unmarshal([]byte(s), &cfg); err != nil {
+			return cfg, fmt.Errorf("parse %s failed: %w", key, err)
+		}
+	}
+	return cfg, nil
+}
+
+// ReadSecretKey 读取 Secret 中的键值，ref 未指定命名空间时使用 defaultNamespace
+func ReadSecretKey(reader client.Reader, ref engine.SecretKeyRef, defaultNamespace string) (string, error) {
+	if ref.Key == "" {
+		return "", fmt.Errorf("secret key is required")
+	}
+	secret, err := readSecret(reader, ref.SecretRef, defaultNamespace)
+	if err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("key '%s' not found in secret %s/%s", ref.Key, secret.Namespace, secret.Name)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+func readSecret(reader client.Reader, ref engine.SecretRef, defaultNamespace string) (*corev1.Secret, error) {
+	if ref.Name == "" {
+		return nil, fmt.Errorf("secret name is required")
+	}
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var secret corev1.Secret
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// AWS 凭据：配置了 Secret 时从 Secret 读取，否则使用环境变量（静态密钥或 IRSA 的 Web Identity）
+func loadAWSCredentials(reader client.Reader, ref *engine.SecretRef, defaultNamespace string) (engine.AWSCredentials, error) {
+	if ref == nil {
+		creds := engine.AWSCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		// IRSA 注入的 Web Identity
+		if roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); roleARN != "" && tokenFile != "" {
+			creds.WebIdentity = &engine.AWSWebIdentity{
+				RoleARN:     roleARN,
+				TokenFile:   tokenFile,
+				SessionName: os.Getenv("AWS_ROLE_SESSION_NAME"),
+			}
+		}
+		return creds, nil
+	}
+	secret, err := readSecret(reader, *ref, defaultNamespace)
+	if err != nil {
+		return engine.AWSCredentials{}, err
+	}
+	return engine.AWSCredentials{
+		AccessKeyID:     strings.TrimSpace(string(secret.Data["AWS_ACCESS_KEY_ID"])),
+		SecretAccessKey: strings.TrimSpace(string(secret.Data["AWS_SECRET_ACCESS_KEY"])),
+		SessionToken:    strings.TrimSpace(string(secret.Data["AWS_SESSION_TOKEN"])),
+	}, nil
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/notify"
+	"github/Beatrueman/ipblock-operator/internal/notify/lark"
+	"github/Beatrueman/ipblock-operator/internal/notify/webhook"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// NotifierConfig 单个通知通道配置（ConfigMap 的 notifiers 字段）
+type NotifierConfig struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	WebhookURL string            `json:"webhookURL,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`    // webhook 类型附加的请求头
+	Templates  map[string]string `json:"templates,omitempty"`  // 事件类型 -> 模板路径，lark 缺省时使用 notifyTemplate_*；webhook 缺省时发送默认 JSON
+	Timezone   string            `json:"timezone,omitempty"`   // IANA 时区，缺省时使用 notifyTimezone
+	TimeFormat string            `json:"timeFormat,omitempty"` // Go 时间格式，缺省时使用 notifyTimeFormat
+	RateLimit  string            `json:"rateLimit,omitempty"`  // 限速，如 "5/s"、"100/m"，缺省时使用 notifyRateLimit
+	Aggregate  *AggregateConfig  `json:"aggregate,omitempty"`  // 封禁风暴聚合，缺省时使用 notifyAggregate
+}
+
+// AggregateConfig 通知聚合配置
+type AggregateConfig struct {
+	Window    string   `json:"window"`              // 聚合窗口，如 "1m"
+	Threshold int      `json:"threshold,omitempty"` // 每个窗口内单独发送的事件数
+	MaxListed int      `json:"maxListed,omitempty"` // 汇总中最多列出的 IP 数
+	Events    []string `json:"events,omitempty"`    // 参与聚合的事件类型，默认 ban
+}
+
+// LoadNotifierFromConfigMap 根据 ConfigMap 构建通知器。
+// 配置了 notifiers 时按多通道 + notifyRoutes 路由构建；
+// 否则兼容旧的 notifyType/notifyWebhookURL 单通道配置。
+// 未配置任何通知时返回 nil, nil。
+func LoadNotifierFromConfigMap(cm *corev1.ConfigMap) (notify.Notifier, error) {
+	templates := make(map[string]string)
+	for k, v := range cm.Data {
+		if strings.HasPrefix(k, "notifyTemplate_") {
+			eventType := strings.TrimPrefix(k, "notifyTemplate_")
+			templates[eventType] = v
+		}
+	}
+
+	var channelConfigs []NotifierConfig
+	if s := strings.TrimSpace(cm.Data["notifiers"]); s != "" {
+		if err := yaml.Unmarshal([]byte(s), &channelConfigs); err != nil {
+			return nil, fmt.Errorf("parse notifiers failed: %w", err)
+		}
+	} else if notifyType := cm.Data["notifyType"]; notifyType != "" {
+		channelConfigs = append(channelConfigs, NotifierConfig{
+			Name:       "default",
+			Type:       notifyType,
+			WebhookURL: cm.Data["notifyWebhookURL"],
+		})
+	}
+
+	if len(channelConfigs) == 0 {
+		return nil, nil
+	}
+
+	var defaultAggregate *AggregateConfig
+	if s := strings.TrimSpace(cm.Data["notifyAggregate"]); s != "" {
+		defaultAggregate = &AggregateConfig{}
+		if err := yaml.Unmarshal([]byte(s), defaultAggregate); err != nil {
+			return nil, fmt.Errorf("parse notifyAggregate failed: %w", err)
+		}
+	}
+
+	channels := make(map[string]notify.Notifier, len(channelConfigs))
+	for _, c := range channelConfigs {
+		if c.Name == "" {
+			return nil, fmt.Errorf("notifier of type '%s' has no name", c.Type)
+		}
+		if _, dup := channels[c.Name]; dup {
+			return nil, fmt.Errorf("duplicate notifier name '%s'", c.Name)
+		}
+		// notifyTemplate_* 是飞书卡片，通用 webhook 不继承
+		if len(c.Templates) == 0 && c.Type != "webhook" {
+			c.Templates = templates
+		}
+		if c.Timezone == "" {
+			c.Timezone = cm.Data["notifyTimezone"]
+		}
+		if c.TimeFormat == "" {
+			c.TimeFormat = cm.Data["notifyTimeFormat"]
+		}
+		if c.RateLimit == "" {
+			c.RateLimit = cm.Data["notifyRateLimit"]
+		}
+		if c.Aggregate == nil {
+			c.Aggregate = defaultAggregate
+		}
+
+		n, err := buildChannel(c)
+		if err != nil {
+			return nil, fmt.Errorf("notifier '%s': %w", c.Name, err)
+		}
+		channels[c.Name] = n
+	}
+
+	var routes []notify.Route
+	if s := strings.TrimSpace(cm.Data["notifyRoutes"]); s != "" {
+		if err := yaml.Unmarshal([]byte(s), &routes); err != nil {
+			return nil, fmt.Errorf("parse notifyRoutes failed: %w", err)
+		}
+	}
+
+	return notify.NewRouter(channels, routes)
+}
+
+// 构建单个通道：聚合 -> 限速 -> 时间格式化 -> 具体通知方式
+func buildChannel(c NotifierConfig) (notify.Notifier, error) {
+	n, err := newChannel(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每个通道按自己的时区和格式生成时间变量
+	if n, err = notify.NewTimeFormatter(n, c.Timezone, c.TimeFormat); err != nil {
+		return nil, err
+	}
+
+	if c.RateLimit != "" {
+		if n, err = notify.NewRateLimiter(n, c.RateLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Aggregate != nil {
+		// 未配置模板的 webhook 通道以默认 JSON 发送汇总
+		if _, ok := c.Templates[notify.EventSummary]; !ok && len(c.Templates) > 0 {
+			return nil, fmt.Errorf("aggregate requires a '%s' template", notify.EventSummary)
+		}
+		window, err := time.ParseDuration(c.Aggregate.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid aggregate window: %w", err)
+		}
+		n, err = notify.NewAggregator(n, c.Name, notify.AggregateOptions{
+			Window:    window,
+			Threshold: c.Aggregate.Threshold,
+			MaxListed: c.Aggregate.MaxListed,
+			Events:    c.Aggregate.Events,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+// 按类型创建通知通道
+func newChannel(c NotifierConfig) (notify.Notifier, error) {
+	switch c.Type {
+	case "lark":
+		if c.WebhookURL == "" {
+			return nil, fmt.Errorf("webhookURL is required")
+		}
+		if len(c.Templates) == 0 {
+			return nil, fmt.Errorf("no templates configured")
+		}
+		return lark.NewLarkNotify(c.WebhookURL, c.Templates)
+	case "webhook":
+		if c.WebhookURL == "" {
+			return nil, fmt.Errorf("webhookURL is required")
+		}
+		return webhook.NewWebhookNotify(c.WebhookURL, c.Headers, c.Templates)
+	// TODO 其他通知方式...
+	default:
+		return nil, fmt.Errorf("unknown notifier type '%s'", c.Type)
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+	"github/Beatrueman/ipblock-operator/internal/plugin"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// 旧连接延迟关闭，等待使用旧引擎的调用结束
+const pluginCloseDelay = time.Minute
+
+// ConfigMap 每次更新都会重新加载引擎，配置和凭据不变的插件复用已有连接
+var (
+	pluginMu      sync.Mutex
+	pluginClients = map[string]cachedPlugin{}
+)
+
+type cachedPlugin struct {
+	key    string
+	client *plugin.Client
+}
+
+// 在 plugins 字段中查找插件
+func findPlugin(cm *corev1.ConfigMap, name string) (plugin.Config, bool, error) {
+	s := strings.TrimSpace(cm.Data["plugins"])
+	if s == "" {
+		return plugin.Config{}, false, nil
+	}
+	var plugins []plugin.Config
+	if err := yaml.Unmarshal([]byte(s), &plugins); err != nil {
+		return plugin.Config{}, false, fmt.Errorf("parse plugins failed: %w", err)
+	}
+	for _, p := range plugins {
+		if p.Name == name {
+			if slices.Contains(engine.BuiltinEngines, name) {
+				return p, false, fmt.Errorf("plugin name '%s' conflicts with a built-in engine", name)
+			}
+			return p, true, nil
+		}
+	}
+	return plugin.Config{}, false, nil
+}
+
+func loadPlugin(cfg plugin.Config, reader client.Reader, namespace string) (engine.Adapter, error) {
+	creds, err := loadCredentials(reader, cfg.TokenSecret, cfg.TLS, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", cfg.Name, err)
+	}
+	data, err := json.Marshal(struct {
+		Config      plugin.Config
+		Credentials plugin.Credentials
+	}{cfg, creds})
+	if err != nil {
+		return nil, err
+	}
+	key := string(data)
+
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	cached, ok := pluginClients[cfg.Name]
+	if ok && cached.key == key {
+		return cached.client, nil
+	}
+	c, err := plugin.Dial(cfg, creds)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		time.AfterFunc(pluginCloseDelay, func() { _ = cached.client.Close() })
+	}
+	pluginClients[cfg.Name] = cachedPlugin{key: key, client: c}
+	return c, nil
+}
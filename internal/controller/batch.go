@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+)
+
+const defaultMaxBatchSize = 200
+
+var errNoAdapter = errors.New("adapter not initialized")
+
+// banBatcher 合并一个窗口内的封禁（或解封）请求，一次批量调用引擎，结果按请求分发给等待的调用方。
+// 攻击期间大量 IPBlock 同时处理时，可以把数百次网关调用合并为几次
+type banBatcher struct {
+	window  time.Duration
+	maxSize int // 攒满后立即提交，不再等待窗口结束
+	run     func(reqs []engine.BanRequest) []engine.BatchResult
+
+	mu      sync.Mutex
+	pending []pendingBan
+	timer   *time.Timer
+}
+
+type pendingBan struct {
+	req  engine.BanRequest
+	done chan engine.BatchResult
+}
+
+func newBanBatcher(window time.Duration, maxSize int, run func([]engine.BanRequest) []engine.BatchResult) *banBatcher {
+	if maxSize <= 0 {
+		maxSize = defaultMaxBatchSize
+	}
+	return &banBatcher{window: window, maxSize: maxSize, run: run}
+}
+
+// do 加入当前窗口并等待批量调用的结果
+func (b *banBatcher) do(req engine.BanRequest) engine.BatchResult {
+	done := make(chan engine.BatchResult, 1)
+
+	b.mu.Lock()
+	b.pending = append(b.pending, pendingBan{req: req, done: done})
+	if len(b.pending) >= b.maxSize {
+		batch := b.take()
+		b.mu.Unlock()
+		go b.flush(batch)
+	} else {
+		// 窗口内第一个请求启动计时器
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.window, func() {
+				b.mu.Lock()
+				batch := b.take()
+				b.mu.Unlock()
+				b.flush(batch)
+			})
+		}
+		b.mu.Unlock()
+	}
+	return <-done
+}
+
+// 取出当前窗口的请求，调用方需持有锁
+func (b *banBatcher) take() []pendingBan {
+	batch := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+func (b *banBatcher) flush(batch []pendingBan) {
+	if len(batch) == 0 {
+		return
+	}
+	reqs := make([]engine.BanRequest, len(batch))
+	for i, p := range batch {
+		reqs[i] = p.req
+	}
+	results := b.run(reqs)
+	for i, p := range batch {
+		if i < len(results) {
+			p.done <- results[i]
+		} else {
+			p.done <- engine.BatchResult{Err: fmt.Errorf("engine returned %d results for %d requests", len(results), len(reqs))}
+		}
+	}
+}
+
+func failAll(reqs []engine.BanRequest, err error) []engine.BatchResult {
+	results := make([]engine.BatchResult, len(reqs))
+	for i := range results {
+		results[i].Err = err
+	}
+	return results
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+)
+
+func TestBanBatcherCoalesces(t *testing.T) {
+	var mu sync.Mutex
+	var calls [][]engine.BanRequest
+	b := newBanBatcher(50*time.Millisecond, 0, func(reqs []engine.BanRequest) []engine.BatchResult {
+		mu.Lock()
+		calls = append(calls, reqs)
+		mu.Unlock()
+		results := make([]engine.BatchResult, len(reqs))
+		for i, req := range reqs {
+			results[i].Message = "banned " + req.IP
+			if req.IP == "192.0.2.3" {
+				results[i].Err = errors.New("rejected")
+			}
+		}
+		return results
+	})
+
+	ips := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"}
+	results := make([]engine.BatchResult, len(ips))
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			results[i] = b.do(engine.BanRequest{IP: ip})
+		}(i, ip)
+	}
+	wg.Wait()
+
+	if len(calls) != 1 || len(calls[0]) != len(ips) {
+		t.Fatalf("calls = %v, want one batch of %d", calls, len(ips))
+	}
+	// 每个调用方拿到自己的结果
+	for i, ip := range ips {
+		if results[i].Message != "banned "+ip {
+			t.Errorf("result %d = %+v", i, results[i])
+		}
+		if (results[i].Err != nil) != (ip == "192.0.2.3") {
+			t.Errorf("%s err = %v", ip, results[i].Err)
+		}
+	}
+}
+
+// 攒满 maxSize 后不等窗口结束立即提交
+func TestBanBatcherFlushesWhenFull(t *testing.T) {
+	b := newBanBatcher(time.Hour, 2, func(reqs []engine.BanRequest) []engine.BatchResult {
+		return make([]engine.BatchResult, len(reqs))
+	})
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b.do(engine.BanRequest{IP: "192.0.2.1"})
+			}()
+		}
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("full batches were not flushed")
+	}
+}
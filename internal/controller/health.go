@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	defaultHealthInterval = 15 * time.Second
+	defaultHealthTimeout  = 5 * time.Second
+)
+
+// BackendHealth 定期探测封禁引擎的后端是否可达。
+// 结果用于 ipblock_engine_backend_up 指标、IPBlock 的 BackendReachable 条件以及可选的 readyz 检查，
+// 后端不可达期间新的封禁排队等待
+type BackendHealth struct {
+	Adapter  func() engine.Adapter // 返回当前使用的引擎，为空时视为可达
+	Interval time.Duration         // 探测间隔，默认 15s
+	Timeout  time.Duration         // 单次探测超时，默认 5s
+	// 可达性变化时调用，由 Reconciler 注册以重新处理 IPBlock
+	OnChange func(ctx context.Context, reachable bool)
+
+	mu      sync.RWMutex
+	probed  bool
+	err     error
+	trigger chan struct{}
+}
+
+// NewBackendHealth interval 为 0 时使用默认间隔
+func NewBackendHealth(adapter func() engine.Adapter, interval time.Duration) *BackendHealth {
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+	return &BackendHealth{Adapter: adapter, Interval: interval, Timeout: defaultHealthTimeout, trigger: make(chan struct{}, 1)}
+}
+
+// Start 实现 manager.Runnable，启动后立即探测一次
+func (h *BackendHealth) Start(ctx context.Context) error {
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+	for {
+		h.Probe(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-h.trigger:
+		}
+	}
+}
+
+// NeedLeaderElection 所有副本都需要探测，指标和 readyz 才能反映各自的状态
+func (h *BackendHealth) NeedLeaderElection() bool {
+	return false
+}
+
+// Trigger 引擎切换后调用，立即重新探测
+func (h *BackendHealth) Trigger() {
+	select {
+	case h.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Probe 探测一次并记录结果，可达性变化时调用 OnChange
+func (h *BackendHealth) Probe(ctx context.Context) error {
+	var err error
+	if h.Adapter != nil {
+		if adapter := h.Adapter(); adapter != nil {
+			timeout := h.Timeout
+			if timeout <= 0 {
+				timeout = defaultHealthTimeout
+			}
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			err = engine.CheckHealth(probeCtx, adapter)
+			cancel()
+		}
+	}
+
+	h.mu.Lock()
+	wasReachable := h.err == nil
+	h.probed = true
+	h.err = err
+	h.mu.Unlock()
+
+	if err == nil {
+		backendUp.Set(1)
+	} else {
+		backendUp.Set(0)
+	}
+
+	if reachable := err == nil; reachable != wasReachable {
+		logger := logf.FromContext(ctx)
+		if reachable {
+			logger.Info("封禁后端已恢复")
+		} else {
+			logger.Error(err, "封禁后端不可达，新的封禁将排队等待")
+		}
+		if h.OnChange != nil {
+			h.OnChange(ctx, reachable)
+		}
+	}
+	return err
+}
+
+// Status 返回最近一次探测的结果，尚未探测时视为可达
+func (h *BackendHealth) Status() (reachable bool, err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.err == nil, h.err
+}
+
+// Check 用作 readyz 检查，尚未完成首次探测或后端不可达时返回错误
+func (h *BackendHealth) Check(_ *http.Request) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !h.probed {
+		return errors.New("engine backend not probed yet")
+	}
+	return h.err
+}
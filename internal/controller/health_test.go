@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+)
+
+// flakyAdapter 的健康状态由 down 决定
+type flakyAdapter struct {
+	mu   sync.Mutex
+	down bool
+}
+
+func (a *flakyAdapter) Ban(req engine.BanRequest) (string, error)   { return "banned", nil }
+func (a *flakyAdapter) UnBan(req engine.BanRequest) (string, error) { return "unbanned", nil }
+
+func (a *flakyAdapter) Health(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (a *flakyAdapter) setDown(down bool) {
+	a.mu.Lock()
+	a.down = down
+	a.mu.Unlock()
+}
+
+func TestBackendHealthTransitions(t *testing.T) {
+	adapter := &flakyAdapter{}
+	h := NewBackendHealth(func() engine.Adapter { return adapter }, 0)
+	var changes []bool
+	h.OnChange = func(ctx context.Context, reachable bool) { changes = append(changes, reachable) }
+
+	// 首次探测完成前 readyz 失败，但封禁不排队
+	if err := h.Check(nil); err == nil {
+		t.Error("readyz should fail before the first probe")
+	}
+	if reachable, _ := h.Status(); !reachable {
+		t.Error("backend should be treated as reachable before the first probe")
+	}
+
+	ctx := context.Background()
+	if err := h.Probe(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Check(nil); err != nil {
+		t.Errorf("readyz = %v", err)
+	}
+	if up := testutil.ToFloat64(backendUp); up != 1 {
+		t.Errorf("backend up = %v", up)
+	}
+
+	adapter.setDown(true)
+	for i := 0; i < 2; i++ {
+		h.Probe(ctx)
+	}
+	if reachable, err := h.Status(); reachable || err == nil {
+		t.Errorf("status = %v, %v", reachable, err)
+	}
+	if err := h.Check(nil); err == nil {
+		t.Error("readyz should fail while the backend is down")
+	}
+	if up := testutil.ToFloat64(backendUp); up != 0 {
+		t.Errorf("backend up = %v while the backend is down", up)
+	}
+
+	adapter.setDown(false)
+	h.Probe(ctx)
+	if len(changes) != 2 || changes[0] || !changes[1] {
+		t.Errorf("changes = %v, want [false true]", changes)
+	}
+}
+
+// 未配置引擎或引擎不支持健康检查时视为可达
+func TestBackendHealthWithoutChecker(t *testing.T) {
+	var adapter engine.Adapter
+	h := NewBackendHealth(func() engine.Adapter { return adapter }, 0)
+	if err := h.Probe(context.Background()); err != nil {
+		t.Errorf("nil adapter: %v", err)
+	}
+	adapter = &engine.NetworkPolicyAdapter{}
+	if err := h.Probe(context.Background()); err != nil {
+		t.Errorf("networkpolicy adapter: %v", err)
+	}
+}
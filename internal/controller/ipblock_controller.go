@@ -24,17 +24,25 @@ import (
 	"github/Beatrueman/ipblock-operator/internal/engine"
 	"github/Beatrueman/ipblock-operator/internal/notify"
 	"github/Beatrueman/ipblock-operator/internal/policy"
+	"github/Beatrueman/ipblock-operator/internal/utils"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	opsv1 "github/Beatrueman/ipblock-operator/api/v1"
 )
@@ -48,16 +56,29 @@ type IPBlockReconciler struct {
 	client.Client                      // 客户端通信
 	Scheme        *runtime.Scheme      // 序列化和反序列化
 	Recorder      record.EventRecorder // Event记录器
-	Adapter       engine.Adapter       // 封禁适配器接口
+	Adapter       engine.Adapter       // 封禁适配器接口，配置重新加载时替换，通过 CurrentAdapter 读取
 	AdapterName   string
 	GatewayHost   string
+	ClusterName   string // 集群名称，用于通知，配置重新加载时替换，通过 CurrentClusterName 读取
 	CmName        string
 	CmNamespace   string
 	Whitelist     *policy.Whitelist // ConfigMap读取
 	mu            sync.RWMutex      // 读写锁
-	Notifier      notify.Notifier   // 通知接口
+	NotifyQueue   *notify.Queue     // 通知队列
+	Health        *BackendHealth    // 后端健康检查，为空时不检查
+	// 合并封禁请求的窗口，窗口内的封禁（解封）一次批量调用引擎，为 0 时逐个调用
+	BatchWindow  time.Duration
+	MaxBatchSize int // 单次批量调用的最大请求数，默认 200
+	// 同时处理的 IPBlock 数，合并封禁需要多个 IPBlock 同时在窗口内等待。
+	// 由 --max-concurrent-reconciles 设置，默认 16；为 0 时使用 controller-runtime 的默认值 1
+	MaxConcurrentReconciles int
 	// 封禁计数器
 	BanCounter int64
+
+	healthEvents chan event.GenericEvent
+	requeueing   atomic.Bool
+	banBatch     *banBatcher
+	unbanBatch   *banBatcher
 }
 
 func (r *IPBlockReconciler) UpdateWhitelist(wl *policy.Whitelist) {
@@ -66,17 +87,57 @@ func (r *IPBlockReconciler) UpdateWhitelist(wl *policy.Whitelist) {
 	r.Whitelist = wl
 }
 
+// CurrentWhitelist 返回当前的白名单，供触发器在创建 IPBlock 前过滤
+func (r *IPBlockReconciler) CurrentWhitelist() *policy.Whitelist {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Whitelist
+}
+
+// UpdateAdapter 切换封禁引擎，ConfigMap 重新加载时调用
+func (r *IPBlockReconciler) UpdateAdapter(name string, adapter engine.Adapter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.AdapterName = name
+	r.Adapter = adapter
+}
+
+// CurrentAdapter 返回当前使用的引擎及其名称
+func (r *IPBlockReconciler) CurrentAdapter() (engine.Adapter, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Adapter, r.AdapterName
+}
+
 func (r *IPBlockReconciler) UpdateGatewayHost(newHost string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.GatewayHost = newHost
 }
 
-// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// UpdateClusterName 更新通知中使用的集群名称，ConfigMap 重新加载时调用
+func (r *IPBlockReconciler) UpdateClusterName(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ClusterName = name
+}
+
+// CurrentClusterName 返回当前的集群名称
+func (r *IPBlockReconciler) CurrentClusterName() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ClusterName
+}
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
 // +kubebuilder:rbac:groups=ops.yiiong.top,resources=ipblocks,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=ops.yiiong.top,resources=ipblocks/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=ops.yiiong.top,resources=ipblocks/finalizers,verbs=update
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups=cilium.io,resources=ciliumclusterwidenetworkpolicies,verbs=get;create;update
+// +kubebuilder:rbac:groups=security.istio.io,resources=authorizationpolicies,verbs=get;create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -104,6 +165,7 @@ func (r *IPBlockReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		ipblock.Status.Phase = "pending"
 		_ = r.Status().Update(ctx, &ipblock)
 	}
+	r.syncBackendCondition(ctx, &ipblock)
 
 	// ==== Step 1: 手动解封优先处理 ====
 	if ipblock.Spec.Unblock {
@@ -111,25 +173,18 @@ func (r *IPBlockReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			logger.V(LOG_LEVEL).Info("已手动解封，跳过重复处理", "ip", ip)
 			return ctrl.Result{}, nil
 		}
-		msg, err := r.Adapter.UnBan(ip)
+		msg, backends, err := r.unban(unbanRequest(&ipblock))
 		if err != nil {
 			logger.Error(err, "手动解封失败", "ip", ip)
 			r.UpdateIPBlockStatus(ctx, &ipblock, func(obj *opsv1.IPBlock) {
 				obj.Status.Result = "failed"
 				obj.Status.Message = "手动解封失败: " + err.Error()
+				obj.Status.Backends = backends
 			})
 			// 错误通知
-			if r.Notifier != nil {
-				go func() {
-					err := r.Notifier.Notify(ctx, "common", map[string]string{
-						"alarm_time": time.Now().UTC().Add(8 * time.Hour).Format("2006-01-02 15:04:05"),
-						"msg":        err.Error(),
-					})
-					if err != nil {
-						logf.Log.Error(err, "通知失败")
-					}
-				}()
-			}
+			r.notify(notify.EventCommon, r.notifyVars(&ipblock, notify.SeverityCritical, map[string]string{
+				notify.VarMsg: err.Error(),
+			}))
 		} else {
 			logger.Info("手动解封成功", "ip", ip)
 			r.Recorder.Event(&ipblock, corev1.EventTypeNormal, "ManualUnblock", "IP manually unblocked")
@@ -138,18 +193,11 @@ func (r *IPBlockReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 				obj.Status.Message = msg
 				obj.Status.UnblockedAt = time.Now().Format(time.RFC3339)
 				obj.Status.Phase = "expired"
+				obj.Status.Backends = backends
 			})
-			if r.Notifier != nil {
-				go func() {
-					err := r.Notifier.Notify(ctx, "resolve", map[string]string{
-						"alarm_time": time.Now().UTC().Add(8 * time.Hour).Format("2006-01-02 15:04:05"),
-						"ip":         ip,
-					})
-					if err != nil {
-						logf.Log.Error(err, "发送解封通知失败", "ip", ip)
-					}
-				}()
-			}
+			r.notify(notify.EventResolve, r.notifyVars(&ipblock, notify.SeverityInfo, map[string]string{
+				notify.VarResult: msg,
+			}))
 		}
 
 		// 先保存原始副本
@@ -226,43 +274,88 @@ func (r *IPBlockReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		banSeconds = int(dur.Seconds())
 	}
 
-	if r.Adapter == nil {
+	adapter, adapterName := r.CurrentAdapter()
+	if adapter == nil {
 		logger.Error(nil, "Adapter 未初始化，无法封禁 IP")
 		return ctrl.Result{}, nil
 	}
 
-	result, err := r.Adapter.Ban(ip, isPermanent, banSeconds)
+	// 后端不可达时不调用引擎，封禁排队等待，后端恢复后重新处理
+	if r.Health != nil {
+		if reachable, herr := r.Health.Status(); !reachable {
+			if ipblock.Status.Result != "queued" {
+				logger.Info("封禁后端不可达，封禁排队等待", "ip", ip, "engine", adapterName)
+				r.Recorder.Event(&ipblock, corev1.EventTypeWarning, "BanQueued", "Engine backend unreachable, ban queued: "+herr.Error())
+				r.UpdateIPBlockStatus(ctx, &ipblock, func(obj *opsv1.IPBlock) {
+					obj.Status.Phase = "pending"
+					obj.Status.Result = "queued"
+					obj.Status.Message = "后端不可达，恢复后自动封禁: " + herr.Error()
+				})
+			}
+			return ctrl.Result{RequeueAfter: r.Health.Interval}, nil
+		}
+	}
+
+	banReq := banRequest(&ipblock, isPermanent, banSeconds)
+	if err := engine.ValidateAction(adapter, banReq); err != nil {
+		// 引擎无法执行该动作，重试也不会成功，等待用户修改 Spec
+		logger.Error(err, "引擎不支持该处置动作", "ip", ip, "engine", adapterName, "action", ipblock.Spec.Action)
+		r.Recorder.Event(&ipblock, corev1.EventTypeWarning, "UnsupportedAction", err.Error())
+		r.UpdateIPBlockStatus(ctx, &ipblock, func(obj *opsv1.IPBlock) {
+			obj.Status.Phase = "failed"
+			obj.Status.Result = "failed"
+			obj.Status.Message = "不支持的处置动作: " + err.Error()
+			obj.Status.LastSpecHash = currentHash
+		})
+		return ctrl.Result{}, nil
+	}
+
+	// 已生效的封禁改为 log-only 时，先从引擎解封
+	if banReq.Action == engine.ActionLogOnly && ipblock.Status.Phase == "active" && ipblock.Status.Action != engine.ActionLogOnly {
+		if _, backends, err := r.unban(unbanRequest(&ipblock)); err != nil {
+			logger.Error(err, "切换为 log-only 时解封失败", "ip", ip)
+			r.UpdateIPBlockStatus(ctx, &ipblock, func(obj *opsv1.IPBlock) {
+				obj.Status.Result = "failed"
+				obj.Status.Message = "切换为 log-only 时解封失败: " + err.Error()
+				obj.Status.Backends = backends
+			})
+			return ctrl.Result{}, err
+		}
+		logger.Info("已解封，改为只记录", "ip", ip)
+	}
+
+	result, backends, err := r.ban(banReq)
 	if err != nil {
 		logger.Error(err, "封禁失败", "ip", ip)
 		r.UpdateIPBlockStatus(ctx, &ipblock, func(obj *opsv1.IPBlock) {
 			obj.Status.Result = "failed"
 			obj.Status.Message = err.Error()
 			obj.Status.Phase = "failed"
+			obj.Status.Backends = backends
 		})
 		// 错误通知
-		if r.Notifier != nil {
-			go func() {
-				err := r.Notifier.Notify(ctx, "common", map[string]string{
-					"alarm_time": time.Now().UTC().Add(8 * time.Hour).Format("2006-01-02 15:04:05"),
-					"msg":        err.Error(),
-				})
-				if err != nil {
-					logf.Log.Error(err, "通知失败")
-				}
-			}()
-		}
+		r.notify(notify.EventCommon, r.notifyVars(&ipblock, notify.SeverityCritical, map[string]string{
+			notify.VarMsg: err.Error(),
+		}))
 	} else {
 		logger.Info("封禁成功", "ip", ip)
 		r.Recorder.Event(&ipblock, corev1.EventTypeNormal, "BanSuccess", "IP ban succeeded")
 		newBanCount := ipblock.Status.BanCount + 1
+		blockedAt := time.Now()
+		expiresAt := ""
+		if !isPermanent {
+			expiresAt = blockedAt.Add(time.Duration(banSeconds) * time.Second).Format(time.RFC3339)
+		}
 
 		r.UpdateIPBlockStatus(ctx, &ipblock, func(obj *opsv1.IPBlock) {
 			obj.Status.Result = "success"
 			obj.Status.Message = result
-			obj.Status.BlockedAt = time.Now().Format(time.RFC3339)
+			obj.Status.BlockedAt = blockedAt.Format(time.RFC3339)
 			obj.Status.LastSpecHash = currentHash
 			obj.Status.Phase = "active"
 			obj.Status.BanCount = newBanCount
+			obj.Status.Backends = backends
+			obj.Status.Action = banReq.Action
 
 		})
 
@@ -281,21 +374,11 @@ func (r *IPBlockReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		// 	return count
 		// }
 
-		if r.Notifier != nil {
-			logger.Info("Notifier found, sending ban notification", "ip", ip)
-			err := r.Notifier.Notify(ctx, "ban", map[string]string{
-				"alarm_time": time.Now().UTC().Add(8 * time.Hour).Format("2006-01-02 15:04:05"),
-				"reason":     fmt.Sprintf("%s", ipblock.Spec.Reason),
-				"count":      fmt.Sprintf("%d", newBanCount),
-			})
-			if err != nil {
-				logger.Error(err, "发送封禁通知失败", "ip", ip)
-			} else {
-				logger.Info("发送封禁通知成功", "ip", ip)
-			}
-		} else {
-			logger.Info("Notifier is nil, skipping ban notification")
-		}
+		r.notify(notify.EventBan, r.notifyVars(&ipblock, notify.SeverityWarning, map[string]string{
+			notify.VarBanCount:  fmt.Sprintf("%d", newBanCount),
+			notify.VarResult:    result,
+			notify.VarExpiresAt: expiresAt,
+		}))
 
 		// 启动自动解封
 		if !isPermanent && ipblock.Status.UnblockedAt == "" {
@@ -306,6 +389,141 @@ func (r *IPBlockReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	return ctrl.Result{}, nil
 }
 
+// 同步 BackendReachable 条件，只在条件变化时写入状态
+func (r *IPBlockReconciler) syncBackendCondition(ctx context.Context, ipblock *opsv1.IPBlock) {
+	if r.Health == nil {
+		return
+	}
+	_, adapterName := r.CurrentAdapter()
+	cond := metav1.Condition{
+		Type:               opsv1.ConditionBackendReachable,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Reachable",
+		Message:            fmt.Sprintf("engine %s backend is reachable", adapterName),
+		ObservedGeneration: ipblock.Generation,
+	}
+	if reachable, err := r.Health.Status(); !reachable {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "Unreachable"
+		cond.Message = fmt.Sprintf("engine %s backend is unreachable: %v", adapterName, err)
+	}
+	if old := meta.FindStatusCondition(ipblock.Status.Conditions, cond.Type); old != nil &&
+		old.Status == cond.Status && old.Reason == cond.Reason && old.Message == cond.Message &&
+		old.ObservedGeneration == cond.ObservedGeneration {
+		return
+	}
+
+	latest, _ := r.UpdateIPBlockStatus(ctx, ipblock, func(obj *opsv1.IPBlock) {
+		meta.SetStatusCondition(&obj.Status.Conditions, cond)
+	})
+	// 使用最新版本继续处理，避免后续更新 Spec 时冲突
+	if latest.Name != "" {
+		*ipblock = *latest
+	}
+}
+
+// 后端可达性变化时重新处理所有 IPBlock，更新 BackendReachable 条件并下发排队中的封禁。
+// 同一时间只有一轮在进行，处理时读取的是最新的健康状态
+func (r *IPBlockReconciler) requeueAll(ctx context.Context, _ bool) {
+	if !r.requeueing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer r.requeueing.Store(false)
+		var list opsv1.IPBlockList
+		if err := r.List(ctx, &list); err != nil {
+			logf.FromContext(ctx).Error(err, "列出 IPBlock 失败，无法重新处理")
+			return
+		}
+		for i := range list.Items {
+			select {
+			case r.healthEvents <- event.GenericEvent{Object: &list.Items[i]}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// 由 IPBlock 构造引擎请求
+// 解封使用封禁生效时的动作：封禁后 Spec 改为 log-only 的，仍需从引擎解封
+func unbanRequest(ipblock *opsv1.IPBlock) engine.BanRequest {
+	req := banRequest(ipblock, false, 0)
+	if ipblock.Status.Phase == "active" {
+		req.Action = ipblock.Status.Action
+	}
+	return req
+}
+
+func banRequest(ipblock *opsv1.IPBlock, isPermanent bool, banSeconds int) engine.BanRequest {
+	req := engine.BanRequest{
+		IP:              ipblock.Spec.IP,
+		Permanent:       isPermanent,
+		DurationSeconds: banSeconds,
+		Source:          ipblock.Spec.Source,
+		Tags:            ipblock.Spec.Tags,
+		Action:          ipblock.Spec.Action,
+	}
+	if rl := ipblock.Spec.RateLimit; rl != nil {
+		req.RateLimit = &engine.RateLimit{PPS: rl.PPS, BPS: rl.BPS, RPS: rl.RPS}
+	}
+	return req
+}
+
+// 调用引擎封禁，组合引擎时同时返回每个后端的结果；log-only 只记录，不调用引擎
+func (r *IPBlockReconciler) ban(req engine.BanRequest) (string, []opsv1.BackendStatus, error) {
+	if req.Action == engine.ActionLogOnly {
+		return fmt.Sprintf("log-only: %s recorded, not enforced", req.IP), nil, nil
+	}
+	if r.banBatch != nil {
+		res := r.banBatch.do(req)
+		return res.Message, backendStatuses(res.Backends), res.Err
+	}
+	adapter, _ := r.CurrentAdapter()
+	if adapter == nil {
+		return "", nil, errNoAdapter
+	}
+	if multi, ok := adapter.(engine.MultiAdapter); ok {
+		msg, results, err := multi.BanEach(req)
+		return msg, backendStatuses(results), err
+	}
+	msg, err := adapter.Ban(req)
+	return msg, nil, err
+}
+
+func (r *IPBlockReconciler) unban(req engine.BanRequest) (string, []opsv1.BackendStatus, error) {
+	if req.Action == engine.ActionLogOnly {
+		return fmt.Sprintf("log-only: %s released", req.IP), nil, nil
+	}
+	if r.unbanBatch != nil {
+		res := r.unbanBatch.do(req)
+		return res.Message, backendStatuses(res.Backends), res.Err
+	}
+	adapter, _ := r.CurrentAdapter()
+	if adapter == nil {
+		return "", nil, errNoAdapter
+	}
+	if multi, ok := adapter.(engine.MultiAdapter); ok {
+		msg, results, err := multi.UnBanEach(req)
+		return msg, backendStatuses(results), err
+	}
+	msg, err := adapter.UnBan(req)
+	return msg, nil, err
+}
+
+func backendStatuses(results []engine.BackendResult) []opsv1.BackendStatus {
+	var statuses []opsv1.BackendStatus
+	for _, res := range results {
+		s := opsv1.BackendStatus{Engine: res.Engine, Result: "success", Message: res.Message}
+		if res.Err != nil {
+			s.Result = "failed"
+			s.Message = res.Err.Error()
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
 // 计算当前Spec的Hash
 func HashSpec(spec opsv1.IPBlockSpec) (string, error) {
 	b, err := json.Marshal(spec)
@@ -356,12 +574,13 @@ func (r *IPBlockReconciler) scheduleAutoUnblock(ipblock *opsv1.IPBlock) {
 	time.Sleep(d)
 
 	ctx := context.Background()
-	msg, err := r.Adapter.UnBan(ipblock.Spec.IP)
+	msg, backends, err := r.unban(unbanRequest(ipblock))
 
-	r.UpdateIPBlockStatus(ctx, ipblock, func(obj *opsv1.IPBlock) {
+	latest, _ := r.UpdateIPBlockStatus(ctx, ipblock, func(obj *opsv1.IPBlock) {
 		obj.Status.Phase = "expired"
 		obj.Status.Result = "unblocked"
 		obj.Status.UnblockedAt = time.Now().Format(time.RFC3339)
+		obj.Status.Backends = backends
 
 		ip := obj.Spec.IP
 		if err != nil {
@@ -369,44 +588,86 @@ func (r *IPBlockReconciler) scheduleAutoUnblock(ipblock *opsv1.IPBlock) {
 			obj.Status.Message = "解封失败: " + err.Error()
 			// 发送事件
 			r.Recorder.Event(obj, corev1.EventTypeWarning, "AutoUnblockFailed", obj.Status.Message)
-			// 错误通知
-			if r.Notifier != nil {
-				go func() {
-					err := r.Notifier.Notify(ctx, "common", map[string]string{
-						"alarm_time": time.Now().UTC().Add(8 * time.Hour).Format("2006-01-02 15:04:05"),
-						"msg":        err.Error(),
-					})
-					if err != nil {
-						logf.Log.Error(err, "通知失败")
-					}
-				}()
-			}
 		} else {
 			logf.Log.Info("自动解封成功", "ip", ip)
 			obj.Status.Message = msg
 			// 发送事件
 			r.Recorder.Event(obj, corev1.EventTypeNormal, "AutoUnblockSuccess", "IP 自动解封成功")
-			// 解封通知
-			if r.Notifier != nil {
-				go func() {
-					err := r.Notifier.Notify(ctx, "resolve", map[string]string{
-						"alarm_time": time.Now().UTC().Add(8 * time.Hour).Format("2006-01-02 15:04:05"),
-						"ip":         ip,
-					})
-					if err != nil {
-						logf.Log.Error(err, "发送解封通知失败", "ip", ip)
-					}
-				}()
-			}
-
 		}
 	})
+
+	// 通知放在状态更新之外，避免冲突重试时重复投递
+	if err != nil {
+		r.notify(notify.EventCommon, r.notifyVars(latest, notify.SeverityCritical, map[string]string{
+			notify.VarMsg: err.Error(),
+		}))
+	} else {
+		r.notify(notify.EventResolve, r.notifyVars(latest, notify.SeverityInfo, map[string]string{
+			notify.VarResult: msg,
+		}))
+	}
+}
+
+// 投递通知到通知队列，由队列负责发送和重试
+func (r *IPBlockReconciler) notify(eventType string, vars map[string]string) {
+	if r.NotifyQueue == nil {
+		return
+	}
+	r.NotifyQueue.Enqueue(eventType, vars)
+}
+
+// 构造通知模板变量：IPBlock 的基础信息，以及用于通知路由的来源、标签和严重程度，
+// vars 中的值优先
+func (r *IPBlockReconciler) notifyVars(ipblock *opsv1.IPBlock, severity string, vars map[string]string) map[string]string {
+	all := map[string]string{
+		notify.VarIP:        ipblock.Spec.IP,
+		notify.VarCIDR:      utils.NormalizeCIDR(ipblock.Spec.IP),
+		notify.VarReason:    ipblock.Spec.Reason,
+		notify.VarSource:    ipblock.Spec.Source,
+		notify.VarBy:        ipblock.Spec.By,
+		notify.VarDuration:  ipblock.Spec.Duration,
+		notify.VarTags:      notify.JoinTags(ipblock.Spec.Tags),
+		notify.VarBanCount:  fmt.Sprintf("%d", ipblock.Status.BanCount),
+		notify.VarCluster:   r.CurrentClusterName(),
+		notify.VarName:      ipblock.Name,
+		notify.VarNamespace: ipblock.Namespace,
+		notify.VarSeverity:  severity,
+		notify.VarTime:      time.Now().UTC().Format(time.RFC3339),
+	}
+	for k, v := range vars {
+		all[k] = v
+	}
+	return all
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *IPBlockReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if r.BatchWindow > 0 {
+		// 提交时使用当前的引擎，切换引擎后的批次发往新引擎
+		r.banBatch = newBanBatcher(r.BatchWindow, r.MaxBatchSize, func(reqs []engine.BanRequest) []engine.BatchResult {
+			adapter, _ := r.CurrentAdapter()
+			if adapter == nil {
+				return failAll(reqs, errNoAdapter)
+			}
+			return engine.BanBatch(adapter, reqs)
+		})
+		r.unbanBatch = newBanBatcher(r.BatchWindow, r.MaxBatchSize, func(reqs []engine.BanRequest) []engine.BatchResult {
+			adapter, _ := r.CurrentAdapter()
+			if adapter == nil {
+				return failAll(reqs, errNoAdapter)
+			}
+			return engine.UnBanBatch(adapter, reqs)
+		})
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&opsv1.IPBlock{}).
 		Named("ipblock").
-		Complete(r)
+		WithOptions(crcontroller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles})
+	if r.Health != nil {
+		r.healthEvents = make(chan event.GenericEvent)
+		r.Health.OnChange = r.requeueAll
+		b = b.WatchesRawSource(source.Channel(r.healthEvents, &handler.EnqueueRequestForObject{}))
+	}
+	return b.Complete(r)
 }
@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// 后端可达性默认只通过指标和 BackendReachable 条件暴露，readyz 检查需通过 --engine-backend-readyz 开启：
+// 触发器与 Operator 同在一个 Pod，未就绪会使 Service 摘除触发器端口，后端故障期间告警也无法进入
+var backendUp = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "ipblock_engine_backend_up",
+	Help: "Whether the engine backend was reachable at the last probe (1) or not (0).",
+})
+
+func init() {
+	metrics.Registry.MustRegister(backendUp)
+}
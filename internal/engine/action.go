@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 处置动作，对应 IPBlock 的 spec.action
+const (
+	ActionBlock     = "block"     // 丢弃来自该地址的流量
+	ActionRateLimit = "ratelimit" // 限速
+	ActionTarpit    = "tarpit"    // 接受连接但不响应，拖慢扫描器
+	ActionLogOnly   = "log-only"  // 只记录不执行，由控制器处理，所有引擎都支持
+)
+
+// 限速单位
+const (
+	RateLimitPPS = "pps" // 每秒包数
+	RateLimitBPS = "bps" // 每秒字节数
+	RateLimitRPS = "rps" // 每秒请求数（L7）
+)
+
+// RateLimit ratelimit 动作的限速参数，只需设置引擎支持的一种单位
+type RateLimit struct {
+	PPS int64
+	BPS int64
+	RPS int64
+}
+
+// Capabilities 引擎支持的处置动作
+type Capabilities struct {
+	// 支持的动作，第一个为 IPBlock 未指定动作时的默认动作
+	Actions []string
+	// ratelimit 动作接受的限速单位；为空表示限速参数由后端固定，不接受 IPBlock 指定
+	RateLimitUnits []string
+	// 未指定限速参数时使用后端的默认限速，否则 ratelimit 动作必须指定一种单位
+	DefaultRateLimit bool
+}
+
+// CapableAdapter 声明支持的处置动作，未实现此接口的引擎只支持 block
+type CapableAdapter interface {
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf 返回引擎支持的处置动作
+func CapabilitiesOf(a Adapter) Capabilities {
+	if c, ok := a.(CapableAdapter); ok {
+		return c.Capabilities()
+	}
+	return Capabilities{Actions: []string{ActionBlock}}
+}
+
+// ValidateAction 检查引擎能否执行请求中的动作和限速参数。
+// 组合引擎要求所有选中的后端都能执行
+func ValidateAction(a Adapter, req BanRequest) error {
+	switch req.Action {
+	case "", ActionBlock, ActionRateLimit, ActionTarpit:
+	case ActionLogOnly:
+		return nil
+	default:
+		return fmt.Errorf("unknown action '%s'", req.Action)
+	}
+
+	if c, ok := a.(*CompositeAdapter); ok {
+		selected := c.selectBackends(req)
+		if len(selected) == 0 {
+			return fmt.Errorf("no composite backend matches source '%s' and tags [%s]", req.Source, strings.Join(req.Tags, ","))
+		}
+		for _, b := range selected {
+			if err := ValidateAction(b.Adapter, req); err != nil {
+				return fmt.Errorf("backend %s: %w", b.Engine, err)
+			}
+		}
+		return nil
+	}
+
+	caps := CapabilitiesOf(a)
+	action := req.Action
+	if action == "" {
+		action = caps.Actions[0]
+	}
+	if !contains(caps.Actions, action) {
+		return fmt.Errorf("engine does not support action '%s', supported: %s", action, strings.Join(caps.Actions, ", "))
+	}
+
+	units := req.RateLimit.units()
+	if action != ActionRateLimit {
+		if len(units) > 0 {
+			return fmt.Errorf("rateLimit is only valid with action '%s'", ActionRateLimit)
+		}
+		return nil
+	}
+	if len(caps.RateLimitUnits) == 0 {
+		if len(units) > 0 {
+			return fmt.Errorf("engine uses a fixed rate limit and does not accept rateLimit parameters")
+		}
+		return nil
+	}
+	if len(units) == 0 && caps.DefaultRateLimit {
+		return nil
+	}
+	if len(units) != 1 {
+		return fmt.Errorf("action '%s' requires exactly one of: %s", ActionRateLimit, strings.Join(caps.RateLimitUnits, ", "))
+	}
+	if !contains(caps.RateLimitUnits, units[0]) {
+		return fmt.Errorf("engine does not support rate limit unit '%s', supported: %s", units[0], strings.Join(caps.RateLimitUnits, ", "))
+	}
+	return nil
+}
+
+// 返回已设置的限速单位
+func (r *RateLimit) units() []string {
+	if r == nil {
+		return nil
+	}
+	var units []string
+	if r.PPS > 0 {
+		units = append(units, RateLimitPPS)
+	}
+	if r.BPS > 0 {
+		units = append(units, RateLimitBPS)
+	}
+	if r.RPS > 0 {
+		units = append(units, RateLimitRPS)
+	}
+	return units
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
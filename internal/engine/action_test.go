@@ -0,0 +1,71 @@
+package engine
+
+import "testing"
+
+// pacingAdapter 支持 block 和按 pps / bps 限速
+type pacingAdapter struct{ recordingAdapter }
+
+func (p *pacingAdapter) Capabilities() Capabilities {
+	return Capabilities{
+		Actions:        []string{ActionBlock, ActionRateLimit, ActionTarpit},
+		RateLimitUnits: []string{RateLimitPPS, RateLimitBPS},
+	}
+}
+
+func TestValidateAction(t *testing.T) {
+	xdp := &XDPAdapter{}
+	iptables := &IptablesAdapter{}
+	pacing := &pacingAdapter{}
+
+	cases := []struct {
+		name    string
+		adapter Adapter
+		req     BanRequest
+		ok      bool
+	}{
+		{"default block", xdp, BanRequest{}, true},
+		{"explicit block", xdp, BanRequest{Action: ActionBlock}, true},
+		{"xdp cannot ratelimit", xdp, BanRequest{Action: ActionRateLimit}, false},
+		{"log-only everywhere", xdp, BanRequest{Action: ActionLogOnly}, true},
+		{"unknown action", xdp, BanRequest{Action: "quarantine"}, false},
+		{"iptables defaults to ratelimit", iptables, BanRequest{}, true},
+		{"iptables cannot block", iptables, BanRequest{Action: ActionBlock}, false},
+		{"iptables pps", iptables, BanRequest{Action: ActionRateLimit, RateLimit: &RateLimit{PPS: 100}}, true},
+		{"iptables rps unsupported", iptables, BanRequest{Action: ActionRateLimit, RateLimit: &RateLimit{RPS: 10}}, false},
+		{"pps", pacing, BanRequest{Action: ActionRateLimit, RateLimit: &RateLimit{PPS: 100}}, true},
+		{"rps unsupported", pacing, BanRequest{Action: ActionRateLimit, RateLimit: &RateLimit{RPS: 10}}, false},
+		{"unit required", pacing, BanRequest{Action: ActionRateLimit}, false},
+		{"single unit", pacing, BanRequest{Action: ActionRateLimit, RateLimit: &RateLimit{PPS: 1, BPS: 1}}, false},
+		{"rateLimit without ratelimit", pacing, BanRequest{Action: ActionTarpit, RateLimit: &RateLimit{PPS: 1}}, false},
+	}
+	for _, c := range cases {
+		err := ValidateAction(c.adapter, c.req)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: ok=%v, err=%v", c.name, c.ok, err)
+		}
+	}
+}
+
+func TestValidateActionComposite(t *testing.T) {
+	c, err := NewCompositeAdapter("", []CompositeBackend{
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: CloudflareEngine, Tags: []string{"edge"}}, Adapter: &recordingAdapter{}},
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: IptablesEngine, Tags: []string{"slow"}}, Adapter: &IptablesAdapter{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 每个后端使用自己的默认动作
+	if err := ValidateAction(c, BanRequest{Tags: []string{"edge", "slow"}}); err != nil {
+		t.Errorf("default actions: %v", err)
+	}
+	if err := ValidateAction(c, BanRequest{Action: ActionRateLimit, Tags: []string{"slow"}}); err != nil {
+		t.Errorf("ratelimit on iptables only: %v", err)
+	}
+	if err := ValidateAction(c, BanRequest{Action: ActionRateLimit, Tags: []string{"edge", "slow"}}); err == nil {
+		t.Error("expected error: cloudflare backend cannot ratelimit")
+	}
+	if err := ValidateAction(c, BanRequest{Tags: []string{"other"}}); err == nil {
+		t.Error("expected error when no backend matches")
+	}
+}
@@ -1,18 +1,70 @@
 package engine
 
+import "fmt"
+
+// 引擎名称，对应 ConfigMap 的 engine 字段
+const (
+	XDPEngine           = "xdp"
+	IptablesEngine      = "iptables"
+	NetworkPolicyEngine = "networkpolicy"
+	CiliumEngine        = "cilium"
+	IstioEngine         = "istio"
+	NginxEngine         = "nginx"
+	CloudflareEngine    = "cloudflare"
+	AWSWAFEngine        = "awswaf"
+	BGPEngine           = "bgp"
+	CompositeEngine     = "composite"
+)
+
+// BuiltinEngines 内置引擎名称，插件不能使用这些名称
+var BuiltinEngines = []string{
+	XDPEngine, IptablesEngine, NetworkPolicyEngine, CiliumEngine, IstioEngine,
+	NginxEngine, CloudflareEngine, AWSWAFEngine, BGPEngine, CompositeEngine,
+}
+
+// BanRequest 一次封禁或解封请求。Source、Tags 取自 IPBlock，组合引擎据此选择后端
+type BanRequest struct {
+	IP              string
+	Permanent       bool
+	DurationSeconds int
+	Source          string
+	Tags            []string
+	Action          string     // 处置动作，为空时使用引擎的默认动作
+	RateLimit       *RateLimit // Action 为 ratelimit 时的限速参数
+}
+
 type Adapter interface {
-	Ban(ip string, isParmanent bool, durationSeconds int) (string, error)
-	UnBan(ip string) (string, error)
+	Ban(req BanRequest) (string, error)
+	UnBan(req BanRequest) (string, error)
 }
 
-func NewAdapter(name, gatewayHost string) Adapter {
+// BackendResult 组合引擎中单个后端的执行结果
+type BackendResult struct {
+	Engine  string
+	Message string
+	Err     error
+}
+
+// MultiAdapter 由组合引擎实现，返回每个后端的执行结果
+type MultiAdapter interface {
+	Adapter
+	BanEach(req BanRequest) (string, []BackendResult, error)
+	UnBanEach(req BanRequest) (string, []BackendResult, error)
+}
+
+// NewAdapter 创建按旧协议通过 gatewayHost 调用封禁后端的引擎，未知名称返回错误
+func NewAdapter(name, gatewayHost string) (Adapter, error) {
+	return NewGatewayAdapter(name, &GatewayClient{Host: gatewayHost})
+}
+
+// NewGatewayAdapter 创建通过网关客户端调用封禁后端的引擎
+func NewGatewayAdapter(name string, client *GatewayClient) (Adapter, error) {
 	switch name {
-	case "xdp":
-		return &XDPAdapter{GatewayHost: gatewayHost}
-	case "iptables":
-		return &IptablesAdapter{GatewayHost: gatewayHost}
+	case XDPEngine:
+		return &XDPAdapter{GatewayHost: client.Host, Client: client}, nil
+	case IptablesEngine:
+		return &IptablesAdapter{GatewayHost: client.Host, Client: client}, nil
 	default:
-
-		return &XDPAdapter{GatewayHost: gatewayHost}
+		return nil, fmt.Errorf("unknown engine '%s'", name)
 	}
 }
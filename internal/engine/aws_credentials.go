@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	stsAPIVersion = "2011-06-15"
+	// 临时凭据在过期前提前刷新
+	awsCredentialRefreshBefore = 5 * time.Minute
+)
+
+// AWSWebIdentity 通过 STS AssumeRoleWithWebIdentity 换取临时凭据，
+// 对应 IRSA 注入的 AWS_ROLE_ARN、AWS_WEB_IDENTITY_TOKEN_FILE 环境变量
+type AWSWebIdentity struct {
+	RoleARN     string
+	TokenFile   string // 每次换取凭据时重新读取，kubelet 会定期轮换
+	SessionName string // 默认 ipblock-operator
+	STSEndpoint string // 默认 https://sts.<region>.amazonaws.com
+}
+
+// awsCredentialProvider 返回签名使用的凭据：配置了静态密钥时直接使用，
+// 否则用 Web Identity 换取临时凭据并缓存到过期前
+type awsCredentialProvider struct {
+	static      AWSCredentials
+	webIdentity *AWSWebIdentity
+	httpClient  *http.Client
+	now         func() time.Time
+
+	mu      sync.Mutex
+	cached  AWSCredentials
+	expires time.Time
+}
+
+func (p *awsCredentialProvider) get(ctx context.Context) (AWSCredentials, error) {
+	if p.webIdentity == nil {
+		return p.static, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cached.AccessKeyID != "" && p.now().Add(awsCredentialRefreshBefore).Before(p.expires) {
+		return p.cached, nil
+	}
+	creds, expires, err := p.assumeRole(ctx)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	p.cached, p.expires = creds, expires
+	return creds, nil
+}
+
+type stsCredentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+// AssumeRoleWithWebIdentity 无需签名，Token 本身即为凭证
+func (p *awsCredentialProvider) assumeRole(ctx context.Context) (AWSCredentials, time.Time, error) {
+	id := p.webIdentity
+	token, err := os.ReadFile(id.TokenFile)
+	if err != nil {
+		return AWSCredentials{}, time.Time{}, fmt.Errorf("read web identity token: %w", err)
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {stsAPIVersion},
+		"RoleArn":          {id.RoleARN},
+		"RoleSessionName":  {id.SessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, id.STSEndpoint+"/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return AWSCredentials{}, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return AWSCredentials{}, time.Time{}, fmt.Errorf("call sts AssumeRoleWithWebIdentity failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return AWSCredentials{}, time.Time{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		_ = xml.Unmarshal(data, &e)
+		if e.Code == "" {
+			e.Code = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		}
+		return AWSCredentials{}, time.Time{}, fmt.Errorf("sts %s: %s", e.Code, e.Message)
+	}
+
+	var out struct {
+		Credentials stsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(data, &out); err != nil {
+		return AWSCredentials{}, time.Time{}, fmt.Errorf("decode sts response: %w", err)
+	}
+	c := out.Credentials
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return AWSCredentials{}, time.Time{}, fmt.Errorf("sts returned no credentials")
+	}
+	return AWSCredentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+	}, c.Expiration, nil
+}
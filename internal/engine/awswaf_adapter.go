@@ -0,0 +1,342 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	wafTargetPrefix = "AWSWAF_20190729."
+	wafMaxAddresses = 10000 // 单个 IPSet 的地址数上限
+	wafLockRetries  = 5
+	wafTimeout      = time.Minute
+)
+
+// WAFIPSetRef 引用一个 WAFv2 IPSet
+type WAFIPSetRef struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// AWSWAFConfig AWS WAFv2 引擎配置（ConfigMap 的 awsWAF 字段）
+type AWSWAFConfig struct {
+	Region   string `json:"region"`
+	Scope    string `json:"scope,omitempty"`    // REGIONAL（默认，ALB/API Gateway）或 CLOUDFRONT（region 必须为 us-east-1）
+	Endpoint string `json:"endpoint,omitempty"` // API 地址，默认 https://wafv2.<region>.amazonaws.com，测试时可指向本地 mock
+
+	IPv4Set *WAFIPSetRef `json:"ipv4Set,omitempty"` // IPv4 地址写入的 IPSet
+	IPv6Set *WAFIPSetRef `json:"ipv6Set,omitempty"` // IPv6 地址写入的 IPSet
+
+	BatchInterval string `json:"batchInterval,omitempty"` // 合并窗口，窗口内的变更合并为一次 UpdateIPSet，默认 5s
+
+	// 凭据所在 Secret，需包含 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY，可选 AWS_SESSION_TOKEN；
+	// 为空时使用 Operator 的同名环境变量，都没有时使用 IRSA 注入的 AWS_ROLE_ARN、AWS_WEB_IDENTITY_TOKEN_FILE
+	CredentialsSecret *SecretRef `json:"credentialsSecret,omitempty"`
+}
+
+// AWSWAFAdapter 把封禁写入 WAFv2 IPSet，IPv4 / IPv6 分别写入不同的 IPSet。
+// 变更按窗口合并后批量提交，使用 GetIPSet 返回的 LockToken 做乐观并发控制，
+// 锁冲突时重新读取并重试
+type AWSWAFAdapter struct {
+	Config      AWSWAFConfig
+	Credentials AWSCredentials
+	HTTPClient  *http.Client
+
+	credentials *awsCredentialProvider
+	batch       *batcher
+}
+
+// WAFError WAFv2 API 返回的错误
+type WAFError struct {
+	Type    string
+	Message string
+}
+
+func (e *WAFError) Error() string {
+	return fmt.Sprintf("wafv2 %s: %s", e.Type, e.Message)
+}
+
+type wafIPSet struct {
+	Name        string   `json:"Name"`
+	Id          string   `json:"Id"`
+	Description string   `json:"Description,omitempty"`
+	Addresses   []string `json:"Addresses"`
+}
+
+// NewAWSWAFAdapter 创建 AWS WAFv2 引擎
+func NewAWSWAFAdapter(cfg AWSWAFConfig, creds AWSCredentials) (*AWSWAFAdapter, error) {
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("aws waf region is required")
+	}
+	switch cfg.Scope {
+	case "":
+		cfg.Scope = "REGIONAL"
+	case "REGIONAL":
+	case "CLOUDFRONT":
+		if cfg.Region != "us-east-1" {
+			return nil, fmt.Errorf("CLOUDFRONT scope requires region us-east-1")
+		}
+	default:
+		return nil, fmt.Errorf("unknown aws waf scope '%s'", cfg.Scope)
+	}
+	if cfg.IPv4Set == nil && cfg.IPv6Set == nil {
+		return nil, fmt.Errorf("at least one of ipv4Set and ipv6Set is required")
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		if creds.WebIdentity == nil {
+			return nil, fmt.Errorf("aws credentials are required")
+		}
+		if creds.WebIdentity.RoleARN == "" || creds.WebIdentity.TokenFile == "" {
+			return nil, fmt.Errorf("aws web identity requires role arn and token file")
+		}
+	} else {
+		// 静态密钥优先
+		creds.WebIdentity = nil
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://wafv2." + cfg.Region + ".amazonaws.com"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+
+	interval := 5 * time.Second
+	if cfg.BatchInterval != "" {
+		d, err := time.ParseDuration(cfg.BatchInterval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid aws waf batchInterval '%s'", cfg.BatchInterval)
+		}
+		interval = d
+	}
+
+	w := &AWSWAFAdapter{
+		Config:      cfg,
+		Credentials: creds,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+	w.credentials = &awsCredentialProvider{static: creds, httpClient: w.HTTPClient, now: time.Now}
+	if id := creds.WebIdentity; id != nil {
+		webIdentity := *id
+		if webIdentity.SessionName == "" {
+			webIdentity.SessionName = "ipblock-operator"
+		}
+		if webIdentity.STSEndpoint == "" {
+			webIdentity.STSEndpoint = "https://sts." + cfg.Region + ".amazonaws.com"
+		}
+		webIdentity.STSEndpoint = strings.TrimRight(webIdentity.STSEndpoint, "/")
+		w.credentials.webIdentity = &webIdentity
+	}
+	w.batch = newBatcher("awswaf-engine", interval, w.apply)
+	return w, nil
+}
+
+// Ban 等待合并窗口结束、批量写入 IPSet 后返回，写入失败时返回错误
+func (w *AWSWAFAdapter) Ban(req BanRequest) (string, error) {
+	cidr, set, err := w.target(req.IP)
+	if err != nil {
+		return "", err
+	}
+	if err := w.batch.do(cidr, true); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s added to WAF IPSet %s", cidr, set.Name), nil
+}
+
+func (w *AWSWAFAdapter) UnBan(req BanRequest) (string, error) {
+	cidr, set, err := w.target(req.IP)
+	if err != nil {
+		return "", err
+	}
+	if err := w.batch.do(cidr, false); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s removed from WAF IPSet %s", cidr, set.Name), nil
+}
+
+// 按地址族选择 IPSet
+func (w *AWSWAFAdapter) target(ip string) (string, *WAFIPSetRef, error) {
+	cidr, err := blockCIDR(ip)
+	if err != nil {
+		return "", nil, err
+	}
+	set := w.Config.IPv4Set
+	if strings.Contains(cidr, ":") {
+		set = w.Config.IPv6Set
+	}
+	if set == nil {
+		return "", nil, fmt.Errorf("no WAF IPSet configured for '%s'", cidr)
+	}
+	return cidr, set, nil
+}
+
+// 按地址族拆分本批次变更，每个 IPSet 一次 UpdateIPSet，失败只影响对应地址族的变更
+func (w *AWSWAFAdapter) apply(ops map[string]bool) (map[string]error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wafTimeout)
+	defer cancel()
+
+	v4 := make(map[string]bool)
+	v6 := make(map[string]bool)
+	for cidr, ban := range ops {
+		if strings.Contains(cidr, ":") {
+			v6[cidr] = ban
+		} else {
+			v4[cidr] = ban
+		}
+	}
+
+	failed := make(map[string]error)
+	for _, family := range []struct {
+		ref *WAFIPSetRef
+		ops map[string]bool
+	}{{w.Config.IPv4Set, v4}, {w.Config.IPv6Set, v6}} {
+		if len(family.ops) == 0 {
+			continue
+		}
+		rejected, err := w.updateIPSet(ctx, family.ref, family.ops)
+		for cidr := range family.ops {
+			if err != nil {
+				failed[cidr] = err
+			} else if rejected[cidr] != nil {
+				failed[cidr] = rejected[cidr]
+			}
+		}
+	}
+	return failed, nil
+}
+
+// updateIPSet 写入一个 IPSet。先处理解封再处理封禁，超出地址数上限的新增封禁单独返回错误，
+// 其余变更照常写入；err 不为空时整批未写入
+func (w *AWSWAFAdapter) updateIPSet(ctx context.Context, ref *WAFIPSetRef, ops map[string]bool) (map[string]error, error) {
+	bans := make([]string, 0, len(ops))
+	for cidr, ban := range ops {
+		if ban {
+			bans = append(bans, cidr)
+		}
+	}
+	sort.Strings(bans)
+
+	for attempt := 1; ; attempt++ {
+		var got struct {
+			IPSet     wafIPSet `json:"IPSet"`
+			LockToken string   `json:"LockToken"`
+		}
+		if err := w.call(ctx, "GetIPSet", w.ipSetKey(ref), &got); err != nil {
+			return nil, err
+		}
+
+		// WAF 返回的地址格式可能与提交时不同，按规范化后的前缀比较
+		addresses := make(map[netip.Prefix]string, len(got.IPSet.Addresses))
+		for _, a := range got.IPSet.Addresses {
+			if p, err := netip.ParsePrefix(a); err == nil {
+				addresses[p.Masked()] = a
+			}
+		}
+		for cidr, ban := range ops {
+			if !ban {
+				delete(addresses, netip.MustParsePrefix(cidr))
+			}
+		}
+		rejected := make(map[string]error)
+		for _, cidr := range bans {
+			p := netip.MustParsePrefix(cidr)
+			if _, ok := addresses[p]; ok {
+				continue
+			}
+			if len(addresses) >= wafMaxAddresses {
+				rejected[cidr] = fmt.Errorf("WAF IPSet %s is full (%d addresses)", ref.Name, wafMaxAddresses)
+				continue
+			}
+			addresses[p] = cidr
+		}
+
+		list := make([]string, 0, len(addresses))
+		for _, a := range addresses {
+			list = append(list, a)
+		}
+		sort.Strings(list)
+
+		update := w.ipSetKey(ref)
+		update["Addresses"] = list
+		update["LockToken"] = got.LockToken
+		if got.IPSet.Description != "" {
+			update["Description"] = got.IPSet.Description
+		}
+
+		err := w.call(ctx, "UpdateIPSet", update, nil)
+		var wafErr *WAFError
+		if errors.As(err, &wafErr) && wafErr.Type == "WAFOptimisticLockException" && attempt < wafLockRetries {
+			// 其他客户端已修改 IPSet，重新读取后重试
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return rejected, nil
+	}
+}
+
+func (w *AWSWAFAdapter) ipSetKey(ref *WAFIPSetRef) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":  ref.Name,
+		"Id":    ref.ID,
+		"Scope": w.Config.Scope,
+	}
+}
+
+// 调用 WAFv2 JSON API
+func (w *AWSWAFAdapter) call(ctx context.Context, action string, in, out interface{}) error {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Config.Endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", wafTargetPrefix+action)
+	creds, err := w.credentials.get(ctx)
+	if err != nil {
+		return err
+	}
+	signV4(req, payload, creds, w.Config.Region, "wafv2", time.Now())
+
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("call wafv2 %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &e)
+		// __type 可能带有命名空间前缀，如 "com.amazonaws...#WAFOptimisticLockException"
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		if e.Type == "" {
+			e.Type = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		}
+		return &WAFError{Type: e.Type, Message: e.Message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
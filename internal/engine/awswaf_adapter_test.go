@@ -0,0 +1,280 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// wafStub 模拟 WAFv2 的 GetIPSet / UpdateIPSet
+type wafStub struct {
+	mu       sync.Mutex
+	sets     map[string][]string // Id -> Addresses
+	lock     map[string]int      // Id -> 当前 LockToken
+	conflict int                 // 剩余需要返回锁冲突的 UpdateIPSet 次数
+	updates  map[string]int      // Id -> 成功的 UpdateIPSet 次数
+}
+
+func newWAFStub() *wafStub {
+	return &wafStub{
+		sets:    map[string][]string{"v4": {"192.0.2.1/32"}, "v6": {}},
+		lock:    map[string]int{"v4": 1, "v6": 1},
+		updates: map[string]int{},
+	}
+}
+
+func (s *wafStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fail := func(typ, msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"__type": "com.amazonaws.wafv2#" + typ, "message": msg})
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(r.Header.Get("Authorization"), "/us-east-1/wafv2/aws4_request") {
+		fail("WAFInvalidPermissionException", "bad signature")
+		return
+	}
+
+	var in struct {
+		Id        string
+		Scope     string
+		LockToken string
+		Addresses []string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Scope != "REGIONAL" {
+		fail("WAFInvalidParameterException", "bad request")
+		return
+	}
+	if _, ok := s.sets[in.Id]; !ok {
+		fail("WAFNonexistentItemException", "no such ip set")
+		return
+	}
+
+	switch r.Header.Get("X-Amz-Target") {
+	case wafTargetPrefix + "GetIPSet":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"IPSet":     map[string]interface{}{"Name": in.Id, "Id": in.Id, "Addresses": s.sets[in.Id]},
+			"LockToken": strconv.Itoa(s.lock[in.Id]),
+		})
+	case wafTargetPrefix + "UpdateIPSet":
+		if s.conflict > 0 {
+			// 模拟其他客户端在读写之间修改了 IPSet
+			s.conflict--
+			s.lock[in.Id]++
+			fail("WAFOptimisticLockException", "lock token is stale")
+			return
+		}
+		if in.LockToken != strconv.Itoa(s.lock[in.Id]) {
+			fail("WAFOptimisticLockException", "lock token is stale")
+			return
+		}
+		s.sets[in.Id] = in.Addresses
+		s.lock[in.Id]++
+		s.updates[in.Id]++
+		_ = json.NewEncoder(w).Encode(map[string]string{"NextLockToken": strconv.Itoa(s.lock[in.Id])})
+	default:
+		fail("UnknownOperationException", "unknown target")
+	}
+}
+
+func newTestWAFAdapter(t *testing.T, stub *wafStub) *AWSWAFAdapter {
+	t.Helper()
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	adapter, err := NewAWSWAFAdapter(AWSWAFConfig{
+		Region:        "us-east-1",
+		Endpoint:      srv.URL,
+		IPv4Set:       &WAFIPSetRef{Name: "v4", ID: "v4"},
+		IPv6Set:       &WAFIPSetRef{Name: "v6", ID: "v6"},
+		BatchInterval: "1h", // 测试中手动 flush
+	}, AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return adapter
+}
+
+func TestAWSWAFAdapterBatchesByFamily(t *testing.T) {
+	stub := newWAFStub()
+	adapter := newTestWAFAdapter(t, stub)
+
+	errs := flushAfter(t, adapter.batch,
+		banOp(adapter, "10.0.0.1"), banOp(adapter, "10.0.1.0/24"), banOp(adapter, "2001:db8::1"),
+		unbanOp(adapter, "192.0.2.1"))
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := strings.Join(stub.sets["v4"], ","); got != "10.0.0.1/32,10.0.1.0/24" {
+		t.Errorf("v4 addresses = %s", got)
+	}
+	if got := strings.Join(stub.sets["v6"], ","); got != "2001:db8::1/128" {
+		t.Errorf("v6 addresses = %s", got)
+	}
+	if stub.updates["v4"] != 1 || stub.updates["v6"] != 1 {
+		t.Errorf("expected one UpdateIPSet per set, got %v", stub.updates)
+	}
+}
+
+func TestAWSWAFAdapterRetriesOnStaleLockToken(t *testing.T) {
+	stub := newWAFStub()
+	stub.conflict = 2
+	adapter := newTestWAFAdapter(t, stub)
+
+	if err := flushAfter(t, adapter.batch, banOp(adapter, "10.0.0.1"))[0]; err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(stub.sets["v4"], ","); got != "10.0.0.1/32,192.0.2.1/32" {
+		t.Errorf("v4 addresses = %s", got)
+	}
+	if stub.conflict != 0 || stub.updates["v4"] != 1 {
+		t.Errorf("expected update to succeed after retries, conflict=%d updates=%v", stub.conflict, stub.updates)
+	}
+}
+
+func TestAWSWAFAdapterReportsFailedBatch(t *testing.T) {
+	stub := newWAFStub()
+	stub.conflict = wafLockRetries
+	adapter := newTestWAFAdapter(t, stub)
+
+	// IPv4 集合冲突重试耗尽，只有 IPv4 的封禁失败
+	errs := flushAfter(t, adapter.batch, banOp(adapter, "10.0.0.1"), banOp(adapter, "2001:db8::1"))
+	if errs[0] == nil || stub.updates["v4"] != 0 {
+		t.Fatalf("expected v4 update to fail after %d conflicts, err=%v", wafLockRetries, errs[0])
+	}
+	if errs[1] != nil {
+		t.Errorf("v6 ban failed: %v", errs[1])
+	}
+
+	// 失败的变更不留到下一个窗口，由控制器重试
+	adapter.batch.flush()
+	if got := strings.Join(stub.sets["v4"], ","); got != "192.0.2.1/32" {
+		t.Errorf("v4 addresses = %s", got)
+	}
+}
+
+// IPSet 将满时只拒绝超出上限的新增封禁，同批次的解封和其余封禁照常写入
+func TestAWSWAFAdapterRejectsBansOverLimit(t *testing.T) {
+	stub := newWAFStub()
+	for i := len(stub.sets["v4"]); i < wafMaxAddresses; i++ {
+		stub.sets["v4"] = append(stub.sets["v4"], fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+	}
+	adapter := newTestWAFAdapter(t, stub)
+
+	errs := flushAfter(t, adapter.batch,
+		unbanOp(adapter, "192.0.2.1"), banOp(adapter, "203.0.113.1"), banOp(adapter, "203.0.113.2"))
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("unban / first ban failed: %v, %v", errs[0], errs[1])
+	}
+	if errs[2] == nil {
+		t.Fatal("expected ban over the IPSet limit to fail")
+	}
+	if n := len(stub.sets["v4"]); n != wafMaxAddresses || stub.sets["v4"][n-1] != "203.0.113.1/32" {
+		t.Errorf("v4 has %d addresses, last %s", n, stub.sets["v4"][n-1])
+	}
+}
+
+func TestAWSWAFAdapterWebIdentity(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("jwt-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var assumed int
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRoleWithWebIdentity" || r.Form.Get("WebIdentityToken") != "jwt-token" ||
+			r.Form.Get("RoleArn") != "arn:aws:iam::123456789012:role/ipblock" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `<ErrorResponse><Error><Code>InvalidParameter</Code><Message>bad request</Message></Error></ErrorResponse>`)
+			return
+		}
+		assumed++
+		_, _ = fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>AKID</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>session</SessionToken>
+<Expiration>%s</Expiration></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`,
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	t.Cleanup(sts.Close)
+	waf := httptest.NewServer(newWAFStub())
+	t.Cleanup(waf.Close)
+
+	adapter, err := NewAWSWAFAdapter(AWSWAFConfig{
+		Region:   "us-east-1",
+		Endpoint: waf.URL,
+		IPv4Set:  &WAFIPSetRef{Name: "v4", ID: "v4"},
+	}, AWSCredentials{WebIdentity: &AWSWebIdentity{
+		RoleARN:     "arn:aws:iam::123456789012:role/ipblock",
+		TokenFile:   tokenFile,
+		STSEndpoint: sts.URL,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := adapter.Health(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 临时凭据在过期前复用
+	if assumed != 1 {
+		t.Errorf("AssumeRoleWithWebIdentity called %d times", assumed)
+	}
+}
+
+func TestAWSWAFAdapterValidation(t *testing.T) {
+	creds := AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	set := &WAFIPSetRef{Name: "v4", ID: "v4"}
+	cases := map[string]AWSWAFConfig{
+		"missing region":     {IPv4Set: set},
+		"unknown scope":      {Region: "us-east-1", Scope: "GLOBAL", IPv4Set: set},
+		"cloudfront region":  {Region: "eu-west-1", Scope: "CLOUDFRONT", IPv4Set: set},
+		"missing ip sets":    {Region: "us-east-1"},
+		"bad batch interval": {Region: "us-east-1", IPv4Set: set, BatchInterval: "soon"},
+	}
+	for name, cfg := range cases {
+		if _, err := NewAWSWAFAdapter(cfg, creds); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := NewAWSWAFAdapter(AWSWAFConfig{Region: "us-east-1", IPv4Set: set}, AWSCredentials{}); err == nil {
+		t.Error("missing credentials: expected error")
+	}
+
+	adapter, err := NewAWSWAFAdapter(AWSWAFConfig{Region: "us-east-1", IPv4Set: set}, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adapter.Ban(BanRequest{IP: "2001:db8::1", Permanent: true}); err == nil {
+		t.Error("expected error for IPv6 without ipv6Set")
+	}
+}
+
+// AWS SigV4 测试套件中的 get-vanilla 用例
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	now, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+	signV4(req, nil, AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, "us-east-1", "service", now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s", got)
+	}
+}
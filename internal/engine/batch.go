@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// batcher 合并一个窗口内的封禁/解封，窗口结束后一次性提交，
+// 用于每次变更代价较高（reload、API 配额）的引擎。调用方等待所在批次的提交结果，
+// 提交失败时返回错误，由控制器决定是否重试，batcher 自身不保留失败的变更
+type batcher struct {
+	name     string // 用于错误信息
+	interval time.Duration
+	// apply 提交一个批次，返回单个 CIDR 的错误；err 不为空时未单独返回错误的 CIDR 都视为失败
+	apply func(ops map[string]bool) (failed map[string]error, err error)
+
+	mu      sync.Mutex
+	pending map[string]*batchOp // 同一 CIDR 以最后一次操作为准
+	timer   *time.Timer
+
+	flushMu sync.Mutex // 串行化提交
+}
+
+type batchOp struct {
+	ban     bool // true 封禁 / false 解封
+	waiters []chan error
+}
+
+func newBatcher(name string, interval time.Duration, apply func(map[string]bool) (map[string]error, error)) *batcher {
+	return &batcher{
+		name:     name,
+		interval: interval,
+		apply:    apply,
+		pending:  make(map[string]*batchOp),
+	}
+}
+
+// do 加入当前窗口并等待提交结果。窗口内第一次变更启动计时器，后续变更合并进同一次提交；
+// 同一 CIDR 被相反的操作覆盖时，之前的调用方立即返回错误
+func (b *batcher) do(cidr string, ban bool) error {
+	done := make(chan error, 1)
+
+	b.mu.Lock()
+	op := b.pending[cidr]
+	if op == nil {
+		op = &batchOp{}
+		b.pending[cidr] = op
+	} else if op.ban != ban {
+		for _, w := range op.waiters {
+			w <- fmt.Errorf("%s: change of %s superseded by a later %s", b.name, cidr, opName(ban))
+		}
+		op.waiters = nil
+	}
+	op.ban = ban
+	op.waiters = append(op.waiters, done)
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, b.flush)
+	}
+	b.mu.Unlock()
+
+	return <-done
+}
+
+func opName(ban bool) string {
+	if ban {
+		return "ban"
+	}
+	return "unban"
+}
+
+func (b *batcher) flush() {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = make(map[string]*batchOp)
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	ops := make(map[string]bool, len(pending))
+	for cidr, op := range pending {
+		ops[cidr] = op.ban
+	}
+
+	failed, err := b.apply(ops)
+	for cidr, op := range pending {
+		result, ok := failed[cidr]
+		if !ok && err != nil {
+			result = fmt.Errorf("%s: %w", b.name, err)
+		}
+		for _, w := range op.waiters {
+			w <- result
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package engine
+
+import "sync"
+
+// 逐个调用时的并发数
+const batchFallbackConcurrency = 8
+
+// BatchResult 批量操作中单个请求的结果
+type BatchResult struct {
+	Message  string
+	Err      error
+	Backends []BackendResult // 组合引擎下每个后端的结果
+}
+
+// BatchAdapter 一次调用处理多个请求的引擎，返回的结果与请求一一对应。
+// 未实现此接口的引擎由 BanBatch、UnBanBatch 逐个调用
+type BatchAdapter interface {
+	Adapter
+	BanBatch(reqs []BanRequest) []BatchResult
+	UnBanBatch(reqs []BanRequest) []BatchResult
+}
+
+// BanBatch 批量封禁，引擎不支持批量操作时并发地逐个调用
+func BanBatch(a Adapter, reqs []BanRequest) []BatchResult {
+	if b, ok := a.(BatchAdapter); ok {
+		return b.BanBatch(reqs)
+	}
+	if m, ok := a.(MultiAdapter); ok {
+		return eachRequest(reqs, func(req BanRequest) BatchResult {
+			msg, results, err := m.BanEach(req)
+			return BatchResult{Message: msg, Err: err, Backends: results}
+		})
+	}
+	return eachRequest(reqs, singleResult(a.Ban))
+}
+
+// UnBanBatch 批量解封，引擎不支持批量操作时并发地逐个调用
+func UnBanBatch(a Adapter, reqs []BanRequest) []BatchResult {
+	if b, ok := a.(BatchAdapter); ok {
+		return b.UnBanBatch(reqs)
+	}
+	if m, ok := a.(MultiAdapter); ok {
+		return eachRequest(reqs, func(req BanRequest) BatchResult {
+			msg, results, err := m.UnBanEach(req)
+			return BatchResult{Message: msg, Err: err, Backends: results}
+		})
+	}
+	return eachRequest(reqs, singleResult(a.UnBan))
+}
+
+func singleResult(op func(BanRequest) (string, error)) func(BanRequest) BatchResult {
+	return func(req BanRequest) BatchResult {
+		msg, err := op(req)
+		return BatchResult{Message: msg, Err: err}
+	}
+}
+
+func eachRequest(reqs []BanRequest, op func(BanRequest) BatchResult) []BatchResult {
+	results := make([]BatchResult, len(reqs))
+	sem := make(chan struct{}, batchFallbackConcurrency)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req BanRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = op(req)
+		}(i, req)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,77 @@
+package engine
+
+import "testing"
+
+// 不支持批量操作的引擎逐个调用，结果与请求顺序一致
+func TestBanBatchFallback(t *testing.T) {
+	a := &recordingAdapter{}
+	reqs := []BanRequest{{IP: "192.0.2.1"}, {IP: "192.0.2.2"}, {IP: "192.0.2.3"}}
+	results := BanBatch(a, reqs)
+	if len(results) != len(reqs) {
+		t.Fatalf("results = %+v", results)
+	}
+	for i, req := range reqs {
+		if results[i].Err != nil || results[i].Message != "banned "+req.IP {
+			t.Errorf("result %d = %+v", i, results[i])
+		}
+	}
+	if len(a.banned) != len(reqs) {
+		t.Errorf("banned = %v", a.banned)
+	}
+
+	a.fail = true
+	for _, r := range UnBanBatch(a, reqs) {
+		if r.Err == nil {
+			t.Error("expected failure")
+		}
+	}
+}
+
+// 组合引擎对每个后端只做一次批量调用，结果按请求汇总
+func TestCompositeBanBatch(t *testing.T) {
+	edge, gateway := &batchAdapter{}, &recordingAdapter{}
+	c, err := NewCompositeAdapter(CompositePolicyAll, []CompositeBackend{
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: CloudflareEngine, Tags: []string{"edge"}}, Adapter: edge},
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine, Sources: []string{"grafana"}}, Adapter: gateway},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := BanBatch(c, []BanRequest{
+		{IP: "192.0.2.1", Source: "grafana", Tags: []string{"edge"}},
+		{IP: "192.0.2.2", Source: "grafana"},
+		{IP: "192.0.2.3", Source: "feed/x"},
+	})
+	if len(edge.batches) != 1 || len(edge.batches[0]) != 1 || edge.batches[0][0].IP != "192.0.2.1" {
+		t.Errorf("edge batches = %+v", edge.batches)
+	}
+	if len(gateway.banned) != 2 {
+		t.Errorf("gateway banned = %v", gateway.banned)
+	}
+	if r := results[0]; r.Err != nil || len(r.Backends) != 2 || r.Backends[0].Engine != CloudflareEngine {
+		t.Errorf("result 0 = %+v", r)
+	}
+	if r := results[1]; r.Err != nil || len(r.Backends) != 1 || r.Backends[0].Engine != XDPEngine {
+		t.Errorf("result 1 = %+v", r)
+	}
+	// 没有匹配的后端
+	if results[2].Err == nil {
+		t.Error("request without matching backend should fail")
+	}
+}
+
+// batchAdapter 记录每次批量调用
+type batchAdapter struct {
+	recordingAdapter
+	batches [][]BanRequest
+}
+
+func (a *batchAdapter) BanBatch(reqs []BanRequest) []BatchResult {
+	a.batches = append(a.batches, reqs)
+	return make([]BatchResult, len(reqs))
+}
+
+func (a *batchAdapter) UnBanBatch(reqs []BanRequest) []BatchResult {
+	return make([]BatchResult, len(reqs))
+}
@@ -0,0 +1,409 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	api "github.com/osrg/gobgp/v3/api"
+	bgplog "github.com/osrg/gobgp/v3/pkg/log"
+	"github.com/osrg/gobgp/v3/pkg/server"
+	"google.golang.org/protobuf/types/known/anypb"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// BGPBlackholeCommunity RFC 7999 定义的 BLACKHOLE 团体属性
+	BGPBlackholeCommunity = "65535:666"
+	// BGPDefaultTag 默认只宣告带有此标签的 IPBlock
+	BGPDefaultTag = "blackhole"
+
+	bgpTimeout = 10 * time.Second
+)
+
+// BGPNeighbor 上游路由器
+type BGPNeighbor struct {
+	Address        string        `json:"address"`
+	PeerAS         uint32        `json:"peerAS"`
+	Port           uint32        `json:"port,omitempty"`           // 默认 179
+	PasswordSecret *SecretKeyRef `json:"passwordSecret,omitempty"` // TCP MD5 密码
+	Passive        bool          `json:"passive,omitempty"`        // 只等待对端连接，需要配置 listenPort
+	MultihopTTL    uint32        `json:"multihopTTL,omitempty"`    // eBGP 多跳，为 0 时只允许直连
+}
+
+// BGPConfig bgp 引擎配置（ConfigMap 的 bgp 字段）
+type BGPConfig struct {
+	LocalAS    uint32 `json:"localAS"`
+	RouterID   string `json:"routerID"`
+	ListenPort int32  `json:"listenPort,omitempty"` // 默认 -1，不监听，只主动连接上游
+	// 黑洞路由的下一跳，IPv4 默认为 routerID；未配置 nextHopV6 时不能封禁 IPv6 地址
+	NextHop   string `json:"nextHop,omitempty"`
+	NextHopV6 string `json:"nextHopV6,omitempty"`
+	// 附加到路由上的团体属性，格式为 ASN:值，默认 65535:666
+	Communities []string `json:"communities,omitempty"`
+	// 只宣告带有该标签的 IPBlock，默认 blackhole；announceAll 为 true 时宣告所有封禁
+	Tag         string `json:"tag,omitempty"`
+	AnnounceAll bool   `json:"announceAll,omitempty"`
+	// 允许宣告的最短前缀，默认 32 和 128，即只宣告单个地址
+	MinPrefixLengthV4 int           `json:"minPrefixLengthV4,omitempty"`
+	MinPrefixLengthV6 int           `json:"minPrefixLengthV6,omitempty"`
+	Neighbors         []BGPNeighbor `json:"neighbors"`
+}
+
+// BGPAdapter 内嵌 GoBGP，把封禁宣告为 /32、/128 黑洞路由，解封时撤回。
+// 路由只存在于本进程中，Operator 重启或会话中断后上游会自动撤回
+type BGPAdapter struct {
+	Config BGPConfig
+
+	server      *server.BgpServer
+	communities []uint32
+
+	mu        sync.Mutex
+	announced map[netip.Prefix]struct{}
+}
+
+// NewBGPAdapter 校验配置并启动 BGP speaker，passwords 为各邻居地址对应的 MD5 密码
+func NewBGPAdapter(cfg BGPConfig, passwords map[string]string) (*BGPAdapter, error) {
+	cfg, communities, err := normalizeBGPConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]*api.Peer, 0, len(cfg.Neighbors))
+	for _, n := range cfg.Neighbors {
+		peer := &api.Peer{
+			Conf:      &api.PeerConf{NeighborAddress: n.Address, PeerAsn: n.PeerAS, AuthPassword: passwords[n.Address]},
+			Transport: &api.Transport{RemotePort: n.Port, PassiveMode: n.Passive},
+			AfiSafis: []*api.AfiSafi{
+				{Config: &api.AfiSafiConfig{Family: bgpFamilyV4, Enabled: true}},
+				{Config: &api.AfiSafiConfig{Family: bgpFamilyV6, Enabled: true}},
+			},
+		}
+		if n.MultihopTTL > 0 {
+			peer.EbgpMultihop = &api.EbgpMultihop{Enabled: true, MultihopTtl: n.MultihopTTL}
+		}
+		peers = append(peers, peer)
+	}
+
+	s := server.NewBgpServer(server.LoggerOption(&bgpLogger{}))
+	go s.Serve()
+
+	ctx, cancel := context.WithTimeout(context.Background(), bgpTimeout)
+	defer cancel()
+	if err := s.StartBgp(ctx, &api.StartBgpRequest{Global: &api.Global{
+		Asn:        cfg.LocalAS,
+		RouterId:   cfg.RouterID,
+		ListenPort: cfg.ListenPort,
+	}}); err != nil {
+		s.Stop()
+		return nil, fmt.Errorf("start bgp speaker failed: %w", err)
+	}
+	for _, p := range peers {
+		if err := s.AddPeer(ctx, &api.AddPeerRequest{Peer: p}); err != nil {
+			s.Stop()
+			return nil, fmt.Errorf("add bgp neighbor %s failed: %w", p.Conf.NeighborAddress, err)
+		}
+	}
+
+	return &BGPAdapter{
+		Config:      cfg,
+		server:      s,
+		communities: communities,
+		announced:   make(map[netip.Prefix]struct{}),
+	}, nil
+}
+
+var (
+	bgpFamilyV4 = &api.Family{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST}
+	bgpFamilyV6 = &api.Family{Afi: api.Family_AFI_IP6, Safi: api.Family_SAFI_UNICAST}
+)
+
+// Ban 宣告黑洞路由，未带黑洞标签的封禁不宣告，直接返回成功
+func (b *BGPAdapter) Ban(req BanRequest) (string, error) {
+	if !b.selected(req) {
+		return fmt.Sprintf("%s is not tagged '%s', blackhole route not announced", req.IP, b.Config.Tag), nil
+	}
+	prefix, err := b.prefix(req.IP)
+	if err != nil {
+		return "", err
+	}
+	path, err := b.path(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bgpTimeout)
+	defer cancel()
+	if _, err := b.server.AddPath(ctx, &api.AddPathRequest{TableType: api.TableType_GLOBAL, Path: path}); err != nil {
+		return "", fmt.Errorf("announce blackhole route %s failed: %w", prefix, err)
+	}
+	b.mu.Lock()
+	b.announced[prefix] = struct{}{}
+	b.mu.Unlock()
+	return fmt.Sprintf("blackhole route %s announced with communities %s", prefix, strings.Join(b.Config.Communities, ",")), nil
+}
+
+// UnBan 撤回黑洞路由，未宣告过的地址直接返回成功
+func (b *BGPAdapter) UnBan(req BanRequest) (string, error) {
+	prefix, err := parseBlackholePrefix(req.IP)
+	if err != nil {
+		return "", err
+	}
+	b.mu.Lock()
+	_, ok := b.announced[prefix]
+	b.mu.Unlock()
+	if !ok {
+		return fmt.Sprintf("blackhole route %s not announced", prefix), nil
+	}
+	path, err := b.path(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bgpTimeout)
+	defer cancel()
+	if err := b.server.DeletePath(ctx, &api.DeletePathRequest{TableType: api.TableType_GLOBAL, Family: path.Family, Path: path}); err != nil {
+		return "", fmt.Errorf("withdraw blackhole route %s failed: %w", prefix, err)
+	}
+	b.mu.Lock()
+	delete(b.announced, prefix)
+	b.mu.Unlock()
+	return fmt.Sprintf("blackhole route %s withdrawn", prefix), nil
+}
+
+// Announced 返回当前宣告中的前缀
+func (b *BGPAdapter) Announced() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list := make([]string, 0, len(b.announced))
+	for p := range b.announced {
+		list = append(list, p.String())
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Health 至少一个邻居处于 Established 状态时视为可达
+func (b *BGPAdapter) Health(ctx context.Context) error {
+	var states []string
+	established := false
+	err := b.server.ListPeer(ctx, &api.ListPeerRequest{}, func(p *api.Peer) {
+		state := p.GetState().GetSessionState()
+		if state == api.PeerState_ESTABLISHED {
+			established = true
+		}
+		states = append(states, fmt.Sprintf("%s %s", p.GetConf().GetNeighborAddress(), strings.ToLower(state.String())))
+	})
+	if err != nil {
+		return fmt.Errorf("list bgp neighbors failed: %w", err)
+	}
+	if !established {
+		return fmt.Errorf("no bgp session established: %s", strings.Join(states, ", "))
+	}
+	return nil
+}
+
+// Stop 关闭所有会话，上游随即撤回本引擎宣告的路由
+func (b *BGPAdapter) Stop() {
+	b.server.Stop()
+}
+
+func (b *BGPAdapter) selected(req BanRequest) bool {
+	return b.Config.AnnounceAll || slices.Contains(req.Tags, b.Config.Tag)
+}
+
+// ValidateBGPConfig 只校验配置，不启动 speaker
+func ValidateBGPConfig(cfg BGPConfig) error {
+	_, _, err := normalizeBGPConfig(cfg)
+	return err
+}
+
+// 校验配置并填充默认值，返回解析后的团体属性
+func normalizeBGPConfig(cfg BGPConfig) (BGPConfig, []uint32, error) {
+	routerID, err := netip.ParseAddr(cfg.RouterID)
+	if err != nil || !routerID.Is4() {
+		return cfg, nil, fmt.Errorf("bgp routerID must be an IPv4 address")
+	}
+	if cfg.LocalAS == 0 {
+		return cfg, nil, fmt.Errorf("bgp localAS is required")
+	}
+	if len(cfg.Neighbors) == 0 {
+		return cfg, nil, fmt.Errorf("bgp requires at least one neighbor")
+	}
+	if cfg.ListenPort == 0 {
+		cfg.ListenPort = -1
+	}
+	if cfg.NextHop == "" {
+		cfg.NextHop = cfg.RouterID
+	}
+	if a, err := netip.ParseAddr(cfg.NextHop); err != nil || !a.Is4() {
+		return cfg, nil, fmt.Errorf("invalid bgp nextHop '%s'", cfg.NextHop)
+	}
+	if cfg.NextHopV6 != "" {
+		if a, err := netip.ParseAddr(cfg.NextHopV6); err != nil || !a.Is6() || a.Is4In6() {
+			return cfg, nil, fmt.Errorf("invalid bgp nextHopV6 '%s'", cfg.NextHopV6)
+		}
+	}
+	if len(cfg.Communities) == 0 {
+		cfg.Communities = []string{BGPBlackholeCommunity}
+	}
+	if cfg.Tag == "" {
+		cfg.Tag = BGPDefaultTag
+	}
+	if cfg.MinPrefixLengthV4 == 0 {
+		cfg.MinPrefixLengthV4 = 32
+	}
+	if cfg.MinPrefixLengthV6 == 0 {
+		cfg.MinPrefixLengthV6 = 128
+	}
+	if cfg.MinPrefixLengthV4 < 8 || cfg.MinPrefixLengthV4 > 32 || cfg.MinPrefixLengthV6 < 16 || cfg.MinPrefixLengthV6 > 128 {
+		return cfg, nil, fmt.Errorf("bgp minPrefixLengthV4 must be 8-32 and minPrefixLengthV6 16-128")
+	}
+
+	communities := make([]uint32, 0, len(cfg.Communities))
+	for _, c := range cfg.Communities {
+		v, err := parseCommunity(c)
+		if err != nil {
+			return cfg, nil, err
+		}
+		communities = append(communities, v)
+	}
+	for _, n := range cfg.Neighbors {
+		if _, err := netip.ParseAddr(n.Address); err != nil {
+			return cfg, nil, fmt.Errorf("invalid bgp neighbor address '%s'", n.Address)
+		}
+		if n.PeerAS == 0 {
+			return cfg, nil, fmt.Errorf("bgp neighbor %s requires peerAS", n.Address)
+		}
+		if n.Passive && cfg.ListenPort < 0 {
+			return cfg, nil, fmt.Errorf("passive bgp neighbor %s requires listenPort", n.Address)
+		}
+	}
+	return cfg, communities, nil
+}
+
+// 单个地址转换为 /32、/128，前缀不能短于配置的最短前缀
+func (b *BGPAdapter) prefix(ip string) (netip.Prefix, error) {
+	p, err := parseBlackholePrefix(ip)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	minLen := b.Config.MinPrefixLengthV4
+	if p.Addr().Is6() {
+		minLen = b.Config.MinPrefixLengthV6
+	}
+	if p.Bits() < minLen {
+		return netip.Prefix{}, fmt.Errorf("prefix %s is shorter than /%d allowed for blackholing", p, minLen)
+	}
+	return p, nil
+}
+
+func parseBlackholePrefix(ip string) (netip.Prefix, error) {
+	cidr, err := blockCIDR(ip)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return p.Masked(), nil
+}
+
+func (b *BGPAdapter) path(p netip.Prefix) (*api.Path, error) {
+	nlri, err := anypb.New(&api.IPAddressPrefix{Prefix: p.Addr().String(), PrefixLen: uint32(p.Bits())})
+	if err != nil {
+		return nil, err
+	}
+	origin, err := anypb.New(&api.OriginAttribute{Origin: 0})
+	if err != nil {
+		return nil, err
+	}
+	communities, err := anypb.New(&api.CommunitiesAttribute{Communities: b.communities})
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Addr().Is4() {
+		nextHop, err := anypb.New(&api.NextHopAttribute{NextHop: b.Config.NextHop})
+		if err != nil {
+			return nil, err
+		}
+		return &api.Path{Family: bgpFamilyV4, Nlri: nlri, Pattrs: []*anypb.Any{origin, nextHop, communities}}, nil
+	}
+
+	if b.Config.NextHopV6 == "" {
+		return nil, fmt.Errorf("bgp nextHopV6 is required to blackhole %s", p)
+	}
+	reach, err := anypb.New(&api.MpReachNLRIAttribute{Family: bgpFamilyV6, NextHops: []string{b.Config.NextHopV6}, Nlris: []*anypb.Any{nlri}})
+	if err != nil {
+		return nil, err
+	}
+	return &api.Path{Family: bgpFamilyV6, Nlri: nlri, Pattrs: []*anypb.Any{origin, reach, communities}}, nil
+}
+
+// 解析 ASN:值 格式的团体属性
+func parseCommunity(s string) (uint32, error) {
+	asn, value, ok := strings.Cut(s, ":")
+	if ok {
+		hi, err1 := strconv.ParseUint(asn, 10, 16)
+		lo, err2 := strconv.ParseUint(value, 10, 16)
+		if err1 == nil && err2 == nil {
+			return uint32(hi)<<16 | uint32(lo), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid bgp community '%s', expected ASN:value", s)
+}
+
+// bgpLogger 把 GoBGP 的日志输出到 controller-runtime 的日志
+type bgpLogger struct{}
+
+func (l *bgpLogger) log() logr.Logger {
+	return logf.Log.WithName("bgp-engine")
+}
+
+func (l *bgpLogger) Panic(msg string, fields bgplog.Fields) {
+	l.log().Error(nil, msg, flatten(fields)...)
+	panic(msg)
+}
+
+func (l *bgpLogger) Fatal(msg string, fields bgplog.Fields) {
+	l.log().Error(nil, msg, flatten(fields)...)
+}
+
+func (l *bgpLogger) Error(msg string, fields bgplog.Fields) {
+	l.log().Error(nil, msg, flatten(fields)...)
+}
+
+func (l *bgpLogger) Warn(msg string, fields bgplog.Fields) {
+	l.log().Info(msg, flatten(fields)...)
+}
+
+func (l *bgpLogger) Info(msg string, fields bgplog.Fields) {
+	l.log().Info(msg, flatten(fields)...)
+}
+
+func (l *bgpLogger) Debug(msg string, fields bgplog.Fields) {
+	l.log().V(1).Info(msg, flatten(fields)...)
+}
+
+func (l *bgpLogger) SetLevel(level bgplog.LogLevel) {}
+
+func (l *bgpLogger) GetLevel() bgplog.LogLevel {
+	return bgplog.InfoLevel
+}
+
+func flatten(fields bgplog.Fields) []interface{} {
+	kv := make([]interface{}, 0, len(fields)*2)
+	for k, v := range fields {
+		kv = append(kv, k, v)
+	}
+	return kv
+}
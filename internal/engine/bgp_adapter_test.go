@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/server"
+)
+
+// startUpstream 启动一个进程内的 BGP speaker 模拟上游路由器，被动等待引擎连接
+func startUpstream(t *testing.T) (*server.BgpServer, uint32) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	s := server.NewBgpServer(server.LoggerOption(&bgpLogger{}))
+	go s.Serve()
+	t.Cleanup(s.Stop)
+	ctx := context.Background()
+	if err := s.StartBgp(ctx, &api.StartBgpRequest{Global: &api.Global{
+		Asn: 64500, RouterId: "10.0.0.2", ListenPort: int32(port), ListenAddresses: []string{"127.0.0.1"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddPeer(ctx, &api.AddPeerRequest{Peer: &api.Peer{
+		Conf:      &api.PeerConf{NeighborAddress: "127.0.0.1", PeerAsn: 65001},
+		Transport: &api.Transport{PassiveMode: true},
+		AfiSafis: []*api.AfiSafi{
+			{Config: &api.AfiSafiConfig{Family: bgpFamilyV4, Enabled: true}},
+			{Config: &api.AfiSafiConfig{Family: bgpFamilyV6, Enabled: true}},
+		},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	return s, uint32(port)
+}
+
+// 上游收到的路由及其团体属性
+func receivedRoutes(t *testing.T, s *server.BgpServer) map[string][]uint32 {
+	t.Helper()
+	routes := make(map[string][]uint32)
+	for _, family := range []*api.Family{bgpFamilyV4, bgpFamilyV6} {
+		err := s.ListPath(context.Background(), &api.ListPathRequest{TableType: api.TableType_GLOBAL, Family: family}, func(d *api.Destination) {
+			for _, p := range d.GetPaths() {
+				var communities []uint32
+				for _, a := range p.GetPattrs() {
+					var c api.CommunitiesAttribute
+					if a.MessageIs(&c) && a.UnmarshalTo(&c) == nil {
+						communities = c.GetCommunities()
+					}
+				}
+				routes[d.GetPrefix()] = communities
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return routes
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestBGPAnnouncesBlackholeRoutes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("establishing a BGP session takes several seconds")
+	}
+	upstream, port := startUpstream(t)
+
+	b, err := NewBGPAdapter(BGPConfig{
+		LocalAS:   65001,
+		RouterID:  "10.0.0.1",
+		NextHopV6: "2001:db8::1",
+		Neighbors: []BGPNeighbor{{Address: "127.0.0.1", PeerAS: 64500, Port: port}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+
+	waitFor(t, "bgp session", func() bool { return b.Health(context.Background()) == nil })
+
+	tags := []string{BGPDefaultTag}
+	for _, ip := range []string{"192.0.2.1", "2001:db8::66"} {
+		if _, err := b.Ban(BanRequest{IP: ip, Permanent: true, Tags: tags}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 未带黑洞标签的封禁不宣告
+	if msg, err := b.Ban(BanRequest{IP: "192.0.2.2", Permanent: true}); err != nil || msg == "" {
+		t.Fatalf("untagged ban: %q, %v", msg, err)
+	}
+	// 默认只允许宣告单个地址
+	if _, err := b.Ban(BanRequest{IP: "198.51.100.0/24", Permanent: true, Tags: tags}); err == nil {
+		t.Error("expected /24 to be rejected")
+	}
+
+	blackhole, _ := parseCommunity(BGPBlackholeCommunity)
+	waitFor(t, "routes at upstream", func() bool { return len(receivedRoutes(t, upstream)) == 2 })
+	routes := receivedRoutes(t, upstream)
+	for _, prefix := range []string{"192.0.2.1/32", "2001:db8::66/128"} {
+		communities, ok := routes[prefix]
+		if !ok || len(communities) != 1 || communities[0] != blackhole {
+			t.Errorf("route %s = %v (present %v), want community %s", prefix, communities, ok, BGPBlackholeCommunity)
+		}
+	}
+
+	if _, err := b.UnBan(BanRequest{IP: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "withdrawal", func() bool {
+		_, ok := receivedRoutes(t, upstream)["192.0.2.1/32"]
+		return !ok
+	})
+	if got := b.Announced(); len(got) != 1 || got[0] != "2001:db8::66/128" {
+		t.Errorf("announced = %v", got)
+	}
+}
+
+func TestNewBGPAdapterValidation(t *testing.T) {
+	neighbor := []BGPNeighbor{{Address: "192.0.2.254", PeerAS: 64500}}
+	for name, cfg := range map[string]BGPConfig{
+		"router id":   {LocalAS: 65001, RouterID: "2001:db8::1", Neighbors: neighbor},
+		"local as":    {RouterID: "10.0.0.1", Neighbors: neighbor},
+		"neighbors":   {LocalAS: 65001, RouterID: "10.0.0.1"},
+		"community":   {LocalAS: 65001, RouterID: "10.0.0.1", Neighbors: neighbor, Communities: []string{"666"}},
+		"next hop v6": {LocalAS: 65001, RouterID: "10.0.0.1", Neighbors: neighbor, NextHopV6: "10.0.0.1"},
+		"prefix":      {LocalAS: 65001, RouterID: "10.0.0.1", Neighbors: neighbor, MinPrefixLengthV4: 4},
+		"passive":     {LocalAS: 65001, RouterID: "10.0.0.1", Neighbors: []BGPNeighbor{{Address: "192.0.2.254", PeerAS: 64500, Passive: true}}},
+	} {
+		if _, err := NewBGPAdapter(cfg, nil); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
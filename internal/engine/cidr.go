@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github/Beatrueman/ipblock-operator/internal/utils"
+)
+
+// AggregateCIDRs 合并 CIDR 列表：去重、去掉被包含的网段，并把相邻的同长度网段合并为上一级网段，
+// 例如 10.0.0.0/25 + 10.0.0.128/25 => 10.0.0.0/24。单个 IP 按 /32、/128 处理，输出按地址排序
+func AggregateCIDRs(cidrs []string) ([]string, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, c := range cidrs {
+		p, err := netip.ParsePrefix(utils.NormalizeCIDR(c))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr '%s': %w", c, err)
+		}
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()).Masked()
+		prefixes = append(prefixes, p)
+	}
+
+	// 按地址升序、前缀长度升序排序，保证包含者排在被包含者之前
+	sort.Slice(prefixes, func(i, j int) bool {
+		if c := prefixes[i].Addr().Compare(prefixes[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return prefixes[i].Bits() < prefixes[j].Bits()
+	})
+
+	var out []netip.Prefix
+	for _, p := range prefixes {
+		if n := len(out); n > 0 && out[n-1].Overlaps(p) {
+			// 已排序，重叠即意味着 p 被上一个网段包含
+			continue
+		}
+		out = append(out, p)
+		for len(out) >= 2 {
+			a, b := out[len(out)-2], out[len(out)-1]
+			if a.Bits() != b.Bits() || a.Bits() == 0 {
+				break
+			}
+			parent := netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked()
+			if parent != netip.PrefixFrom(b.Addr(), b.Bits()-1).Masked() {
+				break
+			}
+			out = append(out[:len(out)-2], parent)
+		}
+	}
+
+	result := make([]string, 0, len(out))
+	for _, p := range out {
+		result = append(result, p.String())
+	}
+	return result, nil
+}
+
+// 按地址族拆分 CIDR
+func splitFamilies(cidrs []string) (v4, v6 []string) {
+	for _, c := range cidrs {
+		if strings.Contains(c, ":") {
+			v6 = append(v6, c)
+		} else {
+			v4 = append(v4, c)
+		}
+	}
+	return v4, v6
+}
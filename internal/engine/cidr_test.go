@@ -0,0 +1,33 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAggregateCIDRs(t *testing.T) {
+	cases := []struct {
+		in   []string
+		want []string
+	}{
+		{[]string{"10.0.0.1", "10.0.0.1/32"}, []string{"10.0.0.1/32"}},
+		{[]string{"10.0.0.0/25", "10.0.0.128/25"}, []string{"10.0.0.0/24"}},
+		{[]string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3"}, []string{"10.0.0.0/30"}},
+		{[]string{"10.0.0.5", "10.0.0.0/24", "192.168.1.1"}, []string{"10.0.0.0/24", "192.168.1.1/32"}},
+		{[]string{"10.0.0.1", "10.0.0.2"}, []string{"10.0.0.1/32", "10.0.0.2/32"}},
+		{[]string{"2001:db8::1", "2001:db8::/127", "1.2.3.4"}, []string{"1.2.3.4/32", "2001:db8::/127"}},
+	}
+	for _, c := range cases {
+		got, err := AggregateCIDRs(c.in)
+		if err != nil {
+			t.Fatalf("AggregateCIDRs(%v) error: %v", c.in, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("AggregateCIDRs(%v) = %v, want %v", c.in, got, c.want)
+		}
+	}
+
+	if _, err := AggregateCIDRs([]string{"not-an-ip"}); err == nil {
+		t.Error("expected error for invalid cidr")
+	}
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CiliumClusterwideNetworkPolicyGVK Cilium 集群级策略，使用 unstructured 访问以免引入 Cilium 依赖
+var CiliumClusterwideNetworkPolicyGVK = schema.GroupVersionKind{
+	Group:   "cilium.io",
+	Version: "v2",
+	Kind:    "CiliumClusterwideNetworkPolicy",
+}
+
+// CiliumAdapter 通过 CiliumClusterwideNetworkPolicy 的 ingressDeny 规则执行封禁
+type CiliumAdapter struct {
+	Client client.Client
+	Config PolicyConfig
+
+	mu sync.Mutex // 串行化对托管策略的读改写
+}
+
+// NewCiliumAdapter 创建 Cilium 引擎
+func NewCiliumAdapter(c client.Client, cfg PolicyConfig) *CiliumAdapter {
+	return &CiliumAdapter{Client: c, Config: cfg.withDefaults()}
+}
+
+func (c *CiliumAdapter) Ban(req BanRequest) (string, error) {
+	cidr, err := blockCIDR(req.IP)
+	if err != nil {
+		return "", err
+	}
+	return c.update(func(set map[string]struct{}) { set[cidr] = struct{}{} })
+}
+
+func (c *CiliumAdapter) UnBan(req BanRequest) (string, error) {
+	cidr, err := blockCIDR(req.IP)
+	if err != nil {
+		return "", err
+	}
+	return c.update(func(set map[string]struct{}) { delete(set, cidr) })
+}
+
+func (c *CiliumAdapter) update(mutate func(map[string]struct{})) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), policyTimeout)
+	defer cancel()
+
+	var total int
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		policy := &unstructured.Unstructured{}
+		policy.SetGroupVersionKind(CiliumClusterwideNetworkPolicyGVK)
+		err := c.Client.Get(ctx, client.ObjectKey{Name: c.Config.Name}, policy)
+		exists := err == nil
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if !exists {
+			policy = &unstructured.Unstructured{}
+			policy.SetGroupVersionKind(CiliumClusterwideNetworkPolicyGVK)
+			policy.SetName(c.Config.Name)
+		}
+
+		blocked, aggregated, err := mutateCIDRSet(policy.GetAnnotations()[BlockedCIDRsAnnotation], mutate)
+		if err != nil {
+			return err
+		}
+		total = len(aggregated)
+
+		policy.SetLabels(withManagedLabel(policy.GetLabels()))
+		policy.SetAnnotations(withBlockedCIDRs(policy.GetAnnotations(), blocked))
+
+		if err := unstructured.SetNestedField(policy.Object, c.buildSpec(aggregated), "spec"); err != nil {
+			return err
+		}
+
+		if exists {
+			return c.Client.Update(ctx, policy)
+		}
+		return c.Client.Create(ctx, policy)
+	})
+	if err != nil {
+		return "", fmt.Errorf("update CiliumClusterwideNetworkPolicy %s failed: %w", c.Config.Name, err)
+	}
+	return fmt.Sprintf("CiliumClusterwideNetworkPolicy %s updated, %d CIDRs blocked", c.Config.Name, total), nil
+}
+
+// 只包含拒绝规则，并关闭默认拒绝，避免被选中的 Pod 因策略存在而拒绝其他流量
+func (c *CiliumAdapter) buildSpec(cidrs []string) map[string]interface{} {
+	matchLabels := make(map[string]interface{}, len(c.Config.Selector))
+	for k, v := range c.Config.Selector {
+		matchLabels[k] = v
+	}
+	spec := map[string]interface{}{
+		"endpointSelector": map[string]interface{}{"matchLabels": matchLabels},
+		"enableDefaultDeny": map[string]interface{}{
+			"ingress": false,
+			"egress":  false,
+		},
+	}
+	if len(cidrs) > 0 {
+		fromCIDR := make([]interface{}, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			fromCIDR = append(fromCIDR, cidr)
+		}
+		spec["ingressDeny"] = []interface{}{
+			map[string]interface{}{"fromCIDR": fromCIDR},
+		}
+	}
+	return spec
+}
@@ -0,0 +1,311 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultCloudflareBaseURL Cloudflare API 地址，测试时可指向本地桩服务
+	DefaultCloudflareBaseURL = "https://api.cloudflare.com/client/v4"
+
+	// 写入规则备注，用于识别本引擎创建的规则，解封和同步时不会触碰其他规则
+	cloudflareNotes = "managed by ipblock-operator"
+
+	cloudflarePerPage     = 100
+	cloudflareMaxAttempts = 5
+	cloudflareTimeout     = time.Minute
+	// Cloudflare 全局限制为每 5 分钟 1200 次请求，客户端按 4 次/秒限速
+	cloudflareRPS = 4
+
+	cloudflareDuplicateCode = 10009 // firewallaccessrules.api.duplicate_of_existing
+)
+
+// SecretRef 引用一个 Secret，Namespace 为空时使用 Operator ConfigMap 所在命名空间
+type SecretRef struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// SecretKeyRef 引用 Secret 中的某个键
+type SecretKeyRef struct {
+	SecretRef
+	Key string `json:"key"`
+}
+
+// CloudflareConfig Cloudflare 引擎配置（ConfigMap 的 cloudflare 字段）
+type CloudflareConfig struct {
+	BaseURL     string       `json:"baseURL,omitempty"`   // API 地址，默认 https://api.cloudflare.com/client/v4
+	ZoneID      string       `json:"zoneID,omitempty"`    // 规则作用于单个 Zone，与 accountID 二选一
+	AccountID   string       `json:"accountID,omitempty"` // 规则作用于账户下所有 Zone
+	Mode        string       `json:"mode,omitempty"`      // block（默认）、challenge、js_challenge、managed_challenge
+	TokenSecret SecretKeyRef `json:"tokenSecret"`         // API Token 所在 Secret，需要 Firewall Services 编辑权限
+}
+
+// CloudflareAdapter 通过 Cloudflare IP Access Rules 在边缘封禁
+type CloudflareAdapter struct {
+	Config     CloudflareConfig
+	Token      string
+	HTTPClient *http.Client
+
+	limiter *rate.Limiter
+}
+
+// CloudflareError Cloudflare API 返回的错误
+type CloudflareError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *CloudflareError) Error() string {
+	return fmt.Sprintf("cloudflare error %d: %s", e.Code, e.Message)
+}
+
+type cloudflareRule struct {
+	ID            string `json:"id"`
+	Mode          string `json:"mode"`
+	Notes         string `json:"notes"`
+	Configuration struct {
+		Target string `json:"target"`
+		Value  string `json:"value"`
+	} `json:"configuration"`
+}
+
+type cloudflareResponse struct {
+	Success    bool              `json:"success"`
+	Errors     []CloudflareError `json:"errors"`
+	Result     json.RawMessage   `json:"result"`
+	ResultInfo *struct {
+		Page       int `json:"page"`
+		TotalPages int `json:"total_pages"`
+	} `json:"result_info"`
+}
+
+// NewCloudflareAdapter 创建 Cloudflare 引擎，token 由调用方从 Secret 中读取
+func NewCloudflareAdapter(cfg CloudflareConfig, token string) (*CloudflareAdapter, error) {
+	if (cfg.ZoneID == "") == (cfg.AccountID == "") {
+		return nil, fmt.Errorf("exactly one of cloudflare zoneID and accountID must be set")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("cloudflare api token is empty")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultCloudflareBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Mode == "" {
+		cfg.Mode = "block"
+	}
+	return &CloudflareAdapter{
+		Config:     cfg,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		limiter:    rate.NewLimiter(cloudflareRPS, cloudflareRPS),
+	}, nil
+}
+
+func (c *CloudflareAdapter) Ban(req BanRequest) (string, error) {
+	target, value, err := cloudflareTarget(req.IP)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cloudflareTimeout)
+	defer cancel()
+
+	body := map[string]interface{}{
+		"mode":          c.Config.Mode,
+		"configuration": map[string]string{"target": target, "value": value},
+		"notes":         cloudflareNotes,
+	}
+	var rule cloudflareRule
+	if err := c.do(ctx, http.MethodPost, c.rulesPath(), nil, body, &rule); err != nil {
+		var cfErr *CloudflareError
+		if errors.As(err, &cfErr) && cfErr.Code == cloudflareDuplicateCode {
+			return fmt.Sprintf("Cloudflare access rule for %s already exists", value), nil
+		}
+		return "", err
+	}
+	return fmt.Sprintf("Cloudflare access rule %s created for %s", rule.ID, value), nil
+}
+
+// UnBan 删除本引擎为该 IP 创建的规则，不存在时视为成功
+func (c *CloudflareAdapter) UnBan(req BanRequest) (string, error) {
+	_, value, err := cloudflareTarget(req.IP)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cloudflareTimeout)
+	defer cancel()
+
+	rules, err := c.listRules(ctx, url.Values{"configuration.value": {value}})
+	if err != nil {
+		return "", err
+	}
+	deleted := 0
+	for _, rule := range rules {
+		if rule.Configuration.Value != value {
+			continue
+		}
+		if err := c.do(ctx, http.MethodDelete, c.rulesPath()+"/"+rule.ID, nil, nil, nil); err != nil {
+			return "", err
+		}
+		deleted++
+	}
+	if deleted == 0 {
+		return fmt.Sprintf("Cloudflare access rule for %s not exists", value), nil
+	}
+	return fmt.Sprintf("Cloudflare access rule for %s deleted", value), nil
+}
+
+// 分页拉取规则，只返回本引擎创建的规则
+func (c *CloudflareAdapter) listRules(ctx context.Context, filter url.Values) ([]cloudflareRule, error) {
+	var all []cloudflareRule
+	for page := 1; ; page++ {
+		query := url.Values{}
+		for k, v := range filter {
+			query[k] = v
+		}
+		query.Set("notes", cloudflareNotes)
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", strconv.Itoa(cloudflarePerPage))
+
+		var rules []cloudflareRule
+		resp, err := c.request(ctx, http.MethodGet, c.rulesPath(), query, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(resp.Result, &rules); err != nil {
+			return nil, fmt.Errorf("decode cloudflare rules failed: %w", err)
+		}
+		for _, rule := range rules {
+			if rule.Notes == cloudflareNotes {
+				all = append(all, rule)
+			}
+		}
+
+		if resp.ResultInfo == nil || page >= resp.ResultInfo.TotalPages || len(rules) == 0 {
+			return all, nil
+		}
+	}
+}
+
+func (c *CloudflareAdapter) rulesPath() string {
+	if c.Config.ZoneID != "" {
+		return "/zones/" + c.Config.ZoneID + "/firewall/access_rules/rules"
+	}
+	return "/accounts/" + c.Config.AccountID + "/firewall/access_rules/rules"
+}
+
+func (c *CloudflareAdapter) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	resp, err := c.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, out)
+}
+
+// 发送请求：客户端限速，遇到 429 和 5xx 按 Retry-After 或指数退避重试
+func (c *CloudflareAdapter) request(ctx context.Context, method, path string, query url.Values, body interface{}) (*cloudflareResponse, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	u := c.Config.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+		req.Header.Set("Content-Type", "application/json")
+
+		httpResp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("call cloudflare api failed: %w", err)
+		}
+		data, err := io.ReadAll(httpResp.Body)
+		httpResp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode >= 500 {
+			if attempt >= cloudflareMaxAttempts {
+				return nil, fmt.Errorf("cloudflare api %s %s: status %d after %d attempts", method, path, httpResp.StatusCode, attempt)
+			}
+			wait := backoff
+			if s, err := strconv.Atoi(httpResp.Header.Get("Retry-After")); err == nil && s >= 0 {
+				wait = time.Duration(s) * time.Second
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+			backoff *= 2
+			continue
+		}
+
+		var resp cloudflareResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("decode cloudflare response failed (status %d): %w", httpResp.StatusCode, err)
+		}
+		if !resp.Success {
+			if len(resp.Errors) > 0 {
+				return nil, &resp.Errors[0]
+			}
+			return nil, fmt.Errorf("cloudflare api %s %s failed: status %d", method, path, httpResp.StatusCode)
+		}
+		return &resp, nil
+	}
+}
+
+// 单个 IP 使用 ip / ip6 目标；网段只支持 Cloudflare 允许的 IPv4 /16、/24 和 IPv6 /32、/48、/64
+func cloudflareTarget(ip string) (target, value string, err error) {
+	cidr, err := blockCIDR(ip)
+	if err != nil {
+		return "", "", err
+	}
+	p := netip.MustParsePrefix(cidr)
+	switch {
+	case p.Addr().Is4() && p.Bits() == 32:
+		return "ip", p.Addr().String(), nil
+	case p.Addr().Is6() && p.Bits() == 128:
+		return "ip6", p.Addr().String(), nil
+	case p.Addr().Is4() && (p.Bits() == 16 || p.Bits() == 24),
+		p.Addr().Is6() && (p.Bits() == 32 || p.Bits() == 48 || p.Bits() == 64):
+		return "ip_range", cidr, nil
+	default:
+		return "", "", fmt.Errorf("cloudflare does not support blocking range '%s'", cidr)
+	}
+}
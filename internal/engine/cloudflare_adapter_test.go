@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// cloudflareStub 模拟 Cloudflare IP Access Rules API
+type cloudflareStub struct {
+	mu        sync.Mutex
+	rules     []cloudflareRule
+	nextID    int
+	throttle  int // 剩余需要返回 429 的请求数
+	throttled int
+}
+
+func (s *cloudflareStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "errors": []CloudflareError{{Code: 10000, Message: "Authentication error"}}})
+		return
+	}
+	if s.throttle > 0 {
+		s.throttle--
+		s.throttled++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	const base = "/zones/zone1/firewall/access_rules/rules"
+	reply := func(result interface{}, info map[string]int) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "errors": []CloudflareError{}, "result": result, "result_info": info})
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == base:
+		q := r.URL.Query()
+		var matched []cloudflareRule
+		for _, rule := range s.rules {
+			if v := q.Get("configuration.value"); v != "" && rule.Configuration.Value != v {
+				continue
+			}
+			if n := q.Get("notes"); n != "" && !strings.Contains(rule.Notes, n) {
+				continue
+			}
+			matched = append(matched, rule)
+		}
+		page, _ := strconv.Atoi(q.Get("page"))
+		perPage, _ := strconv.Atoi(q.Get("per_page"))
+		start, end := (page-1)*perPage, page*perPage
+		if start > len(matched) {
+			start = len(matched)
+		}
+		if end > len(matched) {
+			end = len(matched)
+		}
+		reply(matched[start:end], map[string]int{"page": page, "total_pages": (len(matched) + perPage - 1) / perPage})
+	case r.Method == http.MethodPost && r.URL.Path == base:
+		var rule cloudflareRule
+		_ = json.NewDecoder(r.Body).Decode(&rule)
+		for _, existing := range s.rules {
+			if existing.Configuration.Value == rule.Configuration.Value {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "errors": []CloudflareError{{Code: cloudflareDuplicateCode, Message: "duplicate"}}})
+				return
+			}
+		}
+		s.nextID++
+		rule.ID = fmt.Sprintf("rule-%d", s.nextID)
+		s.rules = append(s.rules, rule)
+		reply(rule, nil)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, base+"/"):
+		id := strings.TrimPrefix(r.URL.Path, base+"/")
+		for i, rule := range s.rules {
+			if rule.ID == id {
+				s.rules = append(s.rules[:i], s.rules[i+1:]...)
+				reply(map[string]string{"id": id}, nil)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "errors": []CloudflareError{{Code: 10001, Message: "not found"}}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *cloudflareStub) add(value, notes string) {
+	s.nextID++
+	rule := cloudflareRule{ID: fmt.Sprintf("rule-%d", s.nextID), Mode: "block", Notes: notes}
+	rule.Configuration.Target = "ip"
+	rule.Configuration.Value = value
+	s.rules = append(s.rules, rule)
+}
+
+func newCloudflareTestAdapter(t *testing.T, stub *cloudflareStub) *CloudflareAdapter {
+	t.Helper()
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	adapter, err := NewCloudflareAdapter(CloudflareConfig{BaseURL: srv.URL, ZoneID: "zone1"}, "test-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return adapter
+}
+
+func TestCloudflareBanAndUnBan(t *testing.T) {
+	stub := &cloudflareStub{throttle: 1}
+	stub.add("192.0.2.1", "added by hand")
+	adapter := newCloudflareTestAdapter(t, stub)
+
+	if _, err := adapter.Ban(BanRequest{IP: "203.0.113.5", Permanent: true}); err != nil {
+		t.Fatal(err)
+	}
+	if stub.throttled != 1 {
+		t.Errorf("expected one throttled request, got %d", stub.throttled)
+	}
+	// 重复封禁视为成功
+	if _, err := adapter.Ban(BanRequest{IP: "203.0.113.5/32", Permanent: true}); err != nil {
+		t.Fatalf("duplicate ban: %v", err)
+	}
+	if _, err := adapter.Ban(BanRequest{IP: "10.1.0.0/16", Permanent: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adapter.Ban(BanRequest{IP: "10.1.2.0/23", Permanent: true}); err == nil {
+		t.Error("expected error for unsupported range")
+	}
+
+	if _, err := adapter.UnBan(BanRequest{IP: "203.0.113.5"}); err != nil {
+		t.Fatal(err)
+	}
+	// 非本引擎创建的规则不删除
+	if msg, err := adapter.UnBan(BanRequest{IP: "192.0.2.1"}); err != nil || !strings.Contains(msg, "not exists") {
+		t.Fatalf("unban unmanaged rule = %q, %v", msg, err)
+	}
+
+	var values []string
+	for _, rule := range stub.rules {
+		values = append(values, rule.Configuration.Value)
+	}
+	sort.Strings(values)
+	if strings.Join(values, ",") != "10.1.0.0/16,192.0.2.1" {
+		t.Errorf("remaining rules = %v", values)
+	}
+}
+
+func TestCloudflareListPaginates(t *testing.T) {
+	stub := &cloudflareStub{}
+	for i := 0; i < 150; i++ {
+		stub.add(fmt.Sprintf("198.51.100.%d", i), cloudflareNotes)
+	}
+	stub.add("192.0.2.1", "added by hand")
+	adapter := newCloudflareTestAdapter(t, stub)
+
+	rules, err := adapter.listRules(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 150 {
+		t.Fatalf("listed %d rules, want 150", len(rules))
+	}
+}
+
+func TestNewCloudflareAdapterValidates(t *testing.T) {
+	if _, err := NewCloudflareAdapter(CloudflareConfig{}, "token"); err == nil {
+		t.Error("expected error without zoneID/accountID")
+	}
+	if _, err := NewCloudflareAdapter(CloudflareConfig{ZoneID: "z"}, ""); err == nil {
+		t.Error("expected error without token")
+	}
+}
@@ -0,0 +1,308 @@
+package engine
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// 组合引擎的失败策略
+const (
+	// CompositePolicyAll 所有选中的后端都成功才视为成功
+	CompositePolicyAll = "all"
+	// CompositePolicyBestEffort 至少一个选中的后端成功即视为成功，失败的后端记录在状态中
+	CompositePolicyBestEffort = "best-effort"
+)
+
+// CompositeBackendConfig 组合引擎中的一个后端，sources 和 tags 同时配置时需同时满足
+type CompositeBackendConfig struct {
+	Engine  string   `json:"engine"`
+	Sources []string `json:"sources,omitempty"` // 只处理来源匹配的封禁，支持通配符如 feed/*，为空时不限制
+	Tags    []string `json:"tags,omitempty"`    // 只处理带有其中任一标签的封禁，为空时不限制
+}
+
+// CompositeConfig 组合引擎配置（ConfigMap 的 composite 字段），各后端的配置仍读取各自的字段
+type CompositeConfig struct {
+	Policy   string                   `json:"policy,omitempty"` // all（默认）或 best-effort
+	Backends []CompositeBackendConfig `json:"backends"`
+}
+
+// CompositeBackend 已创建的后端
+type CompositeBackend struct {
+	CompositeBackendConfig
+	Adapter Adapter
+}
+
+// CompositeAdapter 把一次封禁并发下发到多个后端，例如同时在 Cloudflare 边缘和 XDP 网关封禁。
+// 后端按请求的来源和标签选择，解封使用同一请求，因此会落到封禁时的同一组后端
+type CompositeAdapter struct {
+	Policy   string
+	Backends []CompositeBackend
+}
+
+// NewCompositeAdapter 创建组合引擎
+func NewCompositeAdapter(policy string, backends []CompositeBackend) (*CompositeAdapter, error) {
+	switch policy {
+	case "":
+		policy = CompositePolicyAll
+	case CompositePolicyAll, CompositePolicyBestEffort:
+	default:
+		return nil, fmt.Errorf("unknown composite policy '%s'", policy)
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("composite engine requires at least one backend")
+	}
+
+	seen := make(map[string]struct{}, len(backends))
+	for _, b := range backends {
+		if b.Engine == CompositeEngine {
+			return nil, fmt.Errorf("composite engine cannot be nested")
+		}
+		// 同一引擎的配置只有一份，重复出现没有意义
+		if _, ok := seen[b.Engine]; ok {
+			return nil, fmt.Errorf("duplicate composite backend '%s'", b.Engine)
+		}
+		seen[b.Engine] = struct{}{}
+		for _, pattern := range b.Sources {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid source pattern '%s' for backend '%s'", pattern, b.Engine)
+			}
+		}
+	}
+	return &CompositeAdapter{Policy: policy, Backends: backends}, nil
+}
+
+func (c *CompositeAdapter) Ban(req BanRequest) (string, error) {
+	msg, _, err := c.BanEach(req)
+	return msg, err
+}
+
+func (c *CompositeAdapter) UnBan(req BanRequest) (string, error) {
+	msg, _, err := c.UnBanEach(req)
+	return msg, err
+}
+
+// BanEach 封禁并返回各后端的结果。all 策略下部分后端失败时，已成功的后端会被解封回滚，
+// 使失败的封禁不在任何后端生效；回滚本身失败时记录在对应后端的结果中
+func (c *CompositeAdapter) BanEach(req BanRequest) (string, []BackendResult, error) {
+	return c.each(req, Adapter.Ban, Adapter.UnBan)
+}
+
+func (c *CompositeAdapter) UnBanEach(req BanRequest) (string, []BackendResult, error) {
+	return c.each(req, Adapter.UnBan, nil)
+}
+
+// 并发调用所有选中的后端，结果按配置顺序返回。rollback 不为空时按失败策略撤销已成功的后端
+func (c *CompositeAdapter) each(req BanRequest, op, rollback func(Adapter, BanRequest) (string, error)) (string, []BackendResult, error) {
+	selected := c.selectBackends(req)
+	if len(selected) == 0 {
+		return "", nil, fmt.Errorf("no composite backend matches source '%s' and tags [%s]", req.Source, strings.Join(req.Tags, ","))
+	}
+
+	results := make([]BackendResult, len(selected))
+	var wg sync.WaitGroup
+	for i, b := range selected {
+		wg.Add(1)
+		go func(i int, b CompositeBackend) {
+			defer wg.Done()
+			msg, err := op(b.Adapter, req)
+			results[i] = BackendResult{Engine: b.Engine, Message: msg, Err: err}
+		}(i, b)
+	}
+	wg.Wait()
+
+	if rollback != nil && c.needsRollback(results) {
+		for i, b := range selected {
+			if results[i].Err != nil {
+				continue
+			}
+			wg.Add(1)
+			go func(i int, b CompositeBackend) {
+				defer wg.Done()
+				_, err := rollback(b.Adapter, req)
+				markRolledBack(&results[i], err)
+			}(i, b)
+		}
+		wg.Wait()
+	}
+
+	msg, err := c.summarize(results)
+	return msg, results, err
+}
+
+func (c *CompositeAdapter) BanBatch(reqs []BanRequest) []BatchResult {
+	return c.eachBatch(reqs, BanBatch, UnBanBatch)
+}
+
+func (c *CompositeAdapter) UnBanBatch(reqs []BanRequest) []BatchResult {
+	return c.eachBatch(reqs, UnBanBatch, nil)
+}
+
+// 每个后端对选中它的请求做一次批量调用，各后端并发执行，再按请求汇总。
+// rollback 不为空时，对按失败策略需要撤销的请求在已成功的后端上再做一次批量回滚
+func (c *CompositeAdapter) eachBatch(reqs []BanRequest, op, rollback func(Adapter, []BanRequest) []BatchResult) []BatchResult {
+	// 每个后端选中的请求下标及其结果
+	selected := make([][]int, len(c.Backends))
+	for i, req := range reqs {
+		for bi, b := range c.Backends {
+			if matchSource(b.Sources, req.Source) && matchTags(b.Tags, req.Tags) {
+				selected[bi] = append(selected[bi], i)
+			}
+		}
+	}
+	backendResults := make([][]BatchResult, len(c.Backends))
+	var wg sync.WaitGroup
+	for bi, b := range c.Backends {
+		if len(selected[bi]) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(bi int, b CompositeBackend) {
+			defer wg.Done()
+			sub := make([]BanRequest, len(selected[bi]))
+			for j, i := range selected[bi] {
+				sub[j] = reqs[i]
+			}
+			backendResults[bi] = op(b.Adapter, sub)
+		}(bi, b)
+	}
+	wg.Wait()
+
+	// position[bi][j] 为后端 bi 的第 j 个请求在 perRequest 中的位置
+	perRequest := make([][]BackendResult, len(reqs))
+	position := make([][]int, len(c.Backends))
+	for bi, b := range c.Backends {
+		for j, i := range selected[bi] {
+			r := backendResults[bi][j]
+			position[bi] = append(position[bi], len(perRequest[i]))
+			perRequest[i] = append(perRequest[i], BackendResult{Engine: b.Engine, Message: r.Message, Err: r.Err})
+		}
+	}
+
+	if rollback != nil {
+		c.rollbackBatch(reqs, selected, position, perRequest, rollback)
+	}
+
+	results := make([]BatchResult, len(reqs))
+	for i, req := range reqs {
+		if len(perRequest[i]) == 0 {
+			results[i].Err = fmt.Errorf("no composite backend matches source '%s' and tags [%s]", req.Source, strings.Join(req.Tags, ","))
+			continue
+		}
+		msg, err := c.summarize(perRequest[i])
+		results[i] = BatchResult{Message: msg, Err: err, Backends: perRequest[i]}
+	}
+	return results
+}
+
+// 对需要回滚的请求，每个后端把其中自己成功的部分做一次批量回滚
+func (c *CompositeAdapter) rollbackBatch(reqs []BanRequest, selected, position [][]int, perRequest [][]BackendResult, rollback func(Adapter, []BanRequest) []BatchResult) {
+	// 先确定需要回滚的请求，回滚过程会改写结果
+	undo := make([]bool, len(reqs))
+	for i := range reqs {
+		undo[i] = c.needsRollback(perRequest[i])
+	}
+	var wg sync.WaitGroup
+	for bi, b := range c.Backends {
+		var sub []BanRequest
+		var targets []*BackendResult
+		for j, i := range selected[bi] {
+			r := &perRequest[i][position[bi][j]]
+			if undo[i] && r.Err == nil {
+				sub = append(sub, reqs[i])
+				targets = append(targets, r)
+			}
+		}
+		if len(sub) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(b CompositeBackend, sub []BanRequest, targets []*BackendResult) {
+			defer wg.Done()
+			for k, r := range rollback(b.Adapter, sub) {
+				markRolledBack(targets[k], r.Err)
+			}
+		}(b, sub, targets)
+	}
+	wg.Wait()
+}
+
+// all 策略下部分后端失败时需要撤销其余后端，全部失败时没有需要撤销的后端
+func (c *CompositeAdapter) needsRollback(results []BackendResult) bool {
+	if c.Policy != CompositePolicyAll {
+		return false
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	return failed > 0 && failed < len(results)
+}
+
+// 把回滚结果记录到后端结果中：回滚成功的后端视为未生效，回滚失败的后端仍在生效，两者都计为失败
+func markRolledBack(r *BackendResult, err error) {
+	if err != nil {
+		r.Err = fmt.Errorf("rollback failed, still enforced: %w", err)
+		return
+	}
+	r.Err = fmt.Errorf("rolled back: %s", r.Message)
+}
+
+// 按失败策略汇总各后端的结果
+func (c *CompositeAdapter) summarize(results []BackendResult) (string, error) {
+	var parts []string
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			parts = append(parts, fmt.Sprintf("%s: %v", r.Engine, r.Err))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %s", r.Engine, r.Message))
+		}
+	}
+	msg := strings.Join(parts, "; ")
+
+	if failed == len(results) || (failed > 0 && c.Policy == CompositePolicyAll) {
+		return msg, fmt.Errorf("%d of %d backends failed: %s", failed, len(results), msg)
+	}
+	return msg, nil
+}
+
+func (c *CompositeAdapter) selectBackends(req BanRequest) []CompositeBackend {
+	var selected []CompositeBackend
+	for _, b := range c.Backends {
+		if matchSource(b.Sources, req.Source) && matchTags(b.Tags, req.Tags) {
+			selected = append(selected, b)
+		}
+	}
+	return selected
+}
+
+func matchSource(patterns []string, source string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, source); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTags(want, tags []string) bool {
+	if len(want) == 0 {
+		return true
+	}
+	for _, w := range want {
+		for _, t := range tags {
+			if w == t {
+				return true
+			}
+		}
+	}
+	return false
+}
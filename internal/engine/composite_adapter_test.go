@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingAdapter 记录收到的请求，fail 为 true 时返回错误
+type recordingAdapter struct {
+	mu       sync.Mutex
+	fail     bool
+	banned   []string
+	unbanned []string
+}
+
+func (a *recordingAdapter) Ban(req BanRequest) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.fail {
+		return "", errors.New("backend down")
+	}
+	a.banned = append(a.banned, req.IP)
+	return "banned " + req.IP, nil
+}
+
+func (a *recordingAdapter) UnBan(req BanRequest) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.fail {
+		return "", errors.New("backend down")
+	}
+	a.unbanned = append(a.unbanned, req.IP)
+	return "unbanned " + req.IP, nil
+}
+
+func TestCompositeSelectsBackends(t *testing.T) {
+	edge, gateway, feed := &recordingAdapter{}, &recordingAdapter{}, &recordingAdapter{}
+	c, err := NewCompositeAdapter("", []CompositeBackend{
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: CloudflareEngine, Tags: []string{"edge"}}, Adapter: edge},
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine}, Adapter: gateway},
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: NginxEngine, Sources: []string{"feed/*"}}, Adapter: feed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, results, err := c.BanEach(BanRequest{IP: "10.0.0.1", Source: "grafana", Tags: []string{"edge", "scan"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Engine != CloudflareEngine || results[1].Engine != XDPEngine {
+		t.Errorf("unexpected backends: %+v", results)
+	}
+
+	if _, err := c.Ban(BanRequest{IP: "10.0.0.2", Source: "feed/spamhaus"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(edge.banned) != 1 || len(gateway.banned) != 2 || len(feed.banned) != 1 {
+		t.Errorf("edge=%v gateway=%v feed=%v", edge.banned, gateway.banned, feed.banned)
+	}
+}
+
+func TestCompositePolicies(t *testing.T) {
+	backends := func() []CompositeBackend {
+		return []CompositeBackend{
+			{CompositeBackendConfig: CompositeBackendConfig{Engine: CloudflareEngine}, Adapter: &recordingAdapter{fail: true}},
+			{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine}, Adapter: &recordingAdapter{}},
+		}
+	}
+
+	all, err := NewCompositeAdapter(CompositePolicyAll, backends())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, results, err := all.BanEach(BanRequest{IP: "10.0.0.1"})
+	if err == nil {
+		t.Error("policy all: expected error when one backend fails")
+	}
+	// 成功的后端已被回滚，同样记为失败
+	if len(results) != 2 || results[0].Err == nil || results[1].Err == nil || results[1].Message == "" {
+		t.Errorf("policy all: unexpected results %+v", results)
+	}
+
+	bestEffort, err := NewCompositeAdapter(CompositePolicyBestEffort, backends())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := bestEffort.UnBanEach(BanRequest{IP: "10.0.0.1"}); err != nil {
+		t.Errorf("policy best-effort: unexpected error %v", err)
+	}
+
+	failing := []CompositeBackend{
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine}, Adapter: &recordingAdapter{fail: true}},
+	}
+	bestEffort, _ = NewCompositeAdapter(CompositePolicyBestEffort, failing)
+	if _, err := bestEffort.Ban(BanRequest{IP: "10.0.0.1"}); err == nil {
+		t.Error("policy best-effort: expected error when every backend fails")
+	}
+}
+
+// all 策略下部分后端失败时，已成功的后端被解封回滚
+func TestCompositeRollsBackPartialBan(t *testing.T) {
+	edge, gateway := &recordingAdapter{fail: true}, &recordingAdapter{}
+	backends := []CompositeBackend{
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: CloudflareEngine, Tags: []string{"edge"}}, Adapter: edge},
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine}, Adapter: gateway},
+	}
+
+	all, _ := NewCompositeAdapter(CompositePolicyAll, backends)
+	_, results, err := all.BanEach(BanRequest{IP: "10.0.0.1", Tags: []string{"edge"}})
+	if err == nil {
+		t.Fatal("expected error when one backend fails")
+	}
+	if len(results) != 2 || results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "rolled back") {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if len(gateway.unbanned) != 1 || gateway.unbanned[0] != "10.0.0.1" {
+		t.Errorf("gateway unbanned = %v", gateway.unbanned)
+	}
+
+	// 批量封禁只回滚失败的请求，只落到 xdp 的请求不受影响
+	gateway.unbanned = nil
+	batch := all.BanBatch([]BanRequest{{IP: "10.0.0.2", Tags: []string{"edge"}}, {IP: "10.0.0.3"}})
+	if batch[0].Err == nil || batch[1].Err != nil {
+		t.Errorf("batch results %+v", batch)
+	}
+	if len(gateway.unbanned) != 1 || gateway.unbanned[0] != "10.0.0.2" {
+		t.Errorf("gateway unbanned = %v", gateway.unbanned)
+	}
+
+	// 解封失败和 best-effort 策略都不回滚
+	gateway.unbanned = nil
+	if _, err := all.UnBan(BanRequest{IP: "10.0.0.1", Tags: []string{"edge"}}); err == nil {
+		t.Error("expected unban error")
+	}
+	bestEffort, _ := NewCompositeAdapter(CompositePolicyBestEffort, backends)
+	if _, err := bestEffort.Ban(BanRequest{IP: "10.0.0.4", Tags: []string{"edge"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(gateway.unbanned) != 1 || gateway.unbanned[0] != "10.0.0.1" {
+		t.Errorf("gateway unbanned = %v", gateway.unbanned)
+	}
+}
+
+func TestCompositeValidation(t *testing.T) {
+	a := &recordingAdapter{}
+	cases := map[string][]CompositeBackend{
+		"no backends": nil,
+		"duplicate": {
+			{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine}, Adapter: a},
+			{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine}, Adapter: a},
+		},
+		"nested": {
+			{CompositeBackendConfig: CompositeBackendConfig{Engine: CompositeEngine}, Adapter: a},
+		},
+		"bad pattern": {
+			{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine, Sources: []string{"["}}, Adapter: a},
+		},
+	}
+	for name, backends := range cases {
+		if _, err := NewCompositeAdapter("", backends); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := NewCompositeAdapter("most", []CompositeBackend{{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine}, Adapter: a}}); err == nil {
+		t.Error("unknown policy: expected error")
+	}
+
+	c, _ := NewCompositeAdapter("", []CompositeBackend{
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine, Tags: []string{"edge"}}, Adapter: a},
+	})
+	if _, err := c.Ban(BanRequest{IP: "10.0.0.1"}); err == nil {
+		t.Error("expected error when no backend matches")
+	}
+
+	if _, err := NewAdapter("xpd", "gateway:8080"); err == nil {
+		t.Error("expected error for unknown engine name")
+	}
+}
+
+// healthAdapter 的健康状态由 down 决定
+type healthAdapter struct {
+	recordingAdapter
+	down bool
+}
+
+func (a *healthAdapter) Health(ctx context.Context) error {
+	if a.down {
+		return errors.New("unreachable")
+	}
+	return nil
+}
+
+func TestCompositeHealthFollowsPolicy(t *testing.T) {
+	edge, gateway := &healthAdapter{}, &healthAdapter{down: true}
+	backends := []CompositeBackend{
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: CloudflareEngine}, Adapter: edge},
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: XDPEngine}, Adapter: gateway},
+		// 未实现 HealthChecker 的后端视为可达
+		{CompositeBackendConfig: CompositeBackendConfig{Engine: NginxEngine}, Adapter: &recordingAdapter{}},
+	}
+
+	all, _ := NewCompositeAdapter(CompositePolicyAll, backends)
+	if err := CheckHealth(context.Background(), all); err == nil || !strings.Contains(err.Error(), "xdp: unreachable") {
+		t.Errorf("all policy health = %v", err)
+	}
+	bestEffort, _ := NewCompositeAdapter(CompositePolicyBestEffort, backends[:2])
+	if err := CheckHealth(context.Background(), bestEffort); err != nil {
+		t.Errorf("best-effort health = %v", err)
+	}
+	edge.down = true
+	if err := CheckHealth(context.Background(), bestEffort); err == nil {
+		t.Error("best-effort health should fail when every backend is down")
+	}
+}
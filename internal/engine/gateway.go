@@ -0,0 +1,364 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 网关协议
+const (
+	// GatewayProtocolGet 旧协议：GET /update、/remove、/limit、/unlimit，参数放在查询串中
+	GatewayProtocolGet = "get"
+	// GatewayProtocolPost JSON 协议：POST /v1/ban、/v1/unban、/v1/limit、/v1/unlimit
+	GatewayProtocolPost = "post"
+
+	// RequestIDHeader 携带请求 ID，网关据此对重试的请求去重
+	RequestIDHeader = "X-Request-ID"
+
+	gatewayTimeout     = 10 * time.Second
+	gatewayMaxAttempts = 3
+	gatewayMaxBatch    = 500 // 单次批量请求的最大条数，超出时分多次提交
+)
+
+// GatewayConfig xdp、iptables 引擎调用网关的方式（ConfigMap 的 gateway 字段）
+type GatewayConfig struct {
+	Protocol    string            `json:"protocol,omitempty"`    // get（默认）或 post
+	TLS         *GatewayTLSConfig `json:"tls,omitempty"`         // 配置后使用 HTTPS
+	TokenSecret *SecretKeyRef     `json:"tokenSecret,omitempty"` // Bearer Token 所在 Secret
+	Timeout     string            `json:"timeout,omitempty"`     // 单次请求超时，默认 10s
+}
+
+// GatewayTLSConfig 网关的 TLS 配置
+type GatewayTLSConfig struct {
+	CASecret           *SecretKeyRef `json:"caSecret,omitempty"`         // 校验网关证书的 CA，为空时使用系统 CA
+	ClientCertSecret   *SecretRef    `json:"clientCertSecret,omitempty"` // kubernetes.io/tls 类型的 Secret，用于双向 TLS
+	ServerName         string        `json:"serverName,omitempty"`
+	InsecureSkipVerify bool          `json:"insecureSkipVerify,omitempty"`
+}
+
+// GatewayCredentials 从 Secret 中读取的凭据，均为 PEM 或明文
+type GatewayCredentials struct {
+	Token string
+	CA    []byte
+	Cert  []byte
+	Key   []byte
+}
+
+// GatewayRequest POST 协议的请求体
+type GatewayRequest struct {
+	RequestID       string `json:"requestId"`
+	CIDR            string `json:"cidr"`
+	Permanent       bool   `json:"permanent,omitempty"`
+	DurationSeconds int    `json:"durationSeconds,omitempty"`
+	PPS             int64  `json:"pps,omitempty"`
+	BPS             int64  `json:"bps,omitempty"`
+}
+
+// GatewayResponse POST 协议的应答，Status 为 ok 或 error
+type GatewayResponse struct {
+	RequestID string `json:"requestId"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+}
+
+// GatewayBatchRequest POST /v1/batch 的请求体，Items 中的操作相同，其 requestId 可以为空
+type GatewayBatchRequest struct {
+	RequestID string           `json:"requestId"`
+	Op        string           `json:"op"` // ban、unban、limit、unlimit
+	Items     []GatewayRequest `json:"items"`
+}
+
+// GatewayBatchResponse 批量应答，Results 与请求的 Items 一一对应
+type GatewayBatchResponse struct {
+	RequestID string            `json:"requestId"`
+	Results   []GatewayResponse `json:"results"`
+}
+
+// GatewayClient 调用部署在网关上的封禁后端
+type GatewayClient struct {
+	Host       string // host:port，也可以带 http:// 或 https:// 前缀
+	Protocol   string
+	Token      string
+	HTTPClient *http.Client
+
+	scheme  string
+	noBatch atomic.Bool // 网关不支持 /v1/batch，之后的批量操作直接逐个调用
+}
+
+// GatewayStatusError 网关返回的非 2xx 应答
+type GatewayStatusError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *GatewayStatusError) Error() string {
+	return fmt.Sprintf("网关返回 %s: %s", e.Status, e.Message)
+}
+
+// NewGatewayClient 按配置创建网关客户端
+func NewGatewayClient(host string, cfg GatewayConfig, creds GatewayCredentials) (*GatewayClient, error) {
+	c := &GatewayClient{Host: host, Protocol: cfg.Protocol, Token: creds.Token, scheme: "http"}
+	switch c.Protocol {
+	case "":
+		c.Protocol = GatewayProtocolGet
+	case GatewayProtocolGet, GatewayProtocolPost:
+	default:
+		return nil, fmt.Errorf("unknown gateway protocol '%s'", cfg.Protocol)
+	}
+
+	timeout := gatewayTimeout
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid gateway timeout '%s'", cfg.Timeout)
+		}
+		timeout = d
+	}
+	c.HTTPClient = &http.Client{Timeout: timeout}
+
+	if cfg.TLS != nil {
+		tlsConfig := &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			ServerName:         cfg.TLS.ServerName,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify, //nolint:gosec // 由用户显式开启
+		}
+		if len(creds.CA) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(creds.CA) {
+				return nil, fmt.Errorf("no valid certificate found in gateway CA")
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if len(creds.Cert) > 0 {
+			cert, err := tls.X509KeyPair(creds.Cert, creds.Key)
+			if err != nil {
+				return nil, fmt.Errorf("load gateway client certificate failed: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		c.HTTPClient.Transport = transport
+		c.scheme = "https"
+	}
+	return c, nil
+}
+
+// 未通过 NewGatewayClient 创建时，按旧协议以 HTTP 调用
+func (c *GatewayClient) protocol() string {
+	if c.Protocol == "" {
+		return GatewayProtocolGet
+	}
+	return c.Protocol
+}
+
+func (c *GatewayClient) url(path string, query url.Values) string {
+	base := c.Host
+	if !strings.Contains(base, "://") {
+		scheme := c.scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		base = scheme + "://" + base
+	}
+	u := strings.TrimSuffix(base, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+func (c *GatewayClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return &http.Client{Timeout: gatewayTimeout}
+}
+
+func (c *GatewayClient) authorize(req *http.Request) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+}
+
+// get 旧协议，应答解码到 out
+func (c *GatewayClient) get(path string, query url.Values, out any) error {
+	u := c.url(path, query)
+	log.Printf("调用网关接口: %s", u)
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	c.authorize(req)
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("网关拒绝请求: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析返回信息失败: %v", err)
+	}
+	return nil
+}
+
+// post JSON 协议。网络错误、5xx 和 429 时以同一个请求 ID 重试，网关据此去重
+func (c *GatewayClient) post(path string, body GatewayRequest) (string, error) {
+	if body.RequestID == "" {
+		body.RequestID = NewRequestID()
+	}
+	log.Printf("调用网关接口: POST %s %s (request %s)", c.url(path, nil), body.CIDR, body.RequestID)
+
+	var result GatewayResponse
+	if err := c.postJSON(path, body.RequestID, body, &result); err != nil {
+		return result.Message, err
+	}
+	if result.Status != "ok" {
+		return result.Message, errors.New(result.Message)
+	}
+	return result.Message, nil
+}
+
+// batch 一次提交多个同类操作，返回的结果与 items 一一对应
+func (c *GatewayClient) batch(op string, items []GatewayRequest) ([]GatewayResponse, error) {
+	body := GatewayBatchRequest{RequestID: NewRequestID(), Op: op, Items: items}
+	log.Printf("调用网关接口: POST %s %s x%d (request %s)", c.url("/v1/batch", nil), op, len(items), body.RequestID)
+
+	var result GatewayBatchResponse
+	if err := c.postJSON("/v1/batch", body.RequestID, body, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Results) != len(items) {
+		return nil, fmt.Errorf("网关返回 %d 个结果，请求了 %d 个", len(result.Results), len(items))
+	}
+	return result.Results, nil
+}
+
+// 通过 /v1/batch 批量提交，旧协议的网关没有批量接口，逐个调用 fallback；
+// POST 协议的网关返回 404 / 405 时说明不支持批量接口（旧版本或第三方实现），记住后同样逐个调用
+func (c *GatewayClient) batchResults(op string, reqs []BanRequest, item func(BanRequest) GatewayRequest,
+	fallback func(BanRequest) (string, error), failure string) []BatchResult {
+	if c.protocol() != GatewayProtocolPost || c.noBatch.Load() {
+		return eachRequest(reqs, singleResult(fallback))
+	}
+
+	results := make([]BatchResult, 0, len(reqs))
+	for start := 0; start < len(reqs); start += gatewayMaxBatch {
+		chunk := reqs[start:min(start+gatewayMaxBatch, len(reqs))]
+		items := make([]GatewayRequest, len(chunk))
+		for i, req := range chunk {
+			items[i] = item(req)
+		}
+		responses, err := c.batch(op, items)
+		var statusErr *GatewayStatusError
+		if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusMethodNotAllowed) {
+			log.Printf("网关不支持批量接口，改为逐个调用: %v", err)
+			c.noBatch.Store(true)
+			return append(results, eachRequest(reqs[start:], singleResult(fallback))...)
+		}
+		for i := range chunk {
+			switch {
+			case err != nil:
+				results = append(results, BatchResult{Err: fmt.Errorf("%s: %w", failure, err)})
+			case responses[i].Status != "ok":
+				results = append(results, BatchResult{Message: responses[i].Message, Err: fmt.Errorf("%s: %s", failure, responses[i].Message)})
+			default:
+				results = append(results, BatchResult{Message: responses[i].Message})
+			}
+		}
+	}
+	return results
+}
+
+// postJSON 发送请求并把 2xx 应答解码到 out，失败时按需以同一个请求 ID 重试
+func (c *GatewayClient) postJSON(path, requestID string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	u := c.url(path, nil)
+
+	var lastErr error
+	for attempt := 0; attempt < gatewayMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+		retry, err := c.postOnce(u, requestID, data, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			return err
+		}
+		log.Printf("调用网关接口失败，准备重试 (request %s): %v", requestID, err)
+	}
+	return lastErr
+}
+
+func (c *GatewayClient) postOnce(u, requestID string, data []byte, out any) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(RequestIDHeader, requestID)
+	c.authorize(req)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return true, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var result GatewayResponse
+		if err := json.Unmarshal(raw, &result); err != nil {
+			result.Message = strings.TrimSpace(string(raw))
+		}
+		if r, ok := out.(*GatewayResponse); ok {
+			*r = result
+		}
+		retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, &GatewayStatusError{StatusCode: resp.StatusCode, Status: resp.Status, Message: result.Message}
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		if r, ok := out.(*GatewayResponse); ok {
+			// 兼容返回纯文本的网关：2xx 即视为成功，文本作为返回信息
+			r.Status = "ok"
+			r.Message = strings.TrimSpace(string(raw))
+			return false, nil
+		}
+		return false, fmt.Errorf("解析返回信息失败: %v", err)
+	}
+	return false, nil
+}
+
+// NewRequestID 生成随机的请求 ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
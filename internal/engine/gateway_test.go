@@ -0,0 +1,270 @@
+package engine
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 生成自签名的客户端证书
+func selfSignedClientCert(t *testing.T) (certPEM, keyPEM []byte, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ipblock-operator"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), cert
+}
+
+func TestGatewayPostOverMutualTLS(t *testing.T) {
+	certPEM, keyPEM, clientCert := selfSignedClientCert(t)
+
+	var mu sync.Mutex
+	var requests []GatewayRequest
+	var paths, ids []string
+	calls := 0
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req GatewayRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		requests = append(requests, req)
+		paths = append(paths, r.URL.Path)
+		ids = append(ids, r.Header.Get(RequestIDHeader))
+		// 第一次请求模拟网关故障，客户端应以同一个请求 ID 重试
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(GatewayResponse{RequestID: req.RequestID, Status: "ok", Message: "done"})
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	host := strings.TrimPrefix(srv.URL, "https://")
+	cfg := GatewayConfig{Protocol: GatewayProtocolPost, TLS: &GatewayTLSConfig{}}
+
+	// 没有客户端证书时握手失败
+	noCert, err := NewGatewayClient(host, cfg, GatewayCredentials{Token: "s3cret", CA: caPEM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&XDPAdapter{Client: noCert}).Ban(BanRequest{IP: "192.0.2.1", Permanent: true}); err == nil {
+		t.Fatal("expected handshake failure without client certificate")
+	}
+	calls = 0
+
+	client, err := NewGatewayClient(host, cfg, GatewayCredentials{Token: "s3cret", CA: caPEM, Cert: certPEM, Key: keyPEM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter, err := NewGatewayAdapter(XDPEngine, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := adapter.Ban(BanRequest{IP: "192.0.2.1", DurationSeconds: 600}); err != nil || msg != "done" {
+		t.Fatalf("ban: msg = %q, err = %v", msg, err)
+	}
+	if _, err := adapter.UnBan(BanRequest{IP: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("requests = %+v", requests)
+	}
+	if paths[0] != "/v1/ban" || paths[1] != "/v1/ban" || paths[2] != "/v1/unban" {
+		t.Errorf("paths = %v", paths)
+	}
+	if requests[0].RequestID == "" || requests[0].RequestID != requests[1].RequestID || ids[1] != requests[1].RequestID {
+		t.Errorf("retry should reuse the request id: %v %v", requests[0].RequestID, requests[1].RequestID)
+	}
+	if requests[2].RequestID == requests[0].RequestID {
+		t.Error("different operations should use different request ids")
+	}
+	if r := requests[1]; r.CIDR != "192.0.2.1" || r.Permanent || r.DurationSeconds != 600 {
+		t.Errorf("ban request = %+v", r)
+	}
+}
+
+func TestGatewayPostReportsRejection(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(GatewayResponse{Status: "error", Message: "invalid IP or CIDR"})
+	}))
+	defer srv.Close()
+
+	client, err := NewGatewayClient(srv.URL, GatewayConfig{Protocol: GatewayProtocolPost}, GatewayCredentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = (&IptablesAdapter{Client: client}).Ban(BanRequest{IP: "bogus"})
+	if err == nil || !strings.Contains(err.Error(), "invalid IP or CIDR") {
+		t.Errorf("err = %v", err)
+	}
+	// 客户端错误不重试
+	if calls != 1 {
+		t.Errorf("calls = %d", calls)
+	}
+}
+
+// 返回纯文本的网关，2xx 即视为成功
+func TestGatewayPostAcceptsPlainTextReply(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Successfully added 192.0.2.1\n"))
+	}))
+	defer srv.Close()
+
+	client, err := NewGatewayClient(srv.URL, GatewayConfig{Protocol: GatewayProtocolPost}, GatewayCredentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := (&XDPAdapter{Client: client}).Ban(BanRequest{IP: "192.0.2.1", Permanent: true})
+	if err != nil || msg != "Successfully added 192.0.2.1" {
+		t.Errorf("msg = %q, err = %v", msg, err)
+	}
+}
+
+// 不支持 /v1/batch 的网关逐个调用，之后的批量操作不再尝试批量接口
+func TestGatewayBatchFallsBackWithoutBatchEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	paths := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path != "/v1/ban" {
+			http.NotFound(w, r)
+			return
+		}
+		var req GatewayRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(GatewayResponse{RequestID: req.RequestID, Status: "ok", Message: "banned " + req.CIDR})
+	}))
+	defer srv.Close()
+
+	client, err := NewGatewayClient(srv.URL, GatewayConfig{Protocol: GatewayProtocolPost}, GatewayCredentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	xdp := &XDPAdapter{Client: client}
+	for i := 0; i < 2; i++ {
+		for _, r := range xdp.BanBatch([]BanRequest{{IP: "192.0.2.1", Permanent: true}, {IP: "192.0.2.2", Permanent: true}}) {
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+		}
+	}
+	if paths["/v1/batch"] != 1 || paths["/v1/ban"] != 4 {
+		t.Errorf("calls = %v", paths)
+	}
+}
+
+func TestIptablesPassesRateLimit(t *testing.T) {
+	var got []GatewayRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GatewayRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		got = append(got, req)
+		_ = json.NewEncoder(w).Encode(GatewayResponse{RequestID: req.RequestID, Status: "ok"})
+	}))
+	defer srv.Close()
+
+	client, err := NewGatewayClient(srv.URL, GatewayConfig{Protocol: GatewayProtocolPost}, GatewayCredentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	iptables := &IptablesAdapter{Client: client}
+	for _, req := range []BanRequest{
+		{IP: "192.0.2.1", Action: ActionRateLimit, RateLimit: &RateLimit{PPS: 100}},
+		{IP: "192.0.2.2", Action: ActionRateLimit, RateLimit: &RateLimit{BPS: 1 << 20}},
+		{IP: "192.0.2.3"},
+	} {
+		if _, err := iptables.Ban(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 3 || got[0].PPS != 100 || got[1].BPS != 1<<20 || got[2].PPS != 0 || got[2].BPS != 0 {
+		t.Errorf("requests = %+v", got)
+	}
+}
+
+func TestGatewayLegacyGetEscapesQuery(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		got = append(got, q.Get("cidr"), q.Get("ban_type"))
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "Successfully added"})
+	}))
+	defer srv.Close()
+
+	adapter := &XDPAdapter{GatewayHost: strings.TrimPrefix(srv.URL, "http://")}
+	// 参数中的特殊字符不能改写其他查询参数
+	if _, err := adapter.Ban(BanRequest{IP: "192.0.2.1&ban_type=1", DurationSeconds: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != "192.0.2.1&ban_type=1" || got[1] != "0" {
+		t.Errorf("query = %v", got)
+	}
+}
+
+func TestNewGatewayClientValidation(t *testing.T) {
+	for name, cfg := range map[string]GatewayConfig{
+		"protocol": {Protocol: "put"},
+		"timeout":  {Timeout: "soon"},
+	} {
+		if _, err := NewGatewayClient("gateway:9521", cfg, GatewayCredentials{}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := NewGatewayClient("gateway:9521", GatewayConfig{TLS: &GatewayTLSConfig{}}, GatewayCredentials{CA: []byte("not pem")}); err == nil {
+		t.Error("expected error for invalid CA")
+	}
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// HealthChecker 由需要访问外部后端的引擎实现，检查后端当前是否可达。
+// 只操作集群内资源的引擎（networkpolicy、cilium、istio、nginx）不实现此接口
+type HealthChecker interface {
+	Health(ctx context.Context) error
+}
+
+// CheckHealth 检查引擎的后端是否可达，未实现 HealthChecker 的引擎视为始终可达
+func CheckHealth(ctx context.Context, a Adapter) error {
+	if h, ok := a.(HealthChecker); ok {
+		return h.Health(ctx)
+	}
+	return nil
+}
+
+// Health 请求网关的 /healthz，只要网关有应答且未拒绝认证即视为可达，
+// 以兼容没有 /healthz 接口的旧版网关
+func (c *GatewayClient) Health(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url("/healthz", nil), nil)
+	if err != nil {
+		return err
+	}
+	c.authorize(req)
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("gateway %s unreachable: %w", c.Host, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("gateway %s rejected credentials: %s", c.Host, resp.Status)
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("gateway %s unhealthy: %s", c.Host, resp.Status)
+	}
+	return nil
+}
+
+func (xdp *XDPAdapter) Health(ctx context.Context) error {
+	return xdp.client().Health(ctx)
+}
+
+func (iptables *IptablesAdapter) Health(ctx context.Context) error {
+	return iptables.client().Health(ctx)
+}
+
+// Health 查询第一页规则，同时验证 API 可达和 Token 权限
+func (c *CloudflareAdapter) Health(ctx context.Context) error {
+	query := url.Values{"per_page": {"5"}, "notes": {cloudflareNotes}}
+	if _, err := c.request(ctx, http.MethodGet, c.rulesPath(), query, nil); err != nil {
+		return fmt.Errorf("cloudflare api unavailable: %w", err)
+	}
+	return nil
+}
+
+// Health 读取配置的 IPSet，同时验证 API 可达和凭据权限
+func (w *AWSWAFAdapter) Health(ctx context.Context) error {
+	for _, ref := range []*WAFIPSetRef{w.Config.IPv4Set, w.Config.IPv6Set} {
+		if ref == nil {
+			continue
+		}
+		if err := w.call(ctx, "GetIPSet", w.ipSetKey(ref), nil); err != nil {
+			return fmt.Errorf("aws waf IPSet %s unavailable: %w", ref.Name, err)
+		}
+	}
+	return nil
+}
+
+// Health 按失败策略汇总各后端的健康状态：all 要求所有后端可达，best-effort 只要求至少一个可达
+func (c *CompositeAdapter) Health(ctx context.Context) error {
+	errs := make([]error, len(c.Backends))
+	var wg sync.WaitGroup
+	for i, b := range c.Backends {
+		wg.Add(1)
+		go func(i int, b CompositeBackend) {
+			defer wg.Done()
+			if err := CheckHealth(ctx, b.Adapter); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", b.Engine, err)
+			}
+		}(i, b)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	if c.Policy == CompositePolicyBestEffort && len(failed) < len(c.Backends) {
+		return nil
+	}
+	return fmt.Errorf("%d of %d backends unhealthy: %w", len(failed), len(c.Backends), errors.Join(failed...))
+}
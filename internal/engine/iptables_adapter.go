@@ -1,39 +1,59 @@
 package engine
 
-//
 import (
-	"encoding/json"
 	"fmt"
-	"log"
-	"net/http"
+	"net/url"
+	"strconv"
 )
 
+// IptablesAdapter 调用封禁后端的 /limit 接口对目标地址限速：指定 pps / bps 时按包数或字节数限速，
+// 否则按后端默认的新连接速率限速
 type IptablesAdapter struct {
 	GatewayHost string
+	Client      *GatewayClient // 为空时按旧协议以 HTTP 调用 GatewayHost
 }
 
-func (iptables *IptablesAdapter) Ban(ip string, isParmanent bool, durationSeconds int) (string, error) {
-	// 构造url
-	url := fmt.Sprintf("http://%s/limit?ip=%s", iptables.GatewayHost, ip)
-
-	log.Printf("调用限流接口: %s", url)
+// Capabilities iptables 后端只能限速，可按 pps 或 bps 限速，不指定时使用后端的默认限速
+func (iptables *IptablesAdapter) Capabilities() Capabilities {
+	return Capabilities{
+		Actions:          []string{ActionRateLimit},
+		RateLimitUnits:   []string{RateLimitPPS, RateLimitBPS},
+		DefaultRateLimit: true,
+	}
+}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Printf("调用限流接口失败: %v", err)
-		return "", err
+func (iptables *IptablesAdapter) client() *GatewayClient {
+	if iptables.Client != nil {
+		return iptables.Client
 	}
+	return &GatewayClient{Host: iptables.GatewayHost}
+}
 
-	defer resp.Body.Close()
+func (iptables *IptablesAdapter) Ban(req BanRequest) (string, error) {
+	c := iptables.client()
+	if c.protocol() == GatewayProtocolPost {
+		msg, err := c.post("/v1/limit", limitRequest(req))
+		if err != nil {
+			return msg, fmt.Errorf("限流失败: %w", err)
+		}
+		return msg, nil
+	}
 
 	var result struct {
 		IP     string
 		Status string
 	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("解析返回信息失败: %v", err)
-		return "", fmt.Errorf("解析返回信息失败: %v", err)
+	query := url.Values{"ip": {req.IP}}
+	if r := req.RateLimit; r != nil {
+		if r.PPS > 0 {
+			query.Set("pps", strconv.FormatInt(r.PPS, 10))
+		}
+		if r.BPS > 0 {
+			query.Set("bps", strconv.FormatInt(r.BPS, 10))
+		}
+	}
+	if err := c.get("/limit", query, &result); err != nil {
+		return "", err
 	}
 
 	if result.Status == "limited" {
@@ -43,28 +63,22 @@ func (iptables *IptablesAdapter) Ban(ip string, isParmanent bool, durationSecond
 	return result.Status, fmt.Errorf("限流失败: %s", result.Status)
 }
 
-func (iptables *IptablesAdapter) UnBan(ip string) (string, error) {
-	// 构造url
-	url := fmt.Sprintf("http://%s/unlimit?ip=%s", iptables.GatewayHost, ip)
-
-	log.Printf("调用解限流接口: %s", url)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Printf("调用解限流接口失败: %v", err)
-		return "", err
+func (iptables *IptablesAdapter) UnBan(req BanRequest) (string, error) {
+	c := iptables.client()
+	if c.protocol() == GatewayProtocolPost {
+		msg, err := c.post("/v1/unlimit", GatewayRequest{CIDR: req.IP})
+		if err != nil {
+			return msg, fmt.Errorf("解限流失败: %w", err)
+		}
+		return msg, nil
 	}
 
-	defer resp.Body.Close()
-
 	var result struct {
 		IP     string
 		Status string
 	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("解析返回信息失败: %v", err)
-		return "", fmt.Errorf("解析返回信息失败: %v", err)
+	if err := c.get("/unlimit", url.Values{"ip": {req.IP}}, &result); err != nil {
+		return "", err
 	}
 
 	if result.Status == "unlimited" {
@@ -73,3 +87,24 @@ func (iptables *IptablesAdapter) UnBan(ip string) (string, error) {
 
 	return result.Status, fmt.Errorf("解限流失败: %s", result.Status)
 }
+
+// BanBatch 通过网关的 /v1/batch 一次提交，旧协议逐个调用
+func (iptables *IptablesAdapter) BanBatch(reqs []BanRequest) []BatchResult {
+	return iptables.client().batchResults("limit", reqs, limitRequest, iptables.Ban, "限流失败")
+}
+
+// 限速请求，未指定 pps / bps 时由后端使用默认限速
+func limitRequest(req BanRequest) GatewayRequest {
+	item := GatewayRequest{CIDR: req.IP}
+	if req.RateLimit != nil {
+		item.PPS = req.RateLimit.PPS
+		item.BPS = req.RateLimit.BPS
+	}
+	return item
+}
+
+func (iptables *IptablesAdapter) UnBanBatch(reqs []BanRequest) []BatchResult {
+	return iptables.client().batchResults("unlimit", reqs, func(req BanRequest) GatewayRequest {
+		return GatewayRequest{CIDR: req.IP}
+	}, iptables.UnBan, "解限流失败")
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// FieldOwner server-side apply 使用的字段管理者
+	FieldOwner = "ipblock-operator"
+
+	defaultGatewayNamespace = "istio-system"
+)
+
+// AuthorizationPolicyGVK Istio 授权策略，使用 unstructured 访问以免引入 Istio 依赖
+var AuthorizationPolicyGVK = schema.GroupVersionKind{
+	Group:   "security.istio.io",
+	Version: "v1",
+	Kind:    "AuthorizationPolicy",
+}
+
+// IstioAdapter 在 Istio 网关上维护一条 action 为 DENY 的托管 AuthorizationPolicy，
+// 封禁集合整体渲染到 remoteIpBlocks 中，每次变更只对整条策略做一次 server-side apply
+type IstioAdapter struct {
+	Client client.Client
+	Config PolicyConfig
+
+	mu sync.Mutex // 串行化对托管策略的读改写
+}
+
+// NewIstioAdapter 创建 Istio 引擎，默认作用于 istio-system 命名空间中 istio=ingressgateway 的网关
+func NewIstioAdapter(c client.Client, cfg PolicyConfig) *IstioAdapter {
+	if len(cfg.Namespaces) == 0 {
+		cfg.Namespaces = []string{defaultGatewayNamespace}
+	}
+	if len(cfg.Selector) == 0 {
+		cfg.Selector = map[string]string{"istio": "ingressgateway"}
+	}
+	return &IstioAdapter{Client: c, Config: cfg.withDefaults()}
+}
+
+func (i *IstioAdapter) Ban(req BanRequest) (string, error) {
+	cidr, err := blockCIDR(req.IP)
+	if err != nil {
+		return "", err
+	}
+	return i.update(func(set map[string]struct{}) { set[cidr] = struct{}{} })
+}
+
+func (i *IstioAdapter) UnBan(req BanRequest) (string, error) {
+	cidr, err := blockCIDR(req.IP)
+	if err != nil {
+		return "", err
+	}
+	return i.update(func(set map[string]struct{}) { delete(set, cidr) })
+}
+
+func (i *IstioAdapter) update(mutate func(map[string]struct{})) (string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), policyTimeout)
+	defer cancel()
+
+	var total int
+	for _, ns := range i.Config.Namespaces {
+		err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+			current := &unstructured.Unstructured{}
+			current.SetGroupVersionKind(AuthorizationPolicyGVK)
+			err := i.Client.Get(ctx, client.ObjectKey{Namespace: ns, Name: i.Config.Name}, current)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+
+			blocked, aggregated, err := mutateCIDRSet(current.GetAnnotations()[BlockedCIDRsAnnotation], mutate)
+			if err != nil {
+				return err
+			}
+			total = len(aggregated)
+
+			// 只提交本引擎管理的字段；带上 resourceVersion，策略被并发修改时返回冲突并重试
+			desired := &unstructured.Unstructured{}
+			desired.SetGroupVersionKind(AuthorizationPolicyGVK)
+			desired.SetNamespace(ns)
+			desired.SetName(i.Config.Name)
+			desired.SetResourceVersion(current.GetResourceVersion())
+			desired.SetLabels(withManagedLabel(nil))
+			desired.SetAnnotations(withBlockedCIDRs(nil, blocked))
+			if err := unstructured.SetNestedField(desired.Object, i.buildSpec(aggregated), "spec"); err != nil {
+				return err
+			}
+
+			return i.Client.Patch(ctx, desired, client.Apply, client.FieldOwner(FieldOwner), client.ForceOwnership)
+		})
+		if err != nil {
+			return "", fmt.Errorf("apply AuthorizationPolicy %s/%s failed: %w", ns, i.Config.Name, err)
+		}
+	}
+	return fmt.Sprintf("AuthorizationPolicy %s applied in %s, %d CIDRs blocked",
+		i.Config.Name, strings.Join(i.Config.Namespaces, ","), total), nil
+}
+
+// 封禁集合为空时不生成 rules：DENY 策略没有规则时不匹配任何请求，
+// 而空的 remoteIpBlocks 会被视为未设置，匹配全部请求
+func (i *IstioAdapter) buildSpec(cidrs []string) map[string]interface{} {
+	matchLabels := make(map[string]interface{}, len(i.Config.Selector))
+	for k, v := range i.Config.Selector {
+		matchLabels[k] = v
+	}
+	spec := map[string]interface{}{
+		"selector": map[string]interface{}{"matchLabels": matchLabels},
+		"action":   "DENY",
+	}
+	if len(cidrs) > 0 {
+		blocks := make([]interface{}, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			blocks = append(blocks, cidr)
+		}
+		spec["rules"] = []interface{}{
+			map[string]interface{}{
+				"from": []interface{}{
+					map[string]interface{}{
+						"source": map[string]interface{}{"remoteIpBlocks": blocks},
+					},
+				},
+			},
+		}
+	}
+	return spec
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/utils"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultPolicyName 托管策略的默认名称
+	DefaultPolicyName = "ipblock-deny"
+	// BlockedCIDRsAnnotation 记录托管策略当前封禁的 CIDR（未聚合），
+	// 策略中的规则由它聚合生成，解封时据此重建，不依赖外部状态
+	BlockedCIDRsAnnotation = "ops.yiiong.top/blocked-cidrs"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "ipblock-operator"
+
+	policyTimeout = 10 * time.Second
+)
+
+// PolicyConfig 策略类引擎配置（ConfigMap 的 networkPolicy / authorizationPolicy 字段）
+type PolicyConfig struct {
+	Name       string            `json:"name,omitempty"`       // 托管策略名称，默认 ipblock-deny
+	Namespaces []string          `json:"namespaces,omitempty"` // 策略所在命名空间，默认 default（istio 为 istio-system）；Cilium 为集群级策略，忽略该字段
+	Selector   map[string]string `json:"selector,omitempty"`   // 目标 Pod 标签，networkpolicy 引擎必填（istio 默认 istio=ingressgateway）
+}
+
+func (c PolicyConfig) withDefaults() PolicyConfig {
+	if c.Name == "" {
+		c.Name = DefaultPolicyName
+	}
+	if len(c.Namespaces) == 0 {
+		c.Namespaces = []string{"default"}
+	}
+	return c
+}
+
+// NetworkPolicyAdapter 通过 Kubernetes NetworkPolicy 由 CNI 执行封禁：
+// 在每个目标命名空间维护一条托管策略，放行全部来源，被封禁的 CIDR 写入 ipBlock.except。
+// NetworkPolicy 按并集生效，托管策略会放开被选中 Pod 上原有的隔离，且其他放行同一流量的策略会绕过封禁，
+// 因此必须显式配置 selector，只选择本来就对外开放的 Pod
+type NetworkPolicyAdapter struct {
+	Client client.Client
+	Config PolicyConfig
+
+	mu sync.Mutex // 串行化对托管策略的读改写
+}
+
+// NewNetworkPolicyAdapter 创建 NetworkPolicy 引擎，selector 为空时报错，避免策略选中命名空间内全部 Pod
+func NewNetworkPolicyAdapter(c client.Client, cfg PolicyConfig) (*NetworkPolicyAdapter, error) {
+	if len(cfg.Selector) == 0 {
+		return nil, fmt.Errorf("networkpolicy engine requires a selector, an empty selector would open every pod in the namespace")
+	}
+	return &NetworkPolicyAdapter{Client: c, Config: cfg.withDefaults()}, nil
+}
+
+func (n *NetworkPolicyAdapter) Ban(req BanRequest) (string, error) {
+	// 到期解封由控制器负责，这里只维护封禁集合
+	cidr, err := blockCIDR(req.IP)
+	if err != nil {
+		return "", err
+	}
+	return n.update(func(set map[string]struct{}) { set[cidr] = struct{}{} })
+}
+
+func (n *NetworkPolicyAdapter) UnBan(req BanRequest) (string, error) {
+	cidr, err := blockCIDR(req.IP)
+	if err != nil {
+		return "", err
+	}
+	return n.update(func(set map[string]struct{}) { delete(set, cidr) })
+}
+
+// 在所有目标命名空间中修改封禁集合并重新生成策略
+func (n *NetworkPolicyAdapter) update(mutate func(map[string]struct{})) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), policyTimeout)
+	defer cancel()
+
+	var total int
+	for _, ns := range n.Config.Namespaces {
+		err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+			var np networkingv1.NetworkPolicy
+			err := n.Client.Get(ctx, client.ObjectKey{Namespace: ns, Name: n.Config.Name}, &np)
+			exists := err == nil
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			if !exists {
+				np = networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: n.Config.Name}}
+			}
+
+			blocked, aggregated, err := mutateCIDRSet(np.Annotations[BlockedCIDRsAnnotation], mutate)
+			if err != nil {
+				return err
+			}
+			total = len(aggregated)
+
+			np.Labels = withManagedLabel(np.Labels)
+			np.Annotations = withBlockedCIDRs(np.Annotations, blocked)
+			np.Spec = n.buildSpec(aggregated)
+
+			if exists {
+				return n.Client.Update(ctx, &np)
+			}
+			return n.Client.Create(ctx, &np)
+		})
+		if err != nil {
+			return "", fmt.Errorf("update NetworkPolicy %s/%s failed: %w", ns, n.Config.Name, err)
+		}
+	}
+	return fmt.Sprintf("NetworkPolicy %s updated in %s, %d CIDRs blocked",
+		n.Config.Name, strings.Join(n.Config.Namespaces, ","), total), nil
+}
+
+// 放行所有来源，被封禁的 CIDR 放在 except 中；集群内 Pod 之间的流量通过 namespaceSelector 放行，不受影响
+func (n *NetworkPolicyAdapter) buildSpec(cidrs []string) networkingv1.NetworkPolicySpec {
+	v4, v6 := splitFamilies(cidrs)
+	return networkingv1.NetworkPolicySpec{
+		PodSelector: metav1.LabelSelector{MatchLabels: n.Config.Selector},
+		PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		Ingress: []networkingv1.NetworkPolicyIngressRule{{
+			From: []networkingv1.NetworkPolicyPeer{
+				{IPBlock: &networkingv1.IPBlock{CIDR: "0.0.0.0/0", Except: v4}},
+				{IPBlock: &networkingv1.IPBlock{CIDR: "::/0", Except: v6}},
+				{NamespaceSelector: &metav1.LabelSelector{}},
+			},
+		}},
+	}
+}
+
+// 校验并规范化封禁目标，/0 无法放入 except，直接拒绝
+func blockCIDR(ip string) (string, error) {
+	cidr := utils.NormalizeCIDR(ip)
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", fmt.Errorf("invalid ip '%s'", ip)
+	}
+	if p.Bits() == 0 {
+		return "", fmt.Errorf("refusing to block the whole address space '%s'", ip)
+	}
+	return cidr, nil
+}
+
+// 从注解解析封禁集合并修改，返回修改后的集合（排序）及其聚合结果
+func mutateCIDRSet(annotation string, mutate func(map[string]struct{})) (blocked, aggregated []string, err error) {
+	set := make(map[string]struct{})
+	for _, c := range strings.Split(annotation, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			set[c] = struct{}{}
+		}
+	}
+	mutate(set)
+
+	blocked = make([]string, 0, len(set))
+	for c := range set {
+		blocked = append(blocked, c)
+	}
+	sort.Strings(blocked)
+
+	aggregated, err = AggregateCIDRs(blocked)
+	return blocked, aggregated, err
+}
+
+// 添加托管标签
+func withManagedLabel(labels map[string]string) map[string]string {
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[managedByLabel] = managedByValue
+	return labels
+}
+
+// 写入封禁集合注解
+func withBlockedCIDRs(annotations map[string]string, blocked []string) map[string]string {
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[BlockedCIDRsAnnotation] = strings.Join(blocked, ",")
+	return annotations
+}
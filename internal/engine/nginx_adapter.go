@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NGINX 引擎的两种模式
+const (
+	// NginxModeIngress 维护 ingress-nginx 控制器 ConfigMap 的 block-cidrs（逗号分隔）
+	NginxModeIngress = "ingress-nginx"
+	// NginxModeDenyFile 把 "deny <cidr>;" 渲染到 ConfigMap 中，供普通 NGINX 以 include 方式引用
+	NginxModeDenyFile = "deny-file"
+)
+
+// NginxConfig NGINX 引擎配置（ConfigMap 的 nginx 字段）
+type NginxConfig struct {
+	Mode      string `json:"mode,omitempty"`      // ingress-nginx（默认）或 deny-file
+	Namespace string `json:"namespace,omitempty"` // 目标 ConfigMap 命名空间，默认 ingress-nginx
+	Name      string `json:"name,omitempty"`      // 目标 ConfigMap 名称，默认 ingress-nginx-controller
+	Key       string `json:"key,omitempty"`       // 写入的键，默认 block-cidrs（deny-file 模式为 deny.conf）
+	Debounce  string `json:"debounce,omitempty"`  // 合并窗口，窗口内的封禁/解封合并为一次写入（一次 reload），默认 5s
+}
+
+// NginxAdapter 维护 ingress-nginx 的 block-cidrs 或普通 NGINX 的 deny 文件。
+// 封禁和解封在合并窗口结束后一次性写入 ConfigMap，调用方等待写入结果；
+// 自己写入的 CIDR 记录在 ConfigMap 注解中，与其他来源的条目合并，解封时只删除自己的条目
+type NginxAdapter struct {
+	Client client.Client
+	Config NginxConfig
+
+	batch *batcher
+}
+
+// NewNginxAdapter 创建 NGINX 引擎
+func NewNginxAdapter(c client.Client, cfg NginxConfig) (*NginxAdapter, error) {
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = NginxModeIngress
+	case NginxModeIngress, NginxModeDenyFile:
+	default:
+		return nil, fmt.Errorf("unknown nginx mode '%s'", cfg.Mode)
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = "ingress-nginx"
+	}
+	if cfg.Name == "" {
+		cfg.Name = "ingress-nginx-controller"
+	}
+	if cfg.Key == "" {
+		cfg.Key = "block-cidrs"
+		if cfg.Mode == NginxModeDenyFile {
+			cfg.Key = "deny.conf"
+		}
+	}
+
+	debounce := 5 * time.Second
+	if cfg.Debounce != "" {
+		d, err := time.ParseDuration(cfg.Debounce)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid nginx debounce '%s'", cfg.Debounce)
+		}
+		debounce = d
+	}
+
+	n := &NginxAdapter{Client: c, Config: cfg}
+	n.batch = newBatcher("nginx-engine", debounce, n.apply)
+	return n, nil
+}
+
+// Ban 等待合并窗口结束、ConfigMap 写入后返回，写入失败时返回错误
+func (n *NginxAdapter) Ban(req BanRequest) (string, error) {
+	cidr, err := blockCIDR(req.IP)
+	if err != nil {
+		return "", err
+	}
+	if err := n.batch.do(cidr, true); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s written to %s/%s[%s]", cidr, n.Config.Namespace, n.Config.Name, n.Config.Key), nil
+}
+
+func (n *NginxAdapter) UnBan(req BanRequest) (string, error) {
+	cidr, err := blockCIDR(req.IP)
+	if err != nil {
+		return "", err
+	}
+	if err := n.batch.do(cidr, false); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s removed from %s/%s[%s]", cidr, n.Config.Namespace, n.Config.Name, n.Config.Key), nil
+}
+
+// 一个批次写入一次 ConfigMap，失败时整个批次失败
+func (n *NginxAdapter) apply(ops map[string]bool) (map[string]error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), policyTimeout)
+	defer cancel()
+
+	return nil, retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		var cm corev1.ConfigMap
+		err := n.Client.Get(ctx, client.ObjectKey{Namespace: n.Config.Namespace, Name: n.Config.Name}, &cm)
+		exists := err == nil
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if !exists {
+			// ingress-nginx 的 ConfigMap 由控制器部署，不存在说明配置有误，不能自行创建
+			if n.Config.Mode == NginxModeIngress {
+				return fmt.Errorf("configmap %s/%s not found", n.Config.Namespace, n.Config.Name)
+			}
+			cm = corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: n.Config.Namespace, Name: n.Config.Name}}
+		}
+
+		// 上次写入的条目由注解中的 CIDR 聚合得到，其余条目不属于本引擎
+		annotation := cm.Annotations[BlockedCIDRsAnnotation]
+		_, previous, err := mutateCIDRSet(annotation, func(map[string]struct{}) {})
+		if err != nil {
+			return err
+		}
+		rendered := make(map[string]struct{}, len(previous))
+		for _, cidr := range previous {
+			rendered[n.render(cidr)] = struct{}{}
+		}
+		var unmanaged []string
+		unmanagedSet := make(map[string]struct{})
+		for _, entry := range n.parseEntries(cm.Data[n.Config.Key]) {
+			if _, ok := rendered[entry]; !ok {
+				unmanaged = append(unmanaged, entry)
+				unmanagedSet[entry] = struct{}{}
+			}
+		}
+
+		blocked, aggregated, err := mutateCIDRSet(annotation, func(set map[string]struct{}) {
+			for cidr, add := range ops {
+				if !add {
+					delete(set, cidr)
+					continue
+				}
+				// 已由其他来源配置的条目不接管，解封时也不会删除
+				if _, ok := unmanagedSet[n.render(cidr)]; !ok {
+					set[cidr] = struct{}{}
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		entries := unmanaged
+		for _, cidr := range aggregated {
+			entries = append(entries, n.render(cidr))
+		}
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		cm.Data[n.Config.Key] = n.format(entries)
+		cm.Annotations = withBlockedCIDRs(cm.Annotations, blocked)
+
+		if exists {
+			return n.Client.Update(ctx, &cm)
+		}
+		cm.Labels = withManagedLabel(cm.Labels)
+		return n.Client.Create(ctx, &cm)
+	})
+}
+
+func (n *NginxAdapter) render(cidr string) string {
+	if n.Config.Mode == NginxModeDenyFile {
+		return "deny " + cidr + ";"
+	}
+	return cidr
+}
+
+func (n *NginxAdapter) parseEntries(value string) []string {
+	sep := ","
+	if n.Config.Mode == NginxModeDenyFile {
+		sep = "\n"
+	}
+	var entries []string
+	for _, e := range strings.Split(value, sep) {
+		if e = strings.TrimSpace(e); e != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (n *NginxAdapter) format(entries []string) string {
+	if n.Config.Mode == NginxModeDenyFile {
+		if len(entries) == 0 {
+			return ""
+		}
+		return strings.Join(entries, "\n") + "\n"
+	}
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getConfigMap(t *testing.T, c client.Client, key client.ObjectKey) *corev1.ConfigMap {
+	t.Helper()
+	var cm corev1.ConfigMap
+	if err := c.Get(context.Background(), key, &cm); err != nil {
+		t.Fatal(err)
+	}
+	return &cm
+}
+
+// queueOps 在后台执行 ops，等它们都进入合并窗口后返回，结果在 flush 后从 channel 读取
+func queueOps(t *testing.T, b *batcher, ops ...func() error) []chan error {
+	t.Helper()
+	results := make([]chan error, len(ops))
+	for i, op := range ops {
+		results[i] = make(chan error, 1)
+		go func(i int, op func() error) { results[i] <- op() }(i, op)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b.mu.Lock()
+		waiting := 0
+		for _, op := range b.pending {
+			waiting += len(op.waiters)
+		}
+		b.mu.Unlock()
+		if waiting == len(ops) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d changes queued", waiting, len(ops))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return results
+}
+
+// flushAfter 排队 ops 后手动 flush，返回各自的结果
+func flushAfter(t *testing.T, b *batcher, ops ...func() error) []error {
+	t.Helper()
+	results := queueOps(t, b, ops...)
+	b.flush()
+	errs := make([]error, len(results))
+	for i := range results {
+		errs[i] = <-results[i]
+	}
+	return errs
+}
+
+func banOp(a Adapter, ip string) func() error {
+	return func() error {
+		_, err := a.Ban(BanRequest{IP: ip, Permanent: true})
+		return err
+	}
+}
+
+func unbanOp(a Adapter, ip string) func() error {
+	return func() error {
+		_, err := a.UnBan(BanRequest{IP: ip})
+		return err
+	}
+}
+
+func TestNginxIngressBlockCIDRs(t *testing.T) {
+	key := client.ObjectKey{Namespace: "ingress-nginx", Name: "ingress-nginx-controller"}
+	c := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Data:       map[string]string{"block-cidrs": "192.0.2.1/32, 198.51.100.0/24"},
+	}).Build()
+
+	adapter, err := NewNginxAdapter(c, NginxConfig{Debounce: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := getConfigMap(t, c, key).ResourceVersion
+
+	// 同一窗口内的多次封禁合并为一次写入
+	results := queueOps(t, adapter.batch, banOp(adapter, "10.0.0.0"), banOp(adapter, "10.0.0.1"), banOp(adapter, "192.0.2.1"))
+	if getConfigMap(t, c, key).ResourceVersion != before {
+		t.Fatal("configmap updated before the debounce window ended")
+	}
+	adapter.batch.flush()
+	for _, r := range results {
+		if err := <-r; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cm := getConfigMap(t, c, key)
+	if got := cm.Data["block-cidrs"]; got != "192.0.2.1/32,198.51.100.0/24,10.0.0.0/31" {
+		t.Errorf("block-cidrs = %q", got)
+	}
+	// 192.0.2.1 由其他来源配置，不接管
+	if got := cm.Annotations[BlockedCIDRsAnnotation]; got != "10.0.0.0/32,10.0.0.1/32" {
+		t.Errorf("owned cidrs = %q", got)
+	}
+
+	for _, err := range flushAfter(t, adapter.batch, unbanOp(adapter, "10.0.0.1"), unbanOp(adapter, "192.0.2.1")) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cm = getConfigMap(t, c, key)
+	if got := cm.Data["block-cidrs"]; got != "192.0.2.1/32,198.51.100.0/24,10.0.0.0/32" {
+		t.Errorf("block-cidrs after unban = %q", got)
+	}
+}
+
+func TestNginxDenyFileDebounce(t *testing.T) {
+	c := fake.NewClientBuilder().Build()
+	adapter, err := NewNginxAdapter(c, NginxConfig{
+		Mode:      NginxModeDenyFile,
+		Namespace: "default",
+		Name:      "nginx-deny",
+		Debounce:  "20ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 两次封禁在同一窗口内，计时器到期后一起返回
+	var wg sync.WaitGroup
+	for _, ip := range []string{"203.0.113.9", "2001:db8::1"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := adapter.Ban(BanRequest{IP: ip, Permanent: true}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	cm := getConfigMap(t, c, client.ObjectKey{Namespace: "default", Name: "nginx-deny"})
+	if got := cm.Data["deny.conf"]; got != "deny 203.0.113.9/32;\ndeny 2001:db8::1/128;\n" {
+		t.Errorf("deny.conf = %q", got)
+	}
+}
+
+func TestNginxIngressRequiresConfigMap(t *testing.T) {
+	adapter, err := NewNginxAdapter(fake.NewClientBuilder().Build(), NginxConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adapter.apply(map[string]bool{"10.0.0.1/32": true}); err == nil {
+		t.Fatal("expected error when ingress-nginx configmap is missing")
+	}
+}
+
+// 提交失败时错误返回给调用方，失败的变更不会留在下一个窗口
+func TestNginxBanReportsFailedWrite(t *testing.T) {
+	adapter, err := NewNginxAdapter(fake.NewClientBuilder().Build(), NginxConfig{Debounce: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := flushAfter(t, adapter.batch, banOp(adapter, "10.0.0.1"))
+	if errs[0] == nil {
+		t.Fatal("expected ban to fail when ingress-nginx configmap is missing")
+	}
+	if len(adapter.batch.pending) != 0 {
+		t.Errorf("failed change was requeued: %v", adapter.batch.pending)
+	}
+}
+
+// 同一窗口内被相反操作覆盖的调用方收到错误，最终以最后一次操作为准
+func TestBatcherSupersededChange(t *testing.T) {
+	var applied map[string]bool
+	b := newBatcher("test", time.Hour, func(ops map[string]bool) (map[string]error, error) {
+		applied = ops
+		return nil, nil
+	})
+	banned := queueOps(t, b, func() error { return b.do("10.0.0.1/32", true) })
+	unbanned := make(chan error, 1)
+	go func() { unbanned <- b.do("10.0.0.1/32", false) }()
+	// 解封加入窗口时，之前的封禁立即返回
+	if err := <-banned[0]; err == nil {
+		t.Error("superseded ban should return an error")
+	}
+	b.flush()
+	if err := <-unbanned; err != nil {
+		t.Fatal(err)
+	}
+	if applied["10.0.0.1/32"] {
+		t.Errorf("applied = %v, want unban", applied)
+	}
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("NetworkPolicy engine", func() {
+	const namespace = "np-engine"
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, ns))).To(Succeed())
+	})
+
+	It("maintains aggregated ipBlock.except rules", func() {
+		adapter, err := NewNetworkPolicyAdapter(k8sClient, PolicyConfig{
+			Namespaces: []string{namespace},
+			Selector:   map[string]string{"app": "web"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("banning adjacent addresses")
+		for _, ip := range []string{"10.0.0.0", "10.0.0.1", "2001:db8::1"} {
+			_, err := adapter.Ban(BanRequest{IP: ip, Permanent: true})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		var np networkingv1.NetworkPolicy
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: DefaultPolicyName}, &np)).To(Succeed())
+		Expect(np.Labels).To(HaveKeyWithValue(managedByLabel, managedByValue))
+		Expect(np.Spec.PodSelector.MatchLabels).To(Equal(map[string]string{"app": "web"}))
+		Expect(np.Spec.Ingress).To(HaveLen(1))
+		peers := np.Spec.Ingress[0].From
+		Expect(peers[0].IPBlock.Except).To(Equal([]string{"10.0.0.0/31"}))
+		Expect(peers[1].IPBlock.Except).To(Equal([]string{"2001:db8::1/128"}))
+
+		By("unbanning one address of the aggregated range")
+		_, err = adapter.UnBan(BanRequest{IP: "10.0.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: DefaultPolicyName}, &np)).To(Succeed())
+		Expect(np.Spec.Ingress[0].From[0].IPBlock.Except).To(Equal([]string{"10.0.0.0/32"}))
+		Expect(np.Annotations[BlockedCIDRsAnnotation]).To(Equal("10.0.0.0/32,2001:db8::1/128"))
+	})
+
+	It("rejects invalid targets", func() {
+		_, err := NewNetworkPolicyAdapter(k8sClient, PolicyConfig{Namespaces: []string{namespace}})
+		Expect(err).To(HaveOccurred(), "selector is required")
+
+		adapter, err := NewNetworkPolicyAdapter(k8sClient, PolicyConfig{
+			Namespaces: []string{namespace},
+			Selector:   map[string]string{"app": "web"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = adapter.Ban(BanRequest{IP: "0.0.0.0/0", Permanent: true})
+		Expect(err).To(HaveOccurred())
+		_, err = adapter.Ban(BanRequest{IP: "not-an-ip", Permanent: true})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Cilium engine", func() {
+	It("maintains ingressDeny rules on a clusterwide policy", func() {
+		adapter := NewCiliumAdapter(k8sClient, PolicyConfig{Name: "ipblock-cilium"})
+
+		for _, ip := range []string{"192.0.2.0/25", "192.0.2.128/25"} {
+			_, err := adapter.Ban(BanRequest{IP: ip, DurationSeconds: 60})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		policy := &unstructured.Unstructured{}
+		policy.SetGroupVersionKind(CiliumClusterwideNetworkPolicyGVK)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "ipblock-cilium"}, policy)).To(Succeed())
+		rules, found, err := unstructured.NestedSlice(policy.Object, "spec", "ingressDeny")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(rules[0]).To(HaveKeyWithValue("fromCIDR", []interface{}{"192.0.2.0/24"}))
+
+		By("removing the last ban")
+		for _, ip := range []string{"192.0.2.0/25", "192.0.2.128/25"} {
+			_, err := adapter.UnBan(BanRequest{IP: ip})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "ipblock-cilium"}, policy)).To(Succeed())
+		_, found, _ = unstructured.NestedSlice(policy.Object, "spec", "ingressDeny")
+		Expect(found).To(BeFalse())
+	})
+})
+
+var _ = Describe("Istio engine", func() {
+	const namespace = "istio-system"
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, ns))).To(Succeed())
+	})
+
+	getPolicy := func() *unstructured.Unstructured {
+		policy := &unstructured.Unstructured{}
+		policy.SetGroupVersionKind(AuthorizationPolicyGVK)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: DefaultPolicyName}, policy)).To(Succeed())
+		return policy
+	}
+
+	It("applies the whole ban set as one DENY policy", func() {
+		adapter := NewIstioAdapter(k8sClient, PolicyConfig{})
+
+		for _, ip := range []string{"198.51.100.7", "198.51.100.6", "2001:db8::/64"} {
+			_, err := adapter.Ban(BanRequest{IP: ip, Permanent: true})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		policy := getPolicy()
+		Expect(policy.GetLabels()).To(HaveKeyWithValue(managedByLabel, managedByValue))
+		action, _, _ := unstructured.NestedString(policy.Object, "spec", "action")
+		Expect(action).To(Equal("DENY"))
+		selector, _, _ := unstructured.NestedStringMap(policy.Object, "spec", "selector", "matchLabels")
+		Expect(selector).To(Equal(map[string]string{"istio": "ingressgateway"}))
+		rules, _, _ := unstructured.NestedSlice(policy.Object, "spec", "rules")
+		Expect(rules).To(HaveLen(1))
+		from := rules[0].(map[string]interface{})["from"].([]interface{})
+		remote, _, _ := unstructured.NestedStringSlice(from[0].(map[string]interface{}), "source", "remoteIpBlocks")
+		Expect(remote).To(Equal([]string{"198.51.100.6/31", "2001:db8::/64"}))
+
+		By("removing every ban leaves a policy without rules")
+		for _, ip := range []string{"198.51.100.7", "198.51.100.6", "2001:db8::/64"} {
+			_, err := adapter.UnBan(BanRequest{IP: ip})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		_, found, _ := unstructured.NestedSlice(getPolicy().Object, "spec", "rules")
+		Expect(found).To(BeFalse())
+	})
+})
@@ -1,43 +1,50 @@
 package engine
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
-	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
+// XDPAdapter 调用网关的封禁接口
 type XDPAdapter struct {
 	GatewayHost string
+	Client      *GatewayClient // 为空时按旧协议以 HTTP 调用 GatewayHost
 }
 
-func (xdp *XDPAdapter) Ban(ip string, isPermanent bool, durationSeconds int) (string, error) {
-	banType := 0 // 默认暂时封禁
-	if isPermanent {
-		banType = 1
+func (xdp *XDPAdapter) client() *GatewayClient {
+	if xdp.Client != nil {
+		return xdp.Client
 	}
+	return &GatewayClient{Host: xdp.GatewayHost}
+}
 
-	// 构造url
-	url := fmt.Sprintf("http://%s/update?cidr=%s&ban_type=%d&ban_time=%d", xdp.GatewayHost, ip, banType, durationSeconds)
-
-	log.Printf("调用封禁接口: %s", url)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Printf("调用封禁接口失败: %v", err)
-		return "", err
+func (xdp *XDPAdapter) Ban(req BanRequest) (string, error) {
+	c := xdp.client()
+	if c.protocol() == GatewayProtocolPost {
+		msg, err := c.post("/v1/ban", GatewayRequest{CIDR: req.IP, Permanent: req.Permanent, DurationSeconds: req.DurationSeconds})
+		if err != nil {
+			return msg, fmt.Errorf("封禁失败: %w", err)
+		}
+		return msg, nil
 	}
 
-	defer resp.Body.Close()
+	banType := 0 // 默认暂时封禁
+	if req.Permanent {
+		banType = 1
+	}
+	query := url.Values{
+		"cidr":     {req.IP},
+		"ban_type": {strconv.Itoa(banType)},
+		"ban_time": {strconv.Itoa(req.DurationSeconds)},
+	}
 
 	var result struct {
 		Message string `json:"message"`
 	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("解析返回信息失败: %v", err)
-		return "", fmt.Errorf("解析返回信息失败: %v", err)
+	if err := c.get("/update", query, &result); err != nil {
+		return "", err
 	}
 
 	msg := result.Message
@@ -49,19 +56,20 @@ func (xdp *XDPAdapter) Ban(ip string, isPermanent bool, durationSeconds int) (st
 }
 
 // 解封接口
-func (xdp *XDPAdapter) UnBan(ip string) (string, error) {
-	url := fmt.Sprintf("http://%s/remove?cidr=%s", xdp.GatewayHost, ip)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
+func (xdp *XDPAdapter) UnBan(req BanRequest) (string, error) {
+	c := xdp.client()
+	if c.protocol() == GatewayProtocolPost {
+		msg, err := c.post("/v1/unban", GatewayRequest{CIDR: req.IP})
+		if err != nil {
+			return msg, fmt.Errorf("解封失败：%w", err)
+		}
+		return msg, nil
 	}
 
-	defer resp.Body.Close()
-
 	var result struct {
 		Message string `json:"message"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := c.get("/remove", url.Values{"cidr": {req.IP}}, &result); err != nil {
 		return "", err
 	}
 
@@ -72,3 +80,16 @@ func (xdp *XDPAdapter) UnBan(ip string) (string, error) {
 	return msg, fmt.Errorf("解封失败：%s", msg)
 
 }
+
+// BanBatch 通过网关的 /v1/batch 一次提交，旧协议逐个调用
+func (xdp *XDPAdapter) BanBatch(reqs []BanRequest) []BatchResult {
+	return xdp.client().batchResults("ban", reqs, func(req BanRequest) GatewayRequest {
+		return GatewayRequest{CIDR: req.IP, Permanent: req.Permanent, DurationSeconds: req.DurationSeconds}
+	}, xdp.Ban, "封禁失败")
+}
+
+func (xdp *XDPAdapter) UnBanBatch(reqs []BanRequest) []BatchResult {
+	return xdp.client().batchResults("unban", reqs, func(req BanRequest) GatewayRequest {
+		return GatewayRequest{CIDR: req.IP}
+	}, xdp.UnBan, "解封失败")
+}
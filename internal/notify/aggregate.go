@@ -0,0 +1,226 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// EventSummary 聚合窗口结束时发送的汇总事件
+const EventSummary = "summary"
+
+// 汇总事件的模板变量
+const (
+	VarCount       = "count"       // 窗口内被聚合（未单独发送）的事件数
+	VarIPs         = "ips"         // IP 列表，超过上限时截断并注明剩余数量
+	VarTopReasons  = "topReasons"  // 出现最多的封禁原因及次数
+	VarTopSources  = "topSources"  // 出现最多的来源及次数
+	VarWindowStart = "windowStart" // 窗口开始时间（RFC3339）
+)
+
+// AggregateOptions 聚合参数
+type AggregateOptions struct {
+	Window    time.Duration // 聚合窗口
+	Threshold int           // 每个窗口内单独发送的事件数，超出部分聚合为汇总
+	MaxListed int           // 汇总中最多列出的 IP 数，默认 20
+	TopN      int           // 汇总中列出的原因/来源数，默认 3
+	Events    []string      // 参与聚合的事件类型，默认只聚合 ban
+}
+
+// Aggregator 通知器装饰器：封禁风暴时把窗口内超出阈值的事件合并为一条汇总消息。
+// 某个窗口发生过聚合后，下一个窗口不再单独发送，直到出现一个没有聚合的窗口为止。
+// 通过 Queue 使用时汇总投递到队列，与普通通知一样重试；队列停止时提前发送未结束窗口的汇总。
+type Aggregator struct {
+	Notifier Notifier
+	Name     string // 通道名，用于日志和指标
+	opts     AggregateOptions
+
+	mu          sync.Mutex
+	windowStart time.Time
+	windowEnd   time.Time
+	passed      int
+	storm       bool
+	buffered    []map[string]string
+	queue       *Queue // 为空时直接发送汇总
+}
+
+// NewAggregator 创建聚合装饰器
+func NewAggregator(n Notifier, name string, opts AggregateOptions) (*Aggregator, error) {
+	if opts.Window <= 0 {
+		return nil, fmt.Errorf("aggregate window must be positive")
+	}
+	if opts.Threshold < 0 {
+		return nil, fmt.Errorf("aggregate threshold must not be negative")
+	}
+	if opts.MaxListed <= 0 {
+		opts.MaxListed = 20
+	}
+	if opts.TopN <= 0 {
+		opts.TopN = 3
+	}
+	if len(opts.Events) == 0 {
+		opts.Events = []string{EventBan}
+	}
+	return &Aggregator{Notifier: n, Name: name, opts: opts}, nil
+}
+
+// Notify 实现 Notifier 接口，被聚合的事件直接返回 nil，窗口结束时统一发送。
+// 队列重试的事件首次发送时已计入窗口，直接发送
+func (a *Aggregator) Notify(ctx context.Context, eventType string, vars map[string]string) error {
+	if !containsExact(a.opts.Events, eventType) || isRetry(ctx) {
+		return a.Notifier.Notify(ctx, eventType, vars)
+	}
+
+	a.mu.Lock()
+	now := time.Now()
+	if a.windowEnd.IsZero() {
+		a.windowStart = now
+		a.windowEnd = now.Add(a.opts.Window)
+		a.passed = 0
+		time.AfterFunc(a.opts.Window, a.flush)
+	}
+
+	limit := a.opts.Threshold
+	if a.storm {
+		limit = 0
+	}
+	if a.passed < limit {
+		a.passed++
+		a.mu.Unlock()
+		return a.Notifier.Notify(ctx, eventType, vars)
+	}
+
+	a.buffered = append(a.buffered, vars)
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *Aggregator) attach(q *Queue) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.queue = q
+}
+
+// 窗口结束，发送汇总
+func (a *Aggregator) flush() {
+	a.mu.Lock()
+	buffered := a.buffered
+	start := a.windowStart
+	queue := a.queue
+	a.buffered = nil
+	a.windowEnd = time.Time{}
+	a.storm = len(buffered) > 0
+	a.mu.Unlock()
+
+	if len(buffered) == 0 {
+		return
+	}
+
+	vars := a.summarize(start, buffered)
+	if queue != nil {
+		queue.enqueueTo(a.Name, a.Notifier, EventSummary, vars)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := a.Notifier.Notify(ctx, EventSummary, vars); err != nil {
+		logf.Log.WithName("notify-aggregator").Error(err, "Send summary notification failed",
+			"channel", a.Name, "aggregated", len(buffered))
+		notifyTotal.WithLabelValues(a.Name, EventSummary, resultFailed).Inc()
+		return
+	}
+	notifyTotal.WithLabelValues(a.Name, EventSummary, resultSuccess).Inc()
+}
+
+// 通知器中的聚合器，包括路由各通道上的
+func aggregators(n Notifier) []*Aggregator {
+	switch n := n.(type) {
+	case *Aggregator:
+		return []*Aggregator{n}
+	case *Router:
+		var all []*Aggregator
+		for _, name := range n.Channels() {
+			channel, _ := n.Channel(name)
+			all = append(all, aggregators(channel)...)
+		}
+		return all
+	}
+	return nil
+}
+
+func (a *Aggregator) summarize(start time.Time, events []map[string]string) map[string]string {
+	ips := make([]string, 0, len(events))
+	seen := make(map[string]struct{}, len(events))
+	reasons := make(map[string]int)
+	sources := make(map[string]int)
+	for _, vars := range events {
+		if ip := vars[VarIP]; ip != "" {
+			if _, ok := seen[ip]; !ok {
+				seen[ip] = struct{}{}
+				ips = append(ips, ip)
+			}
+		}
+		reasons[vars[VarReason]]++
+		sources[vars[VarSource]]++
+	}
+
+	listed := ips
+	if len(listed) > a.opts.MaxListed {
+		listed = listed[:a.opts.MaxListed]
+	}
+	ipList := strings.Join(listed, ", ")
+	if more := len(ips) - len(listed); more > 0 {
+		ipList += fmt.Sprintf(" ... (+%d more)", more)
+	}
+
+	return map[string]string{
+		VarCount:       fmt.Sprintf("%d", len(events)),
+		VarIPs:         ipList,
+		VarTopReasons:  topCounts(reasons, a.opts.TopN, 60),
+		VarTopSources:  topCounts(sources, a.opts.TopN, 0),
+		VarWindowStart: start.UTC().Format(time.RFC3339),
+		VarTime:        time.Now().UTC().Format(time.RFC3339),
+		VarSeverity:    SeverityWarning,
+	}
+}
+
+// 按次数降序输出前 n 项，如 "grafana ×12; feed/spamhaus ×3"，maxLen > 0 时截断过长的项
+func topCounts(counts map[string]int, n, maxLen int) string {
+	type kv struct {
+		key   string
+		count int
+	}
+	items := make([]kv, 0, len(counts))
+	for k, c := range counts {
+		if k == "" {
+			k = "-"
+		}
+		items = append(items, kv{k, c})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].count != items[j].count {
+			return items[i].count > items[j].count
+		}
+		return items[i].key < items[j].key
+	})
+	if len(items) > n {
+		items = items[:n]
+	}
+
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		key := item.key
+		if r := []rune(key); maxLen > 0 && len(r) > maxLen {
+			key = string(r[:maxLen]) + "..."
+		}
+		parts = append(parts, fmt.Sprintf("%s ×%d", key, item.count))
+	}
+	return strings.Join(parts, "; ")
+}
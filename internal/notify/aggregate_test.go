@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sentEvent struct {
+	eventType string
+	vars      map[string]string
+}
+
+type collectNotifier struct {
+	mu   sync.Mutex
+	sent []sentEvent
+}
+
+func (c *collectNotifier) Notify(_ context.Context, eventType string, vars map[string]string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, sentEvent{eventType, vars})
+	return nil
+}
+
+func (c *collectNotifier) events() []sentEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]sentEvent(nil), c.sent...)
+}
+
+func waitForEvents(t *testing.T, c *collectNotifier, n int) []sentEvent {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := c.events(); len(got) >= n {
+			return got
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d events, got %d", n, len(c.events()))
+	return nil
+}
+
+func TestAggregatorSummarizesStorm(t *testing.T) {
+	out := &collectNotifier{}
+	a, err := NewAggregator(out, "test", AggregateOptions{Window: 50 * time.Millisecond, Threshold: 2, MaxListed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		reason := "scan"
+		if i%3 == 0 {
+			reason = "login failed"
+		}
+		vars := map[string]string{VarIP: fmt.Sprintf("203.0.113.%d", i), VarReason: reason, VarSource: "grafana"}
+		if err := a.Notify(ctx, EventBan, vars); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 非聚合事件直接透传
+	_ = a.Notify(ctx, EventResolve, map[string]string{VarIP: "203.0.113.1"})
+
+	got := waitForEvents(t, out, 4)
+	if got[0].eventType != EventBan || got[1].eventType != EventBan || got[2].eventType != EventResolve {
+		t.Fatalf("unexpected pass-through events: %+v", got[:3])
+	}
+
+	summary := got[3]
+	if summary.eventType != EventSummary {
+		t.Fatalf("expected summary, got %s", summary.eventType)
+	}
+	if summary.vars[VarCount] != "8" {
+		t.Errorf("count = %q, want 8", summary.vars[VarCount])
+	}
+	if !strings.HasSuffix(summary.vars[VarIPs], "(+5 more)") {
+		t.Errorf("ips = %q", summary.vars[VarIPs])
+	}
+	if summary.vars[VarTopReasons] != "scan ×5; login failed ×3" {
+		t.Errorf("topReasons = %q", summary.vars[VarTopReasons])
+	}
+
+	// 上一个窗口发生过聚合，新窗口内的事件全部聚合
+	_ = a.Notify(ctx, EventBan, map[string]string{VarIP: "203.0.113.100"})
+	got = waitForEvents(t, out, 5)
+	if got[4].eventType != EventSummary || got[4].vars[VarCount] != "1" {
+		t.Fatalf("expected storm summary, got %+v", got[4])
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	count, per, err := ParseRate("100/m")
+	if err != nil || count != 100 || per != time.Minute {
+		t.Fatalf("ParseRate(100/m) = %d, %v, %v", count, per, err)
+	}
+	for _, spec := range []string{"", "5", "0/s", "5/d", "x/s"} {
+		if _, _, err := ParseRate(spec); err == nil {
+			t.Errorf("ParseRate(%q) expected error", spec)
+		}
+	}
+}
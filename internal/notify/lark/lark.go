@@ -5,27 +5,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github/Beatrueman/ipblock-operator/internal/notify"
 	"io/ioutil"
 	"net/http"
-	"strings"
 	"time"
 )
 
 type LarkNotify struct {
 	WebhookURL string
 	Client     *http.Client
-	Template   map[string]string // 卡片json模板
+	Template   *notify.Templates // 卡片json模板
 }
 
 // 创建一个飞书实例
 func NewLarkNotify(webhookURL string, templatePaths map[string]string) (*LarkNotify, error) {
-	templates := make(map[string]string)
-	for eventType, path := range templatePaths {
-		data, err := ioutil.ReadFile(path)
-		if err != nil {
-			return nil, fmt.Errorf("read template for '%s' failed: %w", eventType, err)
-		}
-		templates[eventType] = string(data)
+	// 加载时即校验模板，避免发送时才发现 JSON 不合法
+	parsed, err := notify.ParseTemplateFiles(templatePaths)
+	if err != nil {
+		return nil, err
 	}
 
 	return &LarkNotify{
@@ -33,25 +30,20 @@ func NewLarkNotify(webhookURL string, templatePaths map[string]string) (*LarkNot
 		Client: &http.Client{
 			Timeout: time.Second * 5,
 		},
-		Template: templates,
+		Template: parsed,
 	}, nil
 }
 
 func (l *LarkNotify) Notify(ctx context.Context, eventType string, vars map[string]string) error {
 	//logger := logf.FromContext(ctx)
 
-	template, ok := l.Template[eventType]
-	if !ok {
-		return fmt.Errorf("no template found for event type: %s", eventType)
-	}
-
-	bodyStr := template
-	for k, v := range vars {
-		bodyStr = strings.ReplaceAll(bodyStr, "${"+k+"}", v)
+	card, err := l.Template.Render(eventType, vars)
+	if err != nil {
+		return err
 	}
 
 	var cardContent map[string]interface{}
-	if err := json.Unmarshal([]byte(bodyStr), &cardContent); err != nil {
+	if err := json.Unmarshal(card, &cardContent); err != nil {
 		return err
 	}
 
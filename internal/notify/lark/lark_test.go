@@ -0,0 +1,41 @@
+package lark
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github/Beatrueman/ipblock-operator/internal/notify"
+)
+
+func TestNotifyWithShippedTemplates(t *testing.T) {
+	var payload map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	l, err := NewLarkNotify(srv.URL, map[string]string{
+		notify.EventBan:     "ban.json",
+		notify.EventResolve: "resolve.json",
+		notify.EventCommon:  "common.json",
+	})
+	if err != nil {
+		t.Fatalf("shipped templates should pass validation: %v", err)
+	}
+
+	vars := notify.SampleVars()
+	vars[notify.VarReason] = "Grafana description with \"quotes\"\nand a newline"
+	for _, event := range []string{notify.EventBan, notify.EventResolve, notify.EventCommon} {
+		if err := l.Notify(context.Background(), event, vars); err != nil {
+			t.Fatalf("notify %s failed: %v", event, err)
+		}
+		if payload["msg_type"] != "interactive" {
+			t.Fatalf("unexpected payload for %s: %v", event, payload)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package notify
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// 通知发送结果
+const (
+	resultSuccess    = "success"
+	resultFailed     = "failed"
+	resultRetry      = "retry"
+	resultDeadLetter = "dead_letter"
+	resultDropped    = "dropped"
+)
+
+var (
+	notifyTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ipblock_notify_total",
+		Help: "Number of notification attempts by channel, event type and result.",
+	}, []string{"channel", "event", "result"})
+
+	queueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ipblock_notify_queue_depth",
+		Help: "Number of notifications waiting in the notify queue.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(notifyTotal, queueDepth)
+}
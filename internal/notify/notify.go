@@ -2,6 +2,27 @@ package notify
 
 import "context"
 
+// 事件类型
+const (
+	EventBan     = "ban"     // 封禁成功
+	EventResolve = "resolve" // 解封成功
+	EventCommon  = "common"  // 封禁/解封失败等通用错误
+)
+
+// 事件严重程度，用于路由匹配
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// 路由时读取的通用变量名
+const (
+	VarSource   = "source"
+	VarTags     = "tags" // 逗号分隔
+	VarSeverity = "severity"
+)
+
 type Notifier interface {
 	Notify(ctx context.Context, eventType string, vars map[string]string) error
 }
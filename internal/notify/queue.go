@@ -0,0 +1,318 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Dispatcher 可按通道拆分投递的通知器（如 Router），
+// 队列据此为每个通道单独重试和计数
+type Dispatcher interface {
+	Targets(eventType string, vars map[string]string) map[string]Notifier
+}
+
+// DefaultChannel 非 Dispatcher 通知器在队列中使用的通道名
+const DefaultChannel = "default"
+
+// QueueOptions 通知队列参数，零值使用默认值
+type QueueOptions struct {
+	Size         int           // 队列容量，默认 1000
+	Workers      int           // 发送协程数，默认 4
+	MaxAttempts  int           // 最大发送次数（含首次），默认 5
+	BaseBackoff  time.Duration // 首次重试间隔，之后指数增长，默认 1s
+	MaxBackoff   time.Duration // 最大重试间隔，默认 1m
+	Timeout      time.Duration // 单次发送超时，默认 10s
+	DrainTimeout time.Duration // 停止时等待队列排空的最长时间，默认 30s
+	DeadLetters  int           // 保留的死信数量，默认 100
+}
+
+// DeadLetter 超过最大重试次数仍发送失败的通知
+type DeadLetter struct {
+	Channel   string
+	EventType string
+	Vars      map[string]string
+	Attempts  int
+	Err       string
+	Time      time.Time
+}
+
+type job struct {
+	channel   string
+	notifier  Notifier
+	eventType string
+	vars      map[string]string
+	attempt   int
+}
+
+// Queue 有界通知队列：Enqueue 非阻塞投递，后台协程发送并按指数退避重试，
+// 超过重试次数的通知进入死信。Queue 实现 manager.Runnable，随 Manager 启停，
+// 停止时在 DrainTimeout 内尽量发送完队列中的通知。
+type Queue struct {
+	opts     QueueOptions
+	jobs     chan *job
+	notifier atomic.Value // notifierHolder
+	mu       sync.RWMutex // 保护 closed，避免停止时与 Enqueue 竞争
+	closed   bool
+	pending  sync.WaitGroup // 尚未完成（含等待重试）的通知
+	stop     chan struct{}
+
+	deadMu sync.Mutex
+	dead   []DeadLetter
+}
+
+type notifierHolder struct {
+	n Notifier
+}
+
+type retryKey struct{}
+
+// 标记队列重试的发送。重试会再次经过整个通知器链，聚合器据此直接放行，
+// 避免同一事件再次计入窗口阈值或被并入汇总
+func withRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+func isRetry(ctx context.Context) bool {
+	retry, _ := ctx.Value(retryKey{}).(bool)
+	return retry
+}
+
+// NewQueue 创建通知队列
+func NewQueue(opts QueueOptions) *Queue {
+	if opts.Size <= 0 {
+		opts.Size = 1000
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Minute
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = 30 * time.Second
+	}
+	if opts.DeadLetters <= 0 {
+		opts.DeadLetters = 100
+	}
+	q := &Queue{
+		opts: opts,
+		jobs: make(chan *job, opts.Size),
+		stop: make(chan struct{}),
+	}
+	q.notifier.Store(notifierHolder{})
+	return q
+}
+
+// SetNotifier 热更新通知器，nil 表示关闭通知；已入队的通知仍使用旧通知器发送。
+// 通知器中的聚合器改为通过队列发送汇总，与普通通知一样重试和进入死信
+func (q *Queue) SetNotifier(n Notifier) {
+	for _, a := range aggregators(n) {
+		a.attach(q)
+	}
+	q.notifier.Store(notifierHolder{n: n})
+}
+
+// Enabled 是否配置了通知器
+func (q *Queue) Enabled() bool {
+	return q.notifier.Load().(notifierHolder).n != nil
+}
+
+// Enqueue 投递通知，按通道拆分为独立任务；队列已满或已停止时丢弃并计数
+func (q *Queue) Enqueue(eventType string, vars map[string]string) {
+	n := q.notifier.Load().(notifierHolder).n
+	if n == nil {
+		return
+	}
+
+	targets := map[string]Notifier{DefaultChannel: n}
+	if d, ok := n.(Dispatcher); ok {
+		targets = d.Targets(eventType, vars)
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	for channel, target := range targets {
+		q.push(&job{channel: channel, notifier: target, eventType: eventType, vars: vars, attempt: 1})
+	}
+}
+
+// enqueueTo 投递到指定通道，用于聚合器在窗口结束时发送汇总
+func (q *Queue) enqueueTo(channel string, n Notifier, eventType string, vars map[string]string) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	q.push(&job{channel: channel, notifier: n, eventType: eventType, vars: vars, attempt: 1})
+}
+
+// 调用方需持有 q.mu 读锁
+func (q *Queue) push(j *job) {
+	if q.closed {
+		q.drop(j, "queue stopped")
+		return
+	}
+
+	q.pending.Add(1)
+	select {
+	case q.jobs <- j:
+		queueDepth.Set(float64(len(q.jobs)))
+	default:
+		q.pending.Done()
+		q.drop(j, "queue full")
+	}
+}
+
+// Start 启动发送协程并阻塞到 ctx 结束，随后提前结束聚合窗口、发送汇总并排空队列
+func (q *Queue) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx).WithName("notify-queue")
+
+	sendCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var workers sync.WaitGroup
+	for i := 0; i < q.opts.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			q.work(sendCtx)
+		}()
+	}
+	logger.Info("Notify queue started", "workers", q.opts.Workers, "size", q.opts.Size)
+
+	<-ctx.Done()
+	// 未结束的聚合窗口在停止前发送汇总，避免丢失被聚合的通知
+	for _, a := range aggregators(q.notifier.Load().(notifierHolder).n) {
+		a.flush()
+	}
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	logger.Info("Draining notify queue", "pending", len(q.jobs))
+
+	drained := make(chan struct{})
+	go func() {
+		q.pending.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		logger.Info("Notify queue drained")
+	case <-time.After(q.opts.DrainTimeout):
+		logger.Info("Notify queue drain timed out, remaining notifications are lost", "remaining", len(q.jobs))
+	}
+
+	close(q.stop)
+	cancel()
+	workers.Wait()
+	return nil
+}
+
+// NeedLeaderElection 通知队列无需选主，所有副本都应能发送通知
+func (q *Queue) NeedLeaderElection() bool {
+	return false
+}
+
+// DeadLetters 返回最近的死信
+func (q *Queue) DeadLetters() []DeadLetter {
+	q.deadMu.Lock()
+	defer q.deadMu.Unlock()
+	out := make([]DeadLetter, len(q.dead))
+	copy(out, q.dead)
+	return out
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		select {
+		case <-q.stop:
+			return
+		case j := <-q.jobs:
+			queueDepth.Set(float64(len(q.jobs)))
+			q.process(ctx, j)
+		}
+	}
+}
+
+func (q *Queue) process(ctx context.Context, j *job) {
+	logger := logf.Log.WithName("notify-queue")
+
+	sendCtx, cancel := context.WithTimeout(ctx, q.opts.Timeout)
+	if j.attempt > 1 {
+		sendCtx = withRetry(sendCtx)
+	}
+	err := safeNotify(sendCtx, j.notifier, j.eventType, j.vars)
+	cancel()
+
+	if err == nil {
+		notifyTotal.WithLabelValues(j.channel, j.eventType, resultSuccess).Inc()
+		q.pending.Done()
+		return
+	}
+
+	notifyTotal.WithLabelValues(j.channel, j.eventType, resultFailed).Inc()
+	if j.attempt >= q.opts.MaxAttempts || ctx.Err() != nil {
+		q.deadLetter(j, err)
+		q.pending.Done()
+		return
+	}
+
+	backoff := q.backoff(j.attempt)
+	logger.Info("Notify failed, will retry", "channel", j.channel, "event", j.eventType,
+		"attempt", j.attempt, "backoff", backoff.String(), "error", err.Error())
+	j.attempt++
+	notifyTotal.WithLabelValues(j.channel, j.eventType, resultRetry).Inc()
+
+	time.AfterFunc(backoff, func() {
+		select {
+		case q.jobs <- j:
+			queueDepth.Set(float64(len(q.jobs)))
+		case <-q.stop:
+			q.deadLetter(j, err)
+			q.pending.Done()
+		}
+	})
+}
+
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.opts.BaseBackoff << (attempt - 1)
+	if d <= 0 || d > q.opts.MaxBackoff {
+		return q.opts.MaxBackoff
+	}
+	return d
+}
+
+func (q *Queue) deadLetter(j *job, err error) {
+	logf.Log.WithName("notify-queue").Error(err, "Notify dead-lettered",
+		"channel", j.channel, "event", j.eventType, "attempts", j.attempt, "ip", j.vars[VarIP])
+	notifyTotal.WithLabelValues(j.channel, j.eventType, resultDeadLetter).Inc()
+
+	q.deadMu.Lock()
+	defer q.deadMu.Unlock()
+	q.dead = append(q.dead, DeadLetter{
+		Channel:   j.channel,
+		EventType: j.eventType,
+		Vars:      j.vars,
+		Attempts:  j.attempt,
+		Err:       err.Error(),
+		Time:      time.Now(),
+	})
+	if len(q.dead) > q.opts.DeadLetters {
+		q.dead = q.dead[len(q.dead)-q.opts.DeadLetters:]
+	}
+}
+
+func (q *Queue) drop(j *job, reason string) {
+	logf.Log.WithName("notify-queue").Info("Notify dropped", "channel", j.channel, "event", j.eventType, "reason", reason)
+	notifyTotal.WithLabelValues(j.channel, j.eventType, resultDropped).Inc()
+}
@@ -0,0 +1,176 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type flakyNotifier struct {
+	failures int32 // 前 failures 次发送失败
+	calls    atomic.Int32
+}
+
+func (n *flakyNotifier) Notify(context.Context, string, map[string]string) error {
+	if n.calls.Add(1) <= n.failures {
+		return errors.New("temporary failure")
+	}
+	return nil
+}
+
+func TestQueueRetriesAndDeadLetters(t *testing.T) {
+	q := NewQueue(QueueOptions{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+
+	recovering := &flakyNotifier{failures: 2}
+	broken := &flakyNotifier{failures: 100}
+	router, err := NewRouter(map[string]Notifier{"recovering": recovering, "broken": broken}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.SetNotifier(router)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = q.Start(ctx)
+		close(done)
+	}()
+
+	q.Enqueue(EventBan, map[string]string{VarIP: "1.2.3.4"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(q.DeadLetters()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if got := recovering.calls.Load(); got != 3 {
+		t.Errorf("recovering channel should succeed on 3rd attempt, got %d calls", got)
+	}
+	if got := broken.calls.Load(); got != 3 {
+		t.Errorf("broken channel should stop after MaxAttempts, got %d calls", got)
+	}
+	dead := q.DeadLetters()
+	if len(dead) != 1 || dead[0].Channel != "broken" || dead[0].Attempts != 3 {
+		t.Fatalf("unexpected dead letters: %+v", dead)
+	}
+}
+
+func TestQueueDrainsOnStop(t *testing.T) {
+	q := NewQueue(QueueOptions{Workers: 1})
+	n := &flakyNotifier{}
+	q.SetNotifier(n)
+
+	// 启动前入队，停止时应全部发送完
+	for i := 0; i < 10; i++ {
+		q.Enqueue(EventResolve, map[string]string{})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := q.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.calls.Load(); got != 10 {
+		t.Fatalf("expected 10 notifications drained, got %d", got)
+	}
+
+	// 停止后的通知直接丢弃
+	q.Enqueue(EventResolve, map[string]string{})
+	if got := n.calls.Load(); got != 10 {
+		t.Fatalf("notifications after stop should be dropped, got %d", got)
+	}
+}
+
+// 首次发送失败，之后记录收到的通知
+type failOnceNotifier struct {
+	collectNotifier
+	failed atomic.Bool
+}
+
+func (n *failOnceNotifier) Notify(ctx context.Context, eventType string, vars map[string]string) error {
+	if n.failed.CompareAndSwap(false, true) {
+		return errors.New("temporary failure")
+	}
+	return n.collectNotifier.Notify(ctx, eventType, vars)
+}
+
+func TestQueueRetriesAggregatedSummaryAndFlushesOnStop(t *testing.T) {
+	q := NewQueue(QueueOptions{BaseBackoff: time.Millisecond})
+	out := &failOnceNotifier{}
+	a, err := NewAggregator(out, "lark", AggregateOptions{Window: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := NewRouter(map[string]Notifier{"lark": a}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.SetNotifier(router)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = q.Start(ctx)
+		close(done)
+	}()
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		q.Enqueue(EventBan, map[string]string{VarIP: ip})
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		a.mu.Lock()
+		buffered := len(a.buffered)
+		a.mu.Unlock()
+		if buffered == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d events aggregated", buffered)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 停止时窗口尚未结束，汇总经队列重试后送达
+	cancel()
+	<-done
+	got := out.events()
+	if len(got) != 1 || got[0].eventType != EventSummary || got[0].vars[VarCount] != "3" {
+		t.Fatalf("unexpected notifications: %+v", got)
+	}
+	if dead := q.DeadLetters(); len(dead) != 0 {
+		t.Errorf("unexpected dead letters: %+v", dead)
+	}
+}
+
+// 重试的事件不再计入聚合窗口，也不会被并入汇总
+func TestQueueRetryBypassesAggregator(t *testing.T) {
+	q := NewQueue(QueueOptions{BaseBackoff: time.Millisecond})
+	out := &failOnceNotifier{}
+	a, err := NewAggregator(out, "lark", AggregateOptions{Window: time.Hour, Threshold: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.SetNotifier(a)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = q.Start(ctx)
+		close(done)
+	}()
+	q.Enqueue(EventBan, map[string]string{VarIP: "203.0.113.1"})
+	got := waitForEvents(t, &out.collectNotifier, 1)
+	cancel()
+	<-done
+
+	if got[0].eventType != EventBan || got[0].vars[VarIP] != "203.0.113.1" {
+		t.Fatalf("unexpected notifications: %+v", got)
+	}
+	if got := out.events(); len(got) != 1 {
+		t.Errorf("retried event should be sent once without a summary: %+v", got)
+	}
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter 通知器装饰器，按通道限速；超过速率时等待，等待超出 ctx 期限则返回错误交由队列重试
+type RateLimiter struct {
+	Notifier Notifier
+	Limiter  *rate.Limiter
+}
+
+// NewRateLimiter 创建限速装饰器，spec 形如 "5/s"、"100/m"、"1000/h"，
+// 允许的突发数量等于每个周期的次数
+func NewRateLimiter(n Notifier, spec string) (*RateLimiter, error) {
+	count, per, err := ParseRate(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &RateLimiter{
+		Notifier: n,
+		Limiter:  rate.NewLimiter(rate.Every(per/time.Duration(count)), count),
+	}, nil
+}
+
+// Notify 实现 Notifier 接口
+func (r *RateLimiter) Notify(ctx context.Context, eventType string, vars map[string]string) error {
+	if err := r.Limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limited: %w", err)
+	}
+	return r.Notifier.Notify(ctx, eventType, vars)
+}
+
+// ParseRate 解析 "N/s"、"N/m"、"N/h" 形式的速率
+func ParseRate(spec string) (int, time.Duration, error) {
+	parts := strings.SplitN(strings.TrimSpace(spec), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid rate '%s', expected N/s, N/m or N/h", spec)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate '%s': count must be a positive integer", spec)
+	}
+	var per time.Duration
+	switch strings.TrimSpace(parts[1]) {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return 0, 0, fmt.Errorf("invalid rate '%s': unit must be s, m or h", spec)
+	}
+	return count, per, nil
+}
@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Match 路由匹配条件，字段为空表示不限制；同一字段内任意一项命中即可，
+// Sources 和 Tags 支持 path.Match 通配（如 "feed/*"）
+type Match struct {
+	EventTypes []string `json:"eventTypes,omitempty"`
+	Sources    []string `json:"sources,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Severities []string `json:"severities,omitempty"`
+}
+
+// Route 路由规则：事件命中 Match 时投递到 Channels 中的所有通道
+type Route struct {
+	Match    Match    `json:"match,omitempty"`
+	Channels []string `json:"channels"`
+}
+
+// Router 多通道通知器，按路由规则把事件分发给多个 Notifier，
+// 各通道并发发送、互不影响
+type Router struct {
+	channels map[string]Notifier
+	routes   []Route
+}
+
+// NewRouter 创建路由通知器，routes 为空时事件投递到所有通道
+func NewRouter(channels map[string]Notifier, routes []Route) (*Router, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("no notify channel configured")
+	}
+	for i, route := range routes {
+		if len(route.Channels) == 0 {
+			return nil, fmt.Errorf("route #%d has no channels", i)
+		}
+		for _, name := range route.Channels {
+			if _, ok := channels[name]; !ok {
+				return nil, fmt.Errorf("route #%d references unknown channel '%s'", i, name)
+			}
+		}
+	}
+	return &Router{channels: channels, routes: routes}, nil
+}
+
+// Channels 返回所有通道名（已排序）
+func (r *Router) Channels() []string {
+	names := make([]string, 0, len(r.channels))
+	for name := range r.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Channel 按名称获取通道
+func (r *Router) Channel(name string) (Notifier, bool) {
+	n, ok := r.channels[name]
+	return n, ok
+}
+
+// Resolve 返回事件应投递的通道名（去重、已排序）
+func (r *Router) Resolve(eventType string, vars map[string]string) []string {
+	if len(r.routes) == 0 {
+		return r.Channels()
+	}
+
+	seen := make(map[string]struct{})
+	for _, route := range r.routes {
+		if !route.Match.matches(eventType, vars) {
+			continue
+		}
+		for _, name := range route.Channels {
+			seen[name] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Targets 实现 Dispatcher 接口，返回事件应投递的通道
+func (r *Router) Targets(eventType string, vars map[string]string) map[string]Notifier {
+	names := r.Resolve(eventType, vars)
+	targets := make(map[string]Notifier, len(names))
+	for _, name := range names {
+		targets[name] = r.channels[name]
+	}
+	return targets
+}
+
+// Notify 实现 Notifier 接口，并发投递到所有命中的通道，
+// 单个通道失败（包括 panic）不会影响其他通道，错误按通道汇总返回
+func (r *Router) Notify(ctx context.Context, eventType string, vars map[string]string) error {
+	names := r.Resolve(eventType, vars)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string, n Notifier) {
+			defer wg.Done()
+			if err := safeNotify(ctx, n, eventType, vars); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("channel '%s': %w", name, err))
+				mu.Unlock()
+			}
+		}(name, r.channels[name])
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// 调用单个通道，将 panic 转换为 error
+func safeNotify(ctx context.Context, n Notifier, eventType string, vars map[string]string) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("notifier panic: %v", p)
+		}
+	}()
+	return n.Notify(ctx, eventType, vars)
+}
+
+func (m Match) matches(eventType string, vars map[string]string) bool {
+	if len(m.EventTypes) > 0 && !containsExact(m.EventTypes, eventType) {
+		return false
+	}
+	if len(m.Severities) > 0 && !containsExact(m.Severities, vars[VarSeverity]) {
+		return false
+	}
+	if len(m.Sources) > 0 && !containsPattern(m.Sources, vars[VarSource]) {
+		return false
+	}
+	if len(m.Tags) > 0 {
+		hit := false
+		for _, tag := range SplitTags(vars[VarTags]) {
+			if containsPattern(m.Tags, tag) {
+				hit = true
+				break
+			}
+		}
+		if !hit {
+			return false
+		}
+	}
+	return true
+}
+
+// JoinTags 将标签列表编码为通知变量
+func JoinTags(tags []string) string {
+	return strings.Join(tags, ",")
+}
+
+// SplitTags 解析 JoinTags 编码的标签
+func SplitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func containsExact(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPattern(patterns []string, v string) bool {
+	for _, p := range patterns {
+		if p == v {
+			return true
+		}
+		if ok, err := path.Match(p, v); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
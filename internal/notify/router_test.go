@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type recordNotifier struct {
+	mu     sync.Mutex
+	events []string
+	err    error
+	panic  bool
+}
+
+func (n *recordNotifier) Notify(_ context.Context, eventType string, _ map[string]string) error {
+	if n.panic {
+		panic("boom")
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, eventType)
+	return n.err
+}
+
+func TestRouterResolve(t *testing.T) {
+	channels := map[string]Notifier{
+		"lark":      &recordNotifier{},
+		"pagerduty": &recordNotifier{},
+		"email":     &recordNotifier{},
+	}
+	routes := []Route{
+		{Match: Match{EventTypes: []string{EventBan}, Sources: []string{"grafana"}}, Channels: []string{"lark"}},
+		{Match: Match{EventTypes: []string{EventCommon}}, Channels: []string{"pagerduty"}},
+		{Match: Match{Tags: []string{"feed/*"}, Severities: []string{SeverityWarning}}, Channels: []string{"lark"}},
+		{Channels: []string{"email"}},
+	}
+	r, err := NewRouter(channels, routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		event string
+		vars  map[string]string
+		want  []string
+	}{
+		{EventBan, map[string]string{VarSource: "grafana"}, []string{"email", "lark"}},
+		{EventBan, map[string]string{VarSource: "manual"}, []string{"email"}},
+		{EventCommon, map[string]string{}, []string{"email", "pagerduty"}},
+		{EventBan, map[string]string{VarTags: "a, feed/spamhaus", VarSeverity: SeverityWarning}, []string{"email", "lark"}},
+		{EventBan, map[string]string{VarTags: "feed/spamhaus", VarSeverity: SeverityInfo}, []string{"email"}},
+	}
+	for _, c := range cases {
+		if got := r.Resolve(c.event, c.vars); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Resolve(%s, %v) = %v, want %v", c.event, c.vars, got, c.want)
+		}
+	}
+}
+
+func TestRouterIsolatesChannelFailures(t *testing.T) {
+	ok := &recordNotifier{}
+	failing := &recordNotifier{err: errors.New("rate limited")}
+	panicking := &recordNotifier{panic: true}
+
+	r, err := NewRouter(map[string]Notifier{"ok": ok, "failing": failing, "panicking": panicking}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Notify(context.Background(), EventBan, map[string]string{}); err == nil {
+		t.Fatal("expected joined error from failing channels")
+	}
+	if len(ok.events) != 1 {
+		t.Fatalf("healthy channel should still receive the event, got %v", ok.events)
+	}
+}
+
+func TestNewRouterRejectsUnknownChannel(t *testing.T) {
+	_, err := NewRouter(map[string]Notifier{"lark": &recordNotifier{}}, []Route{{Channels: []string{"missing"}}})
+	if err == nil {
+		t.Fatal("expected error for unknown channel")
+	}
+}
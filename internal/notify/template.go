@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"text/template"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// 模板中可用的变量名
+const (
+	VarIP        = "ip"
+	VarCIDR      = "cidr"
+	VarReason    = "reason"
+	VarBy        = "by"
+	VarDuration  = "duration"
+	VarExpiresAt = "expiresAt"
+	VarBanCount  = "banCount"
+	VarCluster   = "cluster"
+	VarName      = "name"
+	VarNamespace = "namespace"
+	VarResult    = "result"     // 封禁后端返回信息
+	VarMsg       = "msg"        // 错误信息
+	VarAlarmTime = "alarm_time" // 按通道时区格式化后的事件时间
+)
+
+// 模板函数：
+//   - json: 输出带引号的 JSON 字符串，如 "content": {{ .reason | json }}
+//   - jsonEscape: 输出转义后的字符串内容（不带引号），用于拼接在 JSON 字符串内部
+//   - default: 变量为空时使用默认值，如 {{ default "-" .by }}
+//   - truncate: 按字符截断，如 {{ truncate 200 .reason }}
+var funcMap = template.FuncMap{
+	"json":       jsonQuote,
+	"jsonEscape": jsonEscape,
+	"default": func(def, v string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	},
+	"truncate": func(n int, v string) string {
+		r := []rune(v)
+		if len(r) <= n {
+			return v
+		}
+		return string(r[:n]) + "..."
+	},
+}
+
+// 旧版 ${var} 占位符，加载时转换为 {{ .var | jsonEscape }}
+var legacyPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// 旧版变量改名后的对应关系：旧模板的 ${count} 是封禁次数，count 现在表示汇总中被聚合的事件数
+var legacyVarNames = map[string]string{
+	"count": VarBanCount,
+}
+
+// Templates 按事件类型索引的通知模板，渲染结果必须是合法 JSON
+type Templates struct {
+	tmpls map[string]*template.Template
+}
+
+// ParseTemplates 解析模板并使用包含特殊字符的样例变量试渲染，
+// 未正确转义变量的模板会在加载时报错，而不是在发送时丢失通知
+func ParseTemplates(sources map[string]string) (*Templates, error) {
+	t := &Templates{tmpls: make(map[string]*template.Template, len(sources))}
+	for eventType, src := range sources {
+		if legacyPlaceholder.MatchString(src) {
+			// 旧模板中的占位符都位于 JSON 字符串内部，按 jsonEscape 转义后与原先的替换结果一致
+			src = legacyPlaceholder.ReplaceAllStringFunc(src, func(m string) string {
+				name := legacyPlaceholder.FindStringSubmatch(m)[1]
+				if renamed, ok := legacyVarNames[name]; ok {
+					name = renamed
+				}
+				return "{{ ." + name + " | jsonEscape }}"
+			})
+			logf.Log.WithName("notify-template").Info("Template uses deprecated ${var} placeholder, converted to {{ .var | jsonEscape }}", "event", eventType)
+		}
+		tmpl, err := template.New(eventType).Funcs(funcMap).Option("missingkey=zero").Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("parse template '%s' failed: %w", eventType, err)
+		}
+		t.tmpls[eventType] = tmpl
+
+		if _, err := t.Render(eventType, SampleVars()); err != nil {
+			return nil, fmt.Errorf("validate template '%s' failed: %w", eventType, err)
+		}
+	}
+	return t, nil
+}
+
+// ParseTemplateFiles 读取事件类型 -> 模板路径中的模板文件并解析校验
+func ParseTemplateFiles(paths map[string]string) (*Templates, error) {
+	sources := make(map[string]string, len(paths))
+	for eventType, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read template for '%s' failed: %w", eventType, err)
+		}
+		sources[eventType] = string(data)
+	}
+	return ParseTemplates(sources)
+}
+
+// Has 判断是否存在对应事件类型的模板
+func (t *Templates) Has(eventType string) bool {
+	_, ok := t.tmpls[eventType]
+	return ok
+}
+
+// Render 渲染事件模板，并校验结果为合法 JSON
+func (t *Templates) Render(eventType string, vars map[string]string) ([]byte, error) {
+	tmpl, ok := t.tmpls[eventType]
+	if !ok {
+		return nil, fmt.Errorf("no template found for event type: %s", eventType)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("template '%s' rendered invalid JSON", eventType)
+	}
+	return buf.Bytes(), nil
+}
+
+// SampleVars 返回用于校验模板的样例变量，值中刻意包含引号、换行和反斜杠
+func SampleVars() map[string]string {
+	return map[string]string{
+		VarIP:          "203.0.113.7",
+		VarCIDR:        "203.0.113.7/32",
+		VarReason:      "【Grafana告警触发】login \"failed\"\n> 100 times \\ 1m",
+		VarSource:      "grafana",
+		VarBy:          "ops\"bot",
+		VarDuration:    "1h",
+		VarExpiresAt:   "2025-01-01T01:00:00Z",
+		VarBanCount:    "3",
+		VarCluster:     "prod",
+		VarName:        "ipblock-0123456789abcdef",
+		VarNamespace:   "default",
+		VarResult:      "Successfully added \"203.0.113.7\"",
+		VarMsg:         "dial tcp: i/o timeout\n\"gateway\"",
+		VarTime:        "2025-01-01T00:00:00Z",
+		VarAlarmTime:   "2025-01-01 08:00:00 CST",
+		VarExpiresTime: "2025-01-01 09:00:00 CST",
+		VarTags:        "grafana,login",
+		VarSeverity:    SeverityWarning,
+		VarCount:       "42",
+		VarIPs:         "203.0.113.7, 203.0.113.8 ... (+40 more)",
+		VarTopReasons:  "login \"failed\" ×30; scan\\probe ×12",
+		VarTopSources:  "grafana ×40; manual ×2",
+		VarWindowStart: "2025-01-01T00:00:00Z",
+	}
+}
+
+func jsonQuote(v string) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func jsonEscape(v string) string {
+	s := jsonQuote(v)
+	return s[1 : len(s)-1]
+}
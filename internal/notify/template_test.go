@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseTemplatesRejectsUnsafeTemplates(t *testing.T) {
+	cases := map[string]string{
+		"unescaped": `{"text": "{{ .reason }}"}`,
+		"syntax":    `{"text": {{ .reason | json }`,
+	}
+	for name, src := range cases {
+		if _, err := ParseTemplates(map[string]string{EventBan: src}); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+// 旧版 ${var} 占位符转换为 jsonEscape，不再导致整个通知配置加载失败
+func TestParseTemplatesConvertsLegacyPlaceholders(t *testing.T) {
+	tmpls, err := ParseTemplates(map[string]string{EventBan: `{"text": "${ip} ${alarm_time}: ${reason}"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := SampleVars()
+	out, err := tmpls.Render(EventBan, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := vars[VarIP] + " " + vars[VarAlarmTime] + ": " + vars[VarReason]; got.Text != want {
+		t.Errorf("text = %q, want %q", got.Text, want)
+	}
+}
+
+// 旧版飞书封禁卡片的 ${count} 对应现在的 banCount
+func TestParseTemplatesConvertsLegacyBanCard(t *testing.T) {
+	data, err := os.ReadFile("testdata/legacy_ban.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpls, err := ParseTemplates(map[string]string{EventBan: string(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := SampleVars()
+	out, err := tmpls.Render(EventBan, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[第" + vars[VarBanCount] + "次封禁]"; !strings.Contains(string(out), want) {
+		t.Errorf("rendered card does not contain %q:\n%s", want, out)
+	}
+}
+
+func TestRenderEscapesVariables(t *testing.T) {
+	tmpls, err := ParseTemplates(map[string]string{
+		EventBan: `{"title": "ban {{ .ip | jsonEscape }}", "reason": {{ .reason | json }}, "by": {{ default "-" .by | json }}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reason := "line1\n\"quoted\" \\ end"
+	out, err := tmpls.Render(EventBan, map[string]string{VarIP: "1.2.3.4", VarReason: reason})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["reason"] != reason || got["title"] != "ban 1.2.3.4" || got["by"] != "-" {
+		t.Fatalf("unexpected render result: %v", got)
+	}
+	if strings.Contains(string(out), "<no value>") {
+		t.Fatalf("missing keys should render empty: %s", out)
+	}
+}
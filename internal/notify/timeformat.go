@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 默认时区与时间格式，与早期版本固定 UTC+8 的输出保持一致，并附带时区标识
+const (
+	DefaultTimezone   = "Asia/Shanghai"
+	DefaultTimeFormat = "2006-01-02 15:04:05 MST"
+)
+
+// 时间相关变量：time / expiresAt 为 RFC3339 原始时间，
+// alarm_time / expires_time 为按通道时区和格式转换后的时间
+const (
+	VarTime        = "time"
+	VarExpiresTime = "expires_time"
+)
+
+// TimeFormatter 通知器装饰器，按配置的时区和格式生成 alarm_time、expires_time
+type TimeFormatter struct {
+	Notifier Notifier
+	Location *time.Location
+	Layout   string // Go 时间格式
+}
+
+// NewTimeFormatter 创建时间格式化装饰器，timezone 为 IANA 时区名（如 "UTC"、"Europe/Berlin"），
+// 为空时使用默认值
+func NewTimeFormatter(n Notifier, timezone, layout string) (*TimeFormatter, error) {
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+	if layout == "" {
+		layout = DefaultTimeFormat
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone '%s': %w", timezone, err)
+	}
+	return &TimeFormatter{Notifier: n, Location: loc, Layout: layout}, nil
+}
+
+// Notify 实现 Notifier 接口。vars 可能被多个通道共享，这里复制后再写入
+func (t *TimeFormatter) Notify(ctx context.Context, eventType string, vars map[string]string) error {
+	out := make(map[string]string, len(vars)+2)
+	for k, v := range vars {
+		out[k] = v
+	}
+
+	at := time.Now()
+	if ts, err := time.Parse(time.RFC3339, vars[VarTime]); err == nil {
+		at = ts
+	}
+	out[VarAlarmTime] = at.In(t.Location).Format(t.Layout)
+
+	if ts, err := time.Parse(time.RFC3339, vars[VarExpiresAt]); err == nil {
+		out[VarExpiresTime] = ts.In(t.Location).Format(t.Layout)
+	}
+
+	return t.Notifier.Notify(ctx, eventType, out)
+}
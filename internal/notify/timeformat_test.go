@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"testing"
+)
+
+type captureNotifier struct {
+	vars map[string]string
+}
+
+func (c *captureNotifier) Notify(_ context.Context, _ string, vars map[string]string) error {
+	c.vars = vars
+	return nil
+}
+
+func TestTimeFormatterPerChannel(t *testing.T) {
+	vars := map[string]string{
+		VarTime:      "2025-03-01T12:00:00Z",
+		VarExpiresAt: "2025-03-01T13:30:00Z",
+	}
+
+	utc := &captureNotifier{}
+	berlin := &captureNotifier{}
+	fUTC, err := NewTimeFormatter(utc, "UTC", "2006-01-02T15:04 MST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fBerlin, err := NewTimeFormatter(berlin, "Europe/Berlin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = fUTC.Notify(context.Background(), EventBan, vars)
+	_ = fBerlin.Notify(context.Background(), EventBan, vars)
+
+	if got := utc.vars[VarAlarmTime]; got != "2025-03-01T12:00 UTC" {
+		t.Errorf("utc alarm_time = %q", got)
+	}
+	if got := berlin.vars[VarAlarmTime]; got != "2025-03-01 13:00:00 CET" {
+		t.Errorf("berlin alarm_time = %q", got)
+	}
+	if got := berlin.vars[VarExpiresTime]; got != "2025-03-01 14:30:00 CET" {
+		t.Errorf("berlin expires_time = %q", got)
+	}
+	if _, ok := vars[VarAlarmTime]; ok {
+		t.Error("shared vars must not be modified")
+	}
+}
+
+func TestNewTimeFormatterRejectsUnknownZone(t *testing.T) {
+	if _, err := NewTimeFormatter(&captureNotifier{}, "Mars/Olympus", ""); err == nil {
+		t.Fatal("expected error for unknown timezone")
+	}
+}
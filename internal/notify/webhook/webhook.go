@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/notify"
+)
+
+// eventField 未配置模板时，请求体中事件类型所在的字段
+const eventField = "event"
+
+// WebhookNotify 通用 Webhook 通知：把渲染后的模板原样 POST 到 URL，
+// 未配置模板时发送包含事件类型和全部变量的 JSON 对象，便于对接 Slack、钉钉、自建服务等
+type WebhookNotify struct {
+	URL      string
+	Headers  map[string]string // 附加的请求头，如 Authorization
+	Client   *http.Client
+	Template *notify.Templates // 为空时发送默认的 JSON 对象
+}
+
+// NewWebhookNotify templatePaths 为空时使用默认的 JSON 对象
+func NewWebhookNotify(url string, headers map[string]string, templatePaths map[string]string) (*WebhookNotify, error) {
+	w := &WebhookNotify{
+		URL:     url,
+		Headers: headers,
+		Client: &http.Client{
+			Timeout: time.Second * 5,
+		},
+	}
+	if len(templatePaths) > 0 {
+		parsed, err := notify.ParseTemplateFiles(templatePaths)
+		if err != nil {
+			return nil, err
+		}
+		w.Template = parsed
+	}
+	return w, nil
+}
+
+func (w *WebhookNotify) Notify(ctx context.Context, eventType string, vars map[string]string) error {
+	body, err := w.render(eventType, vars)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("notify failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func (w *WebhookNotify) render(eventType string, vars map[string]string) ([]byte, error) {
+	if w.Template != nil {
+		return w.Template.Render(eventType, vars)
+	}
+	payload := make(map[string]string, len(vars)+1)
+	for k, v := range vars {
+		payload[k] = v
+	}
+	payload[eventField] = eventType
+	return json.Marshal(payload)
+}
@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github/Beatrueman/ipblock-operator/internal/notify"
+)
+
+func TestWebhookDefaultPayload(t *testing.T) {
+	var (
+		payload map[string]string
+		auth    string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhookNotify(srv.URL, map[string]string{"Authorization": "Bearer token"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := notify.SampleVars()
+	if err := w.Notify(context.Background(), notify.EventBan, vars); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer token" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if payload[eventField] != notify.EventBan || payload[notify.VarReason] != vars[notify.VarReason] {
+		t.Errorf("unexpected payload %v", payload)
+	}
+}
+
+func TestWebhookTemplateAndErrors(t *testing.T) {
+	status := http.StatusOK
+	var payload map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "ban.json")
+	if err := os.WriteFile(path, []byte(`{"text": "ban {{ .ip | jsonEscape }}"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWebhookNotify(srv.URL, nil, map[string]string{notify.EventBan: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := notify.SampleVars()
+	if err := w.Notify(context.Background(), notify.EventBan, vars); err != nil {
+		t.Fatal(err)
+	}
+	if payload["text"] != "ban "+vars[notify.VarIP] {
+		t.Errorf("unexpected payload %v", payload)
+	}
+	// 配置了模板时，缺少模板的事件类型报错
+	if err := w.Notify(context.Background(), notify.EventCommon, vars); err == nil {
+		t.Error("expected error for event without template")
+	}
+
+	status = http.StatusBadGateway
+	if err := w.Notify(context.Background(), notify.EventBan, vars); err == nil {
+		t.Error("expected error for non-2xx response")
+	}
+}
@@ -0,0 +1,231 @@
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+	pluginv1 "github/Beatrueman/ipblock-operator/internal/plugin/pluginv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Config 一个插件引擎的配置（ConfigMap 的 plugins 字段中的一项）
+type Config struct {
+	Name        string                   `json:"name"`                  // 引擎名，engine 字段或组合引擎的后端引用此名称
+	Address     string                   `json:"address"`               // gRPC 地址，如 my-plugin.ipblock-system:9000
+	Timeout     string                   `json:"timeout,omitempty"`     // 单次调用超时，默认 10s
+	TLS         *engine.GatewayTLSConfig `json:"tls,omitempty"`         // 配置后使用 TLS
+	TokenSecret *engine.SecretKeyRef     `json:"tokenSecret,omitempty"` // 以 authorization: Bearer 元数据发送
+}
+
+// Credentials 从 Secret 中读取的凭据
+type Credentials = engine.GatewayCredentials
+
+// Client 通过 gRPC 调用插件，实现 engine.Adapter 与 engine.CapableAdapter
+type Client struct {
+	Name string
+
+	conn    *grpc.ClientConn
+	client  pluginv1.EnginePluginClient
+	timeout time.Duration
+
+	mu   sync.Mutex
+	caps *engine.Capabilities
+}
+
+// Dial 创建插件客户端，连接在首次调用时建立
+func Dial(cfg Config, creds Credentials) (*Client, error) {
+	if cfg.Name == "" || cfg.Address == "" {
+		return nil, fmt.Errorf("plugin name and address are required")
+	}
+	timeout := defaultTimeout
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid plugin timeout '%s'", cfg.Timeout)
+		}
+		timeout = d
+	}
+
+	transport := insecure.NewCredentials()
+	if cfg.TLS != nil {
+		tlsConfig := &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			ServerName:         cfg.TLS.ServerName,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify, //nolint:gosec // 由用户显式开启
+		}
+		if len(creds.CA) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(creds.CA) {
+				return nil, fmt.Errorf("no valid certificate found in plugin CA")
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if len(creds.Cert) > 0 {
+			cert, err := tls.X509KeyPair(creds.Cert, creds.Key)
+			if err != nil {
+				return nil, fmt.Errorf("load plugin client certificate failed: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport = credentials.NewTLS(tlsConfig)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(transport)}
+	if creds.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken{token: creds.Token, secure: cfg.TLS != nil}))
+	}
+
+	conn, err := grpc.NewClient(cfg.Address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("dial plugin %s failed: %w", cfg.Name, err)
+	}
+	return NewClient(cfg.Name, conn, timeout), nil
+}
+
+// NewClient 使用已有的连接创建客户端
+func NewClient(name string, conn *grpc.ClientConn, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Client{Name: name, conn: conn, client: pluginv1.NewEnginePluginClient(conn), timeout: timeout}
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) Ban(req engine.BanRequest) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	resp, err := c.client.Ban(ctx, toProto(req))
+	if err != nil {
+		return "", fmt.Errorf("plugin %s ban failed: %s", c.Name, describe(err))
+	}
+	return resp.GetMessage(), nil
+}
+
+func (c *Client) UnBan(req engine.BanRequest) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	resp, err := c.client.UnBan(ctx, toProto(req))
+	if err != nil {
+		return "", fmt.Errorf("plugin %s unban failed: %s", c.Name, describe(err))
+	}
+	return resp.GetMessage(), nil
+}
+
+// List 返回插件当前执行中的封禁
+func (c *Client) List(ctx context.Context) ([]*pluginv1.Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	resp, err := c.client.List(ctx, &pluginv1.ListRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s list failed: %s", c.Name, describe(err))
+	}
+	return resp.GetEntries(), nil
+}
+
+// Health 检查插件是否可用，并更新缓存的处置动作
+func (c *Client) Health(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	resp, err := c.client.Health(ctx, &pluginv1.HealthRequest{})
+	if err != nil {
+		return fmt.Errorf("plugin %s health check failed: %s", c.Name, describe(err))
+	}
+	c.setCapabilities(resp.GetCapabilities())
+	if !resp.GetReady() {
+		return fmt.Errorf("plugin %s is not ready: %s", c.Name, resp.GetMessage())
+	}
+	return nil
+}
+
+// Capabilities 返回插件在 Health 中声明的处置动作。尚未成功调用过 Health 时先调用一次，
+// 插件不可达时按只支持 block 处理
+func (c *Client) Capabilities() engine.Capabilities {
+	c.mu.Lock()
+	caps := c.caps
+	c.mu.Unlock()
+	if caps == nil {
+		_ = c.Health(context.Background())
+		c.mu.Lock()
+		caps = c.caps
+		c.mu.Unlock()
+	}
+	if caps == nil {
+		return engine.Capabilities{Actions: []string{engine.ActionBlock}}
+	}
+	return *caps
+}
+
+func (c *Client) setCapabilities(pc *pluginv1.Capabilities) {
+	caps := engine.Capabilities{Actions: pc.GetActions(), RateLimitUnits: pc.GetRateLimitUnits()}
+	if len(caps.Actions) == 0 {
+		caps.Actions = []string{engine.ActionBlock}
+	}
+	c.mu.Lock()
+	c.caps = &caps
+	c.mu.Unlock()
+}
+
+func toProto(req engine.BanRequest) *pluginv1.BanRequest {
+	pr := &pluginv1.BanRequest{
+		Ip:              req.IP,
+		Permanent:       req.Permanent,
+		DurationSeconds: int64(req.DurationSeconds),
+		Source:          req.Source,
+		Tags:            req.Tags,
+		Action:          req.Action,
+		RequestId:       engine.NewRequestID(),
+	}
+	if req.RateLimit != nil {
+		pr.RateLimit = &pluginv1.RateLimit{Pps: req.RateLimit.PPS, Bps: req.RateLimit.BPS, Rps: req.RateLimit.RPS}
+	}
+	return pr
+}
+
+// FromProto 将插件协议的请求转换为 engine.BanRequest，供 Go 编写的插件使用
+func FromProto(pr *pluginv1.BanRequest) engine.BanRequest {
+	req := engine.BanRequest{
+		IP:              pr.GetIp(),
+		Permanent:       pr.GetPermanent(),
+		DurationSeconds: int(pr.GetDurationSeconds()),
+		Source:          pr.GetSource(),
+		Tags:            pr.GetTags(),
+		Action:          pr.GetAction(),
+	}
+	if rl := pr.GetRateLimit(); rl != nil {
+		req.RateLimit = &engine.RateLimit{PPS: rl.GetPps(), BPS: rl.GetBps(), RPS: rl.GetRps()}
+	}
+	return req
+}
+
+func describe(err error) string {
+	if s, ok := status.FromError(err); ok {
+		return fmt.Sprintf("%s: %s", s.Code(), s.Message())
+	}
+	return err.Error()
+}
+
+// bearerToken 以 authorization 元数据发送 Token
+type bearerToken struct {
+	token  string
+	secure bool
+}
+
+func (b bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + b.token}, nil
+}
+
+func (b bearerToken) RequireTransportSecurity() bool {
+	return b.secure
+}
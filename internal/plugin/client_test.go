@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+	"github/Beatrueman/ipblock-operator/internal/plugin/reference"
+)
+
+func startReference(t *testing.T, token string) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(reference.NewServer(), token)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func TestClientAsAdapter(t *testing.T) {
+	addr := startReference(t, "s3cret")
+	client, err := Dial(Config{Name: "reference", Address: addr}, Credentials{Token: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var adapter engine.Adapter = client
+	if _, err := adapter.Ban(engine.BanRequest{IP: "192.0.2.1", DurationSeconds: 60, Source: "grafana"}); err != nil {
+		t.Fatal(err)
+	}
+	rl := engine.BanRequest{IP: "192.0.2.2", Permanent: true, Action: engine.ActionRateLimit, RateLimit: &engine.RateLimit{RPS: 10}}
+	if err := engine.ValidateAction(adapter, rl); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if _, err := adapter.Ban(rl); err != nil {
+		t.Fatal(err)
+	}
+	// 能力来自插件的 Health 应答
+	if err := engine.ValidateAction(adapter, engine.BanRequest{IP: "192.0.2.3", Action: engine.ActionTarpit}); err == nil {
+		t.Error("tarpit should be rejected by capabilities")
+	}
+
+	entries, err := client.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].GetIp() != "192.0.2.1/32" || entries[1].GetAction() != engine.ActionRateLimit {
+		t.Errorf("entries = %v", entries)
+	}
+
+	if _, err := adapter.UnBan(engine.BanRequest{IP: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adapter.Ban(engine.BanRequest{IP: "bogus", Permanent: true}); err == nil || !strings.Contains(err.Error(), "InvalidArgument") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestClientRequiresToken(t *testing.T) {
+	addr := startReference(t, "s3cret")
+	client, err := Dial(Config{Name: "reference", Address: addr}, Credentials{Token: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.Ban(engine.BanRequest{IP: "192.0.2.1", Permanent: true}); err == nil || !strings.Contains(err.Error(), "Unauthenticated") {
+		t.Errorf("err = %v", err)
+	}
+	if err := client.Health(context.Background()); err == nil {
+		t.Error("health check should fail without a valid token")
+	}
+	// 插件不可达或拒绝时按只支持 block 处理
+	if caps := client.Capabilities(); len(caps.Actions) != 1 || caps.Actions[0] != engine.ActionBlock {
+		t.Errorf("caps = %+v", caps)
+	}
+}
@@ -0,0 +1,211 @@
+// Package conformance 插件协议的一致性测试，任何插件都应通过。
+// 测试只使用文档保留地址（192.0.2.0/24、2001:db8::/32），结束时解封所有用到的地址。
+//
+// 在插件自己的测试中调用 Run，或者在本仓库中对已部署的插件运行：
+//
+//	IPBLOCK_PLUGIN_ADDR=127.0.0.1:9000 go test ./internal/plugin/conformance/
+package conformance
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+	pluginv1 "github/Beatrueman/ipblock-operator/internal/plugin/pluginv1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var knownActions = []string{engine.ActionBlock, engine.ActionRateLimit, engine.ActionTarpit}
+
+// Run 对插件运行全部一致性测试
+func Run(t *testing.T, client pluginv1.EnginePluginClient) {
+	s := &suite{client: client}
+	t.Cleanup(s.cleanup)
+
+	var caps *pluginv1.Capabilities
+	t.Run("Health", func(t *testing.T) {
+		resp, err := client.Health(s.ctx(t), &pluginv1.HealthRequest{})
+		if err != nil {
+			t.Fatalf("Health: %v", err)
+		}
+		if !resp.GetReady() {
+			t.Fatalf("plugin not ready: %s", resp.GetMessage())
+		}
+		caps = resp.GetCapabilities()
+		for _, a := range caps.GetActions() {
+			if !contains(knownActions, a) {
+				t.Errorf("unknown action '%s' in capabilities", a)
+			}
+		}
+	})
+
+	t.Run("BanPermanent", func(t *testing.T) {
+		s.ban(t, &pluginv1.BanRequest{Ip: "192.0.2.10", Permanent: true})
+		e := s.find(t, "192.0.2.10")
+		if e == nil {
+			t.Fatal("banned address missing from List")
+		}
+		if !e.GetPermanent() || e.GetExpiresAt() != 0 {
+			t.Errorf("permanent entry = %v", e)
+		}
+	})
+
+	t.Run("BanTemporary", func(t *testing.T) {
+		start := time.Now().Unix()
+		s.ban(t, &pluginv1.BanRequest{Ip: "192.0.2.11", DurationSeconds: 600})
+		e := s.find(t, "192.0.2.11")
+		if e == nil {
+			t.Fatal("banned address missing from List")
+		}
+		if e.GetPermanent() || e.GetExpiresAt() < start+590 || e.GetExpiresAt() > time.Now().Unix()+610 {
+			t.Errorf("temporary entry = %v, want expiry about 600s from now", e)
+		}
+	})
+
+	t.Run("BanIsIdempotent", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			s.ban(t, &pluginv1.BanRequest{Ip: "192.0.2.12", Permanent: true})
+		}
+		count := 0
+		for _, e := range s.list(t) {
+			if samePrefix(e.GetIp(), "192.0.2.12") {
+				count++
+			}
+		}
+		if count != 1 {
+			t.Errorf("address listed %d times", count)
+		}
+	})
+
+	t.Run("BanUpdatesExpiry", func(t *testing.T) {
+		s.ban(t, &pluginv1.BanRequest{Ip: "192.0.2.13", DurationSeconds: 600})
+		s.ban(t, &pluginv1.BanRequest{Ip: "192.0.2.13", Permanent: true})
+		if e := s.find(t, "192.0.2.13"); e == nil || !e.GetPermanent() {
+			t.Errorf("entry after re-ban = %v, want permanent", e)
+		}
+	})
+
+	t.Run("CIDRAndIPv6", func(t *testing.T) {
+		for _, ip := range []string{"192.0.2.128/25", "2001:db8::1", "2001:db8:1::/48"} {
+			s.ban(t, &pluginv1.BanRequest{Ip: ip, Permanent: true})
+			if s.find(t, ip) == nil {
+				t.Errorf("%s missing from List", ip)
+			}
+		}
+	})
+
+	t.Run("UnBan", func(t *testing.T) {
+		s.ban(t, &pluginv1.BanRequest{Ip: "192.0.2.14", Permanent: true})
+		for i := 0; i < 2; i++ {
+			// 第二次解封时地址已不存在，也应成功
+			if _, err := client.UnBan(s.ctx(t), &pluginv1.BanRequest{Ip: "192.0.2.14", RequestId: engine.NewRequestID()}); err != nil {
+				t.Fatalf("UnBan #%d: %v", i+1, err)
+			}
+		}
+		if s.find(t, "192.0.2.14") != nil {
+			t.Error("address still listed after UnBan")
+		}
+	})
+
+	t.Run("RejectsInvalidIP", func(t *testing.T) {
+		_, err := client.Ban(s.ctx(t), &pluginv1.BanRequest{Ip: "not-an-ip", Permanent: true, RequestId: engine.NewRequestID()})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Ban(not-an-ip) = %v, want InvalidArgument", err)
+		}
+	})
+
+	t.Run("RejectsUnsupportedAction", func(t *testing.T) {
+		var unsupported string
+		for _, a := range knownActions {
+			if !contains(caps.GetActions(), a) {
+				unsupported = a
+				break
+			}
+		}
+		if unsupported == "" {
+			t.Skip("plugin supports every action")
+		}
+		_, err := client.Ban(s.ctx(t), &pluginv1.BanRequest{Ip: "192.0.2.15", Permanent: true, Action: unsupported, RequestId: engine.NewRequestID()})
+		s.used = append(s.used, "192.0.2.15")
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Ban with action %s = %v, want InvalidArgument", unsupported, err)
+		}
+	})
+}
+
+type suite struct {
+	client pluginv1.EnginePluginClient
+	used   []string
+}
+
+func (s *suite) ctx(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func (s *suite) ban(t *testing.T, req *pluginv1.BanRequest) {
+	t.Helper()
+	req.RequestId = engine.NewRequestID()
+	s.used = append(s.used, req.GetIp())
+	if _, err := s.client.Ban(s.ctx(t), req); err != nil {
+		t.Fatalf("Ban(%s): %v", req.GetIp(), err)
+	}
+}
+
+func (s *suite) list(t *testing.T) []*pluginv1.Entry {
+	t.Helper()
+	resp, err := s.client.List(s.ctx(t), &pluginv1.ListRequest{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return resp.GetEntries()
+}
+
+func (s *suite) find(t *testing.T, ip string) *pluginv1.Entry {
+	t.Helper()
+	for _, e := range s.list(t) {
+		if samePrefix(e.GetIp(), ip) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *suite) cleanup() {
+	for _, ip := range s.used {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, _ = s.client.UnBan(ctx, &pluginv1.BanRequest{Ip: ip, RequestId: engine.NewRequestID()})
+		cancel()
+	}
+}
+
+// 插件可以把单个 IP 列为 /32、/128，也可以不带前缀长度
+func samePrefix(a, b string) bool {
+	pa, errA := parsePrefix(a)
+	pb, errB := parsePrefix(b)
+	return errA == nil && errB == nil && pa == pb
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package conformance
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"github/Beatrueman/ipblock-operator/internal/plugin"
+	pluginv1 "github/Beatrueman/ipblock-operator/internal/plugin/pluginv1"
+	"github/Beatrueman/ipblock-operator/internal/plugin/reference"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestReferencePlugin(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := plugin.NewServer(reference.NewServer(), "")
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	Run(t, pluginv1.NewEnginePluginClient(conn))
+}
+
+// 对已部署的插件运行：IPBLOCK_PLUGIN_ADDR=host:port go test ./internal/plugin/conformance/
+func TestDeployedPlugin(t *testing.T) {
+	addr := os.Getenv("IPBLOCK_PLUGIN_ADDR")
+	if addr == "" {
+		t.Skip("set IPBLOCK_PLUGIN_ADDR to run against a deployed plugin")
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	Run(t, pluginv1.NewEnginePluginClient(conn))
+}
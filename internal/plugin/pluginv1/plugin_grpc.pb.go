@@ -0,0 +1,243 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: internal/plugin/pluginv1/plugin.proto
+
+package pluginv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	EnginePlugin_Ban_FullMethodName    = "/ipblock.plugin.v1.EnginePlugin/Ban"
+	EnginePlugin_UnBan_FullMethodName  = "/ipblock.plugin.v1.EnginePlugin/UnBan"
+	EnginePlugin_List_FullMethodName   = "/ipblock.plugin.v1.EnginePlugin/List"
+	EnginePlugin_Health_FullMethodName = "/ipblock.plugin.v1.EnginePlugin/Health"
+)
+
+// EnginePluginClient is the client API for EnginePlugin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EnginePluginClient interface {
+	// Ban 封禁，对已封禁的地址重复调用时更新到期时间，不应报错
+	Ban(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*BanResponse, error)
+	// UnBan 解封，地址未封禁时直接返回成功
+	UnBan(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*BanResponse, error)
+	// List 返回插件当前执行中的封禁
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Health 返回插件是否可用以及支持的处置动作
+	Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
+}
+
+type enginePluginClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEnginePluginClient(cc grpc.ClientConnInterface) EnginePluginClient {
+	return &enginePluginClient{cc}
+}
+
+func (c *enginePluginClient) Ban(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*BanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BanResponse)
+	err := c.cc.Invoke(ctx, EnginePlugin_Ban_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *enginePluginClient) UnBan(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*BanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BanResponse)
+	err := c.cc.Invoke(ctx, EnginePlugin_UnBan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *enginePluginClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, EnginePlugin_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *enginePluginClient) Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HealthResponse)
+	err := c.cc.Invoke(ctx, EnginePlugin_Health_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EnginePluginServer is the server API for EnginePlugin service.
+// All implementations must embed UnimplementedEnginePluginServer
+// for forward compatibility.
+type EnginePluginServer interface {
+	// Ban 封禁，对已封禁的地址重复调用时更新到期时间，不应报错
+	Ban(context.Context, *BanRequest) (*BanResponse, error)
+	// UnBan 解封，地址未封禁时直接返回成功
+	UnBan(context.Context, *BanRequest) (*BanResponse, error)
+	// List 返回插件当前执行中的封禁
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Health 返回插件是否可用以及支持的处置动作
+	Health(context.Context, *HealthRequest) (*HealthResponse, error)
+	mustEmbedUnimplementedEnginePluginServer()
+}
+
+// UnimplementedEnginePluginServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEnginePluginServer struct{}
+
+func (UnimplementedEnginePluginServer) Ban(context.Context, *BanRequest) (*BanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ban not implemented")
+}
+func (UnimplementedEnginePluginServer) UnBan(context.Context, *BanRequest) (*BanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnBan not implemented")
+}
+func (UnimplementedEnginePluginServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedEnginePluginServer) Health(context.Context, *HealthRequest) (*HealthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Health not implemented")
+}
+func (UnimplementedEnginePluginServer) mustEmbedUnimplementedEnginePluginServer() {}
+func (UnimplementedEnginePluginServer) testEmbeddedByValue()                      {}
+
+// UnsafeEnginePluginServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EnginePluginServer will
+// result in compilation errors.
+type UnsafeEnginePluginServer interface {
+	mustEmbedUnimplementedEnginePluginServer()
+}
+
+func RegisterEnginePluginServer(s grpc.ServiceRegistrar, srv EnginePluginServer) {
+	// If the following call pancis, it indicates UnimplementedEnginePluginServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EnginePlugin_ServiceDesc, srv)
+}
+
+func _EnginePlugin_Ban_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnginePluginServer).Ban(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EnginePlugin_Ban_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnginePluginServer).Ban(ctx, req.(*BanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EnginePlugin_UnBan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnginePluginServer).UnBan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EnginePlugin_UnBan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnginePluginServer).UnBan(ctx, req.(*BanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EnginePlugin_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnginePluginServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EnginePlugin_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnginePluginServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EnginePlugin_Health_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EnginePluginServer).Health(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EnginePlugin_Health_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EnginePluginServer).Health(ctx, req.(*HealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// EnginePlugin_ServiceDesc is the grpc.ServiceDesc for EnginePlugin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EnginePlugin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ipblock.plugin.v1.EnginePlugin",
+	HandlerType: (*EnginePluginServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Ban",
+			Handler:    _EnginePlugin_Ban_Handler,
+		},
+		{
+			MethodName: "UnBan",
+			Handler:    _EnginePlugin_UnBan_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _EnginePlugin_List_Handler,
+		},
+		{
+			MethodName: "Health",
+			Handler:    _EnginePlugin_Health_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "internal/plugin/pluginv1/plugin.proto",
+}
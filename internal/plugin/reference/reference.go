@@ -0,0 +1,131 @@
+// Package reference 参考插件：在内存中记录封禁，不执行实际拦截。
+// 演示插件协议的约定（幂等、到期、参数校验、声明处置动作），也用于运行一致性测试
+package reference
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+	pluginv1 "github/Beatrueman/ipblock-operator/internal/plugin/pluginv1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type entry struct {
+	permanent bool
+	expiresAt time.Time
+	action    string
+}
+
+// Server 参考插件，支持 block 与按 pps / rps 限速
+type Server struct {
+	pluginv1.UnimplementedEnginePluginServer
+
+	mu      sync.Mutex
+	entries map[netip.Prefix]entry
+	now     func() time.Time
+}
+
+func NewServer() *Server {
+	return &Server{entries: make(map[netip.Prefix]entry), now: time.Now}
+}
+
+var capabilities = &pluginv1.Capabilities{
+	Actions:        []string{engine.ActionBlock, engine.ActionRateLimit},
+	RateLimitUnits: []string{engine.RateLimitPPS, engine.RateLimitRPS},
+}
+
+func (s *Server) Ban(ctx context.Context, req *pluginv1.BanRequest) (*pluginv1.BanResponse, error) {
+	p, err := parsePrefix(req.GetIp())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !req.GetPermanent() && req.GetDurationSeconds() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "duration_seconds must be positive for temporary bans")
+	}
+	action := req.GetAction()
+	if action == "" {
+		action = capabilities.Actions[0]
+	}
+	if !contains(capabilities.Actions, action) {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported action '%s'", action)
+	}
+
+	e := entry{permanent: req.GetPermanent(), action: action}
+	if !e.permanent {
+		e.expiresAt = s.now().Add(time.Duration(req.GetDurationSeconds()) * time.Second)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, existed := s.entries[p]
+	s.entries[p] = e
+	if existed {
+		return &pluginv1.BanResponse{Message: fmt.Sprintf("%s updated", p)}, nil
+	}
+	return &pluginv1.BanResponse{Message: fmt.Sprintf("%s banned", p)}, nil
+}
+
+func (s *Server) UnBan(ctx context.Context, req *pluginv1.BanRequest) (*pluginv1.BanResponse, error) {
+	p, err := parsePrefix(req.GetIp())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[p]; !ok {
+		return &pluginv1.BanResponse{Message: fmt.Sprintf("%s not banned", p)}, nil
+	}
+	delete(s.entries, p)
+	return &pluginv1.BanResponse{Message: fmt.Sprintf("%s unbanned", p)}, nil
+}
+
+// List 只返回未到期的条目，并顺带清理已到期的条目
+func (s *Server) List(ctx context.Context, req *pluginv1.ListRequest) (*pluginv1.ListResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	resp := &pluginv1.ListResponse{}
+	for p, e := range s.entries {
+		if !e.permanent && !e.expiresAt.After(now) {
+			delete(s.entries, p)
+			continue
+		}
+		pe := &pluginv1.Entry{Ip: p.String(), Permanent: e.permanent, Action: e.action}
+		if !e.permanent {
+			pe.ExpiresAt = e.expiresAt.Unix()
+		}
+		resp.Entries = append(resp.Entries, pe)
+	}
+	sort.Slice(resp.Entries, func(i, j int) bool { return resp.Entries[i].Ip < resp.Entries[j].Ip })
+	return resp, nil
+}
+
+func (s *Server) Health(ctx context.Context, req *pluginv1.HealthRequest) (*pluginv1.HealthResponse, error) {
+	return &pluginv1.HealthResponse{Ready: true, Capabilities: capabilities}, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP or CIDR '%s'", s)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"context"
+	"crypto/subtle"
+
+	pluginv1 "github/Beatrueman/ipblock-operator/internal/plugin/pluginv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NewServer 创建注册了插件服务的 gRPC 服务端，token 不为空时要求 authorization: Bearer <token>
+func NewServer(impl pluginv1.EnginePluginServer, token string, opts ...grpc.ServerOption) *grpc.Server {
+	if token != "" {
+		opts = append(opts, grpc.UnaryInterceptor(tokenInterceptor(token)))
+	}
+	s := grpc.NewServer(opts...)
+	pluginv1.RegisterEnginePluginServer(s, impl)
+	return s
+}
+
+func tokenInterceptor(token string) grpc.UnaryServerInterceptor {
+	want := []byte("Bearer " + token)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var got string
+		if v := md.Get("authorization"); len(v) > 0 {
+			got = v[0]
+		}
+		if subtle.ConstantTimeCompare([]byte(got), want) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "invalid or missing token")
+		}
+		return handler(ctx, req)
+	}
+}
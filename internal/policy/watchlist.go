@@ -34,7 +34,12 @@ func NewWhitelist(ipList []string) *Whitelist {
 	return w
 }
 
+// IsWhitelisted ip 也可以是 CIDR，与任一白名单项重叠即视为在白名单中，
+// 避免封禁网段时连带封禁白名单中的地址
 func (w *Whitelist) IsWhitelisted(ip string) bool {
+	if _, ipNet, err := net.ParseCIDR(ip); err == nil {
+		return w.overlaps(ipNet)
+	}
 	parsed := net.ParseIP(ip)
 	if parsed == nil {
 		return false
@@ -52,6 +57,20 @@ func (w *Whitelist) IsWhitelisted(ip string) bool {
 	return false
 }
 
+func (w *Whitelist) overlaps(n *net.IPNet) bool {
+	for _, ipnet := range w.ipNets {
+		if ipnet.Contains(n.IP) || n.Contains(ipnet.IP) {
+			return true
+		}
+	}
+	for _, i := range w.ips {
+		if n.Contains(i) {
+			return true
+		}
+	}
+	return false
+}
+
 // 打印所有白名单内容
 func (w *Whitelist) StringSlice() []string {
 	list := make([]string, 0)
@@ -0,0 +1,211 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const defaultAlertWindow = time.Minute
+
+// Falco 的告警级别，与 syslog 的 severity 一致，下标越小越严重
+var severityLevels = []string{"emergency", "alert", "critical", "error", "warning", "notice", "informational", "debug"}
+
+// syslog 常用的缩写
+var severityAliases = map[string]string{"emerg": "emergency", "crit": "critical", "err": "error", "warn": "warning", "info": "informational"}
+
+// AlertRule IDS / 运行时安全告警的封禁规则，按顺序匹配，第一条命中的规则决定是否封禁以及封禁多久
+type AlertRule struct {
+	Name         string   `json:"name"`
+	Signature    string   `json:"signature,omitempty"`    // 匹配签名的正则：Suricata 为 alert.signature，Falco 为 rule
+	SignatureIDs []int64  `json:"signatureIds,omitempty"` // Suricata 签名 ID（sid），为空不限制
+	Categories   []string `json:"categories,omitempty"`   // Suricata alert.category，为空不限制
+	MaxSeverity  int      `json:"maxSeverity,omitempty"`  // Suricata：severity 不大于该值（1 最严重），0 不限制
+	MinPriority  string   `json:"minPriority,omitempty"`  // Falco：priority 不低于该级别，如 warning
+	Ignore       bool     `json:"ignore,omitempty"`       // 命中后不封禁，用于排除误报较多的签名
+	Threshold    int      `json:"threshold,omitempty"`    // 窗口内同一地址命中次数，默认 1 即首次告警就封禁
+	Window       string   `json:"window,omitempty"`       // 默认 1m
+	Duration     string   `json:"duration,omitempty"`     // 封禁时长，为空表示永久
+	Tags         []string `json:"tags,omitempty"`         // 附加到 IPBlock 的标签
+}
+
+// securityAlert Suricata 和 Falco 告警统一后的结构
+type securityAlert struct {
+	IP          string
+	Signature   string
+	SignatureID int64  // 仅 Suricata
+	Category    string // 仅 Suricata
+	Severity    int    // 仅 Suricata
+	Priority    string // 仅 Falco，小写
+	Tags        []string
+}
+
+type compiledAlertRule struct {
+	AlertRule
+	signature   *regexp.Regexp
+	sids        map[int64]bool
+	categories  map[string]bool
+	minPriority int // severityLevels 的下标，-1 不限制
+	counts      *slidingWindow
+}
+
+// compileAlertRules 编译规则，name 为触发器名称，用于错误信息
+func compileAlertRules(name string, rules []AlertRule) ([]compiledAlertRule, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%s trigger requires at least one rule", name)
+	}
+	compiled := make([]compiledAlertRule, 0, len(rules))
+	for i, r := range rules {
+		rule, err := compileAlertRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s rule #%d: %w", name, i+1, err)
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+func compileAlertRule(r AlertRule) (compiledAlertRule, error) {
+	rule := compiledAlertRule{AlertRule: r, minPriority: -1}
+	if r.Name == "" {
+		return rule, fmt.Errorf("name is required")
+	}
+	var err error
+	if r.Signature != "" {
+		if rule.signature, err = regexp.Compile(r.Signature); err != nil {
+			return rule, fmt.Errorf("invalid signature of rule '%s': %w", r.Name, err)
+		}
+	}
+	if len(r.SignatureIDs) > 0 {
+		rule.sids = make(map[int64]bool, len(r.SignatureIDs))
+		for _, sid := range r.SignatureIDs {
+			rule.sids[sid] = true
+		}
+	}
+	if len(r.Categories) > 0 {
+		rule.categories = make(map[string]bool, len(r.Categories))
+		for _, c := range r.Categories {
+			rule.categories[strings.ToLower(c)] = true
+		}
+	}
+	if r.MaxSeverity < 0 {
+		return rule, fmt.Errorf("maxSeverity of rule '%s' must not be negative", r.Name)
+	}
+	if r.MinPriority != "" {
+		if rule.minPriority = severityLevel(r.MinPriority); rule.minPriority < 0 {
+			return rule, fmt.Errorf("unknown minPriority '%s' of rule '%s'", r.MinPriority, r.Name)
+		}
+	}
+	if r.Threshold < 0 {
+		return rule, fmt.Errorf("threshold of rule '%s' must not be negative", r.Name)
+	}
+	window, err := parseDurationOr(r.Window, defaultAlertWindow)
+	if err != nil || window <= 0 {
+		return rule, fmt.Errorf("invalid window '%s' of rule '%s'", r.Window, r.Name)
+	}
+	if r.Duration != "" {
+		if _, err := time.ParseDuration(r.Duration); err != nil {
+			return rule, fmt.Errorf("invalid duration of rule '%s': %w", r.Name, err)
+		}
+	}
+	rule.counts = newSlidingWindow(window, r.Threshold)
+	return rule, nil
+}
+
+// 级别名称转换为数值，未知级别返回 -1
+func severityLevel(p string) int {
+	p = strings.ToLower(p)
+	if alias, ok := severityAliases[p]; ok {
+		p = alias
+	}
+	for i, name := range severityLevels {
+		if name == p {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *compiledAlertRule) matches(a securityAlert) bool {
+	if r.signature != nil && !r.signature.MatchString(a.Signature) {
+		return false
+	}
+	if r.sids != nil && !r.sids[a.SignatureID] {
+		return false
+	}
+	if r.categories != nil && !r.categories[strings.ToLower(a.Category)] {
+		return false
+	}
+	if r.MaxSeverity > 0 && (a.Severity <= 0 || a.Severity > r.MaxSeverity) {
+		return false
+	}
+	if r.minPriority >= 0 {
+		p := severityLevel(a.Priority)
+		if p < 0 || p > r.minPriority {
+			return false
+		}
+	}
+	return true
+}
+
+// alertRules 按规则处理告警，Suricata 和 Falco 触发器共用，source 为触发器名称
+type alertRules struct {
+	pipeline *Pipeline
+	source   string
+	rules    []compiledAlertRule
+	now      func() time.Time
+}
+
+// handle 找到第一条命中的规则，达到阈值时提交封禁；告警签名写入 Reason 和 Tags
+func (h *alertRules) handle(ctx context.Context, a securityAlert, reason string) bool {
+	ip, ok := offenderIP(a.IP)
+	if !ok {
+		return false
+	}
+	for i := range h.rules {
+		rule := &h.rules[i]
+		if !rule.matches(a) {
+			continue
+		}
+		if rule.Ignore {
+			return false
+		}
+		if _, reached := rule.counts.Add(ip, h.now()); !reached {
+			return false
+		}
+		h.pipeline.Submit(ctx, Offender{
+			IP:       ip,
+			Duration: rule.Duration,
+			Reason:   reason,
+			Source:   h.source,
+			Tags:     alertTags(rule.Tags, a),
+		})
+		return true
+	}
+	return false
+}
+
+// 规则标签、签名、sid 以及告警自带的标签，去重并保持顺序
+func alertTags(ruleTags []string, a securityAlert) []string {
+	tags := make([]string, 0, len(ruleTags)+len(a.Tags)+2)
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		if tag = strings.TrimSpace(tag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	for _, tag := range ruleTags {
+		add(tag)
+	}
+	add(a.Signature)
+	if a.SignatureID > 0 {
+		add(fmt.Sprintf("sid:%d", a.SignatureID))
+	}
+	for _, tag := range a.Tags {
+		add(tag)
+	}
+	return tags
+}
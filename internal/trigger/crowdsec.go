@@ -0,0 +1,308 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	defaultCrowdSecInterval = 10 * time.Second
+	defaultCrowdSecTimeout  = 30 * time.Second
+	crowdSecSource          = "crowdsec"
+	crowdSecUserAgent       = "ipblock-operator-bouncer"
+)
+
+// CrowdSecConfig crowdsec 触发器配置，作为 CrowdSec 的 bouncer 拉取 LAPI 的决策
+type CrowdSecConfig struct {
+	URL    string `json:"url"`              // LAPI 地址，如 http://crowdsec-service.crowdsec:8080
+	APIKey string `json:"apiKey,omitempty"` // bouncer 的 API Key（cscli bouncers add），建议使用 apiKeySecret
+	// API Key 所在 Secret，由 Operator 读取后填入 APIKey
+	APIKeySecret *engine.SecretKeyRef `json:"apiKeySecret,omitempty"`
+	Interval     string               `json:"interval,omitempty"` // 拉取间隔，默认 10s
+	Timeout      string               `json:"timeout,omitempty"`  // 单次请求超时，默认 30s
+	// 只处理这些类型的决策，默认只处理 ban
+	Types []string `json:"types,omitempty"`
+	// 以下过滤条件原样传给 LAPI
+	Scopes              []string `json:"scopes,omitempty"`              // 默认 ip,range
+	Origins             []string `json:"origins,omitempty"`             // 如 crowdsec,cscli,CAPI
+	ScenariosContaining []string `json:"scenariosContaining,omitempty"` // 场景名包含任一关键字
+	ScenariosNotContain []string `json:"scenariosNotContaining,omitempty"`
+}
+
+// CrowdSecDecision LAPI 返回的决策
+type CrowdSecDecision struct {
+	ID       int64  `json:"id"`
+	Origin   string `json:"origin"`
+	Type     string `json:"type"`
+	Scope    string `json:"scope"`
+	Value    string `json:"value"`
+	Duration string `json:"duration"`
+	Scenario string `json:"scenario"`
+}
+
+// CrowdSecTrigger 轮询 /v1/decisions/stream：新增的决策创建 IPBlock，删除的决策解封。
+// 首次拉取（以及触发器重建后）使用 startup=true 获取全部生效中的决策
+type CrowdSecTrigger struct {
+	Pipeline   *Pipeline
+	Config     CrowdSecConfig
+	HTTPClient *http.Client // 为空时使用 http.DefaultClient
+
+	interval time.Duration
+	timeout  time.Duration
+	types    map[string]bool
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	started bool // 已完成 startup 拉取
+}
+
+// NewCrowdSecTrigger 校验配置
+func NewCrowdSecTrigger(cfg CrowdSecConfig, pipeline *Pipeline) (*CrowdSecTrigger, error) {
+	if u, err := url.Parse(cfg.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid crowdsec url '%s'", cfg.URL)
+	}
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("crowdsec trigger requires apiKey or apiKeySecret")
+	}
+	if len(cfg.Types) == 0 {
+		cfg.Types = []string{"ban"}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"ip", "range"}
+	}
+
+	t := &CrowdSecTrigger{Pipeline: pipeline, Config: cfg, types: map[string]bool{}}
+	for _, typ := range cfg.Types {
+		t.types[strings.ToLower(typ)] = true
+	}
+	var err error
+	if t.interval, err = parseDurationOr(cfg.Interval, defaultCrowdSecInterval); err != nil {
+		return nil, fmt.Errorf("invalid crowdsec interval: %w", err)
+	}
+	if t.timeout, err = parseDurationOr(cfg.Timeout, defaultCrowdSecTimeout); err != nil {
+		return nil, fmt.Errorf("invalid crowdsec timeout: %w", err)
+	}
+	return t, nil
+}
+
+func (t *CrowdSecTrigger) Name() string {
+	return crowdSecSource
+}
+
+// Start 立即拉取一次，之后按间隔拉取，直到 Stop 或 ctx 结束
+func (t *CrowdSecTrigger) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx)
+
+	t.mu.Lock()
+	if t.cancel != nil {
+		t.cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t.cancel = cancel
+	t.started = false
+	t.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			if err := t.Poll(ctx); err != nil {
+				logger.Error(err, "[crowdsec] Poll decisions failed")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	logger.Info("[crowdsec] Bouncer started", "url", t.Config.URL, "interval", t.interval)
+	return nil
+}
+
+func (t *CrowdSecTrigger) Stop(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		logf.FromContext(ctx).Info("[crowdsec] Stopping bouncer")
+		t.cancel()
+		t.cancel = nil
+	}
+	return nil
+}
+
+// Poll 拉取一次决策流。同一地址的决策被替换时（同一批中既被删除又有新决策），
+// 不解封该地址，保持现有封禁：先解封再提交会因为 IPBlock 仍处于封禁状态而跳过新决策。
+// startup 拉取返回全部生效中的决策，停机或重建触发器期间删除的决策不会出现在 deleted 中，
+// 因此按全量比较，解封不在其中的 crowdsec IPBlock
+func (t *CrowdSecTrigger) Poll(ctx context.Context) error {
+	t.mu.Lock()
+	startup := !t.started
+	t.mu.Unlock()
+
+	stream, err := t.stream(ctx, startup)
+	if err != nil {
+		return err
+	}
+	logger := logf.FromContext(ctx)
+	offenders := make([]Offender, 0, len(stream.New))
+	// 仍生效的决策涉及的地址，时长无法解析的决策也算在内，不据此解封
+	current := make(map[string]bool, len(stream.New))
+	for _, d := range stream.New {
+		ip, ok := t.decisionIP(d)
+		if !ok {
+			continue
+		}
+		duration, err := decisionDuration(d.Duration)
+		if err != nil {
+			logger.Info("[crowdsec] Skip decision with invalid duration", "id", d.ID, "ip", ip, "duration", d.Duration)
+			current[ip] = true
+			continue
+		}
+		if duration == "" {
+			continue // 已过期
+		}
+		current[ip] = true
+		reason := d.Scenario
+		if reason == "" {
+			reason = "crowdsec decision from " + d.Origin
+		}
+		offenders = append(offenders, Offender{
+			IP:       ip,
+			Duration: duration,
+			Reason:   reason,
+			Source:   crowdSecSource,
+		})
+	}
+	replaced := make(map[string]bool, len(offenders))
+	for _, o := range offenders {
+		replaced[o.IP] = true
+	}
+
+	var errs []error
+	if startup {
+		existing, err := t.Pipeline.listBySource(ctx, crowdSecSource)
+		if err != nil {
+			return err
+		}
+		for ip := range existing {
+			if current[ip] {
+				continue
+			}
+			if err := t.Pipeline.Unblock(ctx, ip, crowdSecSource); err != nil {
+				errs = append(errs, fmt.Errorf("unblock %s: %w", ip, err))
+			}
+		}
+	}
+	// 全量比较完成后才改为增量拉取，列出 IPBlock 失败时下次仍使用 startup 拉取
+	t.mu.Lock()
+	t.started = true
+	t.mu.Unlock()
+
+	for _, d := range stream.Deleted {
+		ip, ok := t.decisionIP(d)
+		if !ok || replaced[ip] {
+			continue
+		}
+		if err := t.Pipeline.Unblock(ctx, ip, crowdSecSource); err != nil {
+			errs = append(errs, fmt.Errorf("unblock %s: %w", ip, err))
+		}
+	}
+	for _, o := range offenders {
+		t.Pipeline.Submit(ctx, o)
+	}
+	if len(stream.New)+len(stream.Deleted) > 0 {
+		logger.Info("[crowdsec] Decisions processed", "new", len(stream.New), "deleted", len(stream.Deleted), "startup", startup)
+	}
+	return errors.Join(errs...)
+}
+
+// 只处理配置的决策类型，以及 Ip、Range 范围的决策
+func (t *CrowdSecTrigger) decisionIP(d CrowdSecDecision) (string, bool) {
+	if !t.types[strings.ToLower(d.Type)] {
+		return "", false
+	}
+	switch strings.ToLower(d.Scope) {
+	case "ip", "range":
+	default:
+		return "", false
+	}
+	return offenderIP(d.Value)
+}
+
+// LAPI 返回的剩余时长精确到纳秒，如 3h59m59.5s，取整到秒；已过期（为负）时返回空
+func decisionDuration(s string) (string, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return "", err
+	}
+	d = d.Round(time.Second)
+	if d <= 0 {
+		return "", nil
+	}
+	return d.String(), nil
+}
+
+type crowdSecStream struct {
+	New     []CrowdSecDecision `json:"new"`
+	Deleted []CrowdSecDecision `json:"deleted"`
+}
+
+func (t *CrowdSecTrigger) stream(ctx context.Context, startup bool) (*crowdSecStream, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	query := url.Values{"startup": {fmt.Sprint(startup)}}
+	query.Set("scopes", strings.Join(t.Config.Scopes, ","))
+	if len(t.Config.Origins) > 0 {
+		query.Set("origins", strings.Join(t.Config.Origins, ","))
+	}
+	if len(t.Config.ScenariosContaining) > 0 {
+		query.Set("scenarios_containing", strings.Join(t.Config.ScenariosContaining, ","))
+	}
+	if len(t.Config.ScenariosNotContain) > 0 {
+		query.Set("scenarios_not_containing", strings.Join(t.Config.ScenariosNotContain, ","))
+	}
+	u := strings.TrimRight(t.Config.URL, "/") + "/v1/decisions/stream?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Api-Key", t.Config.APIKey)
+	req.Header.Set("User-Agent", crowdSecUserAgent)
+	httpClient := t.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("crowdsec returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var stream crowdSecStream
+	if err := json.Unmarshal(body, &stream); err != nil {
+		return nil, fmt.Errorf("decode decisions failed: %w", err)
+	}
+	return &stream, nil
+}
@@ -0,0 +1,156 @@
+package trigger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	opsv1 "github/Beatrueman/ipblock-operator/api/v1"
+	"github/Beatrueman/ipblock-operator/internal/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCrowdSecTriggerStream(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		startups []string
+		bodies   = []string{
+			`{"new":[
+				{"id":1,"origin":"crowdsec","type":"ban","scope":"Ip","value":"192.0.2.50","duration":"3h59m59.6s","scenario":"crowdsecurity/ssh-bf"},
+				{"id":2,"origin":"CAPI","type":"ban","scope":"Range","value":"198.51.100.0/24","duration":"167h","scenario":"crowdsecurity/http-probing"},
+				{"id":3,"origin":"crowdsec","type":"captcha","scope":"Ip","value":"192.0.2.51","duration":"1h","scenario":"crowdsecurity/http-crawl"},
+				{"id":4,"origin":"crowdsec","type":"ban","scope":"Country","value":"XX","duration":"1h","scenario":"geo"}
+			],"deleted":null}`,
+			`{"new":null,"deleted":[{"id":1,"origin":"crowdsec","type":"ban","scope":"Ip","value":"192.0.2.50","duration":"-1s","scenario":"crowdsecurity/ssh-bf"}]}`,
+			// CAPI 刷新黑名单：同一地址的旧决策删除、新决策下发在同一批
+			`{"new":[{"id":5,"origin":"CAPI","type":"ban","scope":"Range","value":"198.51.100.0/24","duration":"167h","scenario":"crowdsecurity/http-probing"}],
+			"deleted":[{"id":2,"origin":"CAPI","type":"ban","scope":"Range","value":"198.51.100.0/24","duration":"-1s","scenario":"crowdsecurity/http-probing"}]}`,
+		}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/decisions/stream" || r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, `{"message":"access forbidden"}`, http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		startups = append(startups, r.URL.Query().Get("startup"))
+		body := `{"new":null,"deleted":null}`
+		if len(startups) <= len(bodies) {
+			body = bodies[len(startups)-1]
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	p := newTestPipeline(t)
+	trig, err := NewCrowdSecTrigger(CrowdSecConfig{URL: srv.URL, APIKey: "secret"}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := trig.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ssh, ok := getIPBlock(t, p, "192.0.2.50")
+	if !ok || ssh.Spec.Source != "crowdsec" || ssh.Spec.Reason != "crowdsecurity/ssh-bf" || ssh.Spec.Duration != "4h0m0s" {
+		t.Fatalf("unexpected ip decision %+v", ssh)
+	}
+	rng, ok := getIPBlock(t, p, "198.51.100.0/24")
+	if !ok || rng.Spec.Duration != "167h0m0s" {
+		t.Fatalf("unexpected range decision %+v", rng)
+	}
+	if _, ok := getIPBlock(t, p, "192.0.2.51"); ok {
+		t.Fatal("captcha decisions should be ignored by default")
+	}
+
+	// 封禁生效后收到删除的决策
+	ssh.Status.Phase = "active"
+	if err := p.Client.Status().Update(ctx, ssh); err != nil {
+		t.Fatal(err)
+	}
+	if err := trig.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ssh, _ = getIPBlock(t, p, "192.0.2.50")
+	if !ssh.Spec.Unblock {
+		t.Fatal("deleted decision should unblock the IPBlock")
+	}
+
+	// 被替换的决策不解封
+	rng.Status.Phase = "active"
+	if err := p.Client.Status().Update(ctx, rng); err != nil {
+		t.Fatal(err)
+	}
+	if err := trig.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if rng, _ = getIPBlock(t, p, "198.51.100.0/24"); rng.Spec.Unblock {
+		t.Fatal("replaced decision should keep the IPBlock active")
+	}
+	if len(startups) != 3 || startups[0] != "true" || startups[1] != "false" || startups[2] != "false" {
+		t.Fatalf("expected startup=true only on the first poll, got %v", startups)
+	}
+}
+
+// startup 拉取按全量比较，停机期间删除的决策对应的 IPBlock 被解封
+func TestCrowdSecTriggerStartupReconciles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"new":[{"id":7,"origin":"crowdsec","type":"ban","scope":"Ip","value":"192.0.2.60","duration":"1h","scenario":"crowdsecurity/ssh-bf"}],"deleted":null}`))
+	}))
+	defer srv.Close()
+
+	ipblock := func(ip, source string) *opsv1.IPBlock {
+		return &opsv1.IPBlock{
+			ObjectMeta: metav1.ObjectMeta{Name: utils.GenCRName(ip), Namespace: "default"},
+			Spec:       opsv1.IPBlockSpec{IP: ip, Source: source},
+			Status:     opsv1.IPBlockStatus{Phase: "active"},
+		}
+	}
+	p := newTestPipeline(t,
+		ipblock("192.0.2.60", crowdSecSource), // 仍生效
+		ipblock("192.0.2.61", crowdSecSource), // 停机期间被删除
+		ipblock("192.0.2.62", "grafana"),      // 其他来源
+	)
+	trig, err := NewCrowdSecTrigger(CrowdSecConfig{URL: srv.URL, APIKey: "secret"}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trig.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, wantUnblock := range map[string]bool{"192.0.2.60": false, "192.0.2.61": true, "192.0.2.62": false} {
+		got, ok := getIPBlock(t, p, ip)
+		if !ok {
+			t.Fatalf("%s: IPBlock missing", ip)
+		}
+		if got.Spec.Unblock != wantUnblock {
+			t.Errorf("%s: unblock = %v, want %v", ip, got.Spec.Unblock, wantUnblock)
+		}
+	}
+}
+
+func TestCrowdSecTriggerAuthError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"access forbidden"}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	trig, err := NewCrowdSecTrigger(CrowdSecConfig{URL: srv.URL, APIKey: "wrong"}, newTestPipeline(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trig.Poll(context.Background()); err == nil {
+		t.Fatal("expected error on forbidden response")
+	}
+	if trig.started {
+		t.Fatal("startup pull should be retried after a failure")
+	}
+	if _, err := NewCrowdSecTrigger(CrowdSecConfig{URL: srv.URL}, nil); err == nil {
+		t.Fatal("expected missing api key to be rejected")
+	}
+}
@@ -0,0 +1,180 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const falcoSource = "falco"
+
+// 默认从这些输出字段中取攻击者地址：fd.rip 为进程视角的对端地址，fd.cip 为客户端地址
+var defaultFalcoIPFields = []string{"fd.rip", "fd.cip"}
+
+// FalcoConfig falco 触发器配置，接收 Falco http_output（或 Falcosidekick webhook）推送的事件
+type FalcoConfig struct {
+	Addr     string      `json:"addr"`               // 监听地址
+	Path     string      `json:"path,omitempty"`     // 监听路由，填写在 http_output.url 中，默认 /falco
+	IPFields []string    `json:"ipFields,omitempty"` // 按顺序取第一个合法地址的 output_fields 字段
+	Rules    []AlertRule `json:"rules"`
+}
+
+// FalcoEvent Falco 的 JSON 输出
+type FalcoEvent struct {
+	Output       string         `json:"output"`
+	Priority     string         `json:"priority"`
+	Rule         string         `json:"rule"`
+	Time         string         `json:"time"`
+	Hostname     string         `json:"hostname,omitempty"`
+	Tags         []string       `json:"tags,omitempty"`
+	OutputFields map[string]any `json:"output_fields"`
+}
+
+// FalcoTrigger 按规则名和级别过滤 Falco 事件，命中规则的对端地址提交封禁
+type FalcoTrigger struct {
+	Pipeline *Pipeline
+	Config   FalcoConfig
+
+	alerts *alertRules
+
+	mu     sync.Mutex
+	server *http.Server
+}
+
+// NewFalcoTrigger 校验配置并编译规则
+func NewFalcoTrigger(cfg FalcoConfig, pipeline *Pipeline) (*FalcoTrigger, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("falco trigger requires addr")
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/falco"
+	}
+	if len(cfg.IPFields) == 0 {
+		cfg.IPFields = defaultFalcoIPFields
+	}
+	rules, err := compileAlertRules(falcoSource, cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	return &FalcoTrigger{
+		Pipeline: pipeline,
+		Config:   cfg,
+		alerts:   &alertRules{pipeline: pipeline, source: falcoSource, rules: rules, now: time.Now},
+	}, nil
+}
+
+func (f *FalcoTrigger) Name() string {
+	return falcoSource
+}
+
+func (f *FalcoTrigger) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(f.Config.Path, f.handleEvents)
+
+	f.mu.Lock()
+	f.server = &http.Server{Addr: f.Config.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	server := f.server
+	f.mu.Unlock()
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error(err, "[falco] ListenAndServe error")
+		}
+	}()
+	logger.Info("[falco] Trigger HTTP server started", "addr", f.Config.Addr, "path", f.Config.Path, "rules", len(f.alerts.rules))
+
+	go func() {
+		<-ctx.Done()
+		_ = f.Stop(context.Background())
+	}()
+	return nil
+}
+
+func (f *FalcoTrigger) Stop(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.server != nil {
+		logf.FromContext(ctx).Info("[falco] Shutting down HTTP server")
+		err := f.server.Shutdown(ctx)
+		f.server = nil
+		return err
+	}
+	return nil
+}
+
+// 请求体可以是单个事件、事件数组，或每行一个事件
+func (f *FalcoTrigger) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dec := json.NewDecoder(io.LimitReader(r.Body, 4<<20))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		var events []FalcoEvent
+		if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+			if err := json.Unmarshal(raw, &events); err != nil {
+				http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+				return
+			}
+		} else {
+			var ev FalcoEvent
+			if err := json.Unmarshal(raw, &ev); err != nil {
+				http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+				return
+			}
+			events = append(events, ev)
+		}
+		for _, ev := range events {
+			f.HandleEvent(r.Context(), ev)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// HandleEvent 处理一条 Falco 事件，返回是否提交了封禁
+func (f *FalcoTrigger) HandleEvent(ctx context.Context, ev FalcoEvent) bool {
+	a := securityAlert{
+		IP:        f.attacker(ev),
+		Signature: ev.Rule,
+		Priority:  strings.ToLower(ev.Priority),
+		Tags:      ev.Tags,
+	}
+	if a.IP == "" {
+		return false
+	}
+	reason := fmt.Sprintf("【Falco告警】%s (%s)", ev.Rule, ev.Priority)
+	return f.alerts.handle(ctx, a, reason)
+}
+
+func (f *FalcoTrigger) attacker(ev FalcoEvent) string {
+	for _, field := range f.Config.IPFields {
+		v, ok := ev.OutputFields[field].(string)
+		if !ok {
+			continue
+		}
+		if ip, ok := offenderIP(v); ok {
+			return ip
+		}
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package trigger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const falcoShellEvent = `{"uuid":"9c2a","output":"10:00:00.000000000: Notice Unexpected connection to K8s API Server from container (connection=198.51.100.20:51234->10.96.0.1:443)","priority":"Notice","rule":"Contact K8S API Server From Container","time":"2026-10-19T10:00:00.000000000Z","output_fields":{"container.id":"abc","fd.name":"198.51.100.20:51234->10.96.0.1:443","fd.rip":null,"fd.cip":"198.51.100.20","evt.time":1760868000000000000},"source":"syscall","tags":["network","k8s","mitre_discovery"],"hostname":"node-1"}`
+
+func TestFalcoTriggerWebhook(t *testing.T) {
+	p := newTestPipeline(t)
+	trig, err := NewFalcoTrigger(FalcoConfig{
+		Addr: ":0",
+		Rules: []AlertRule{
+			{Name: "debug", MinPriority: "warning", Signature: "^Debug", Ignore: true},
+			{Name: "k8s-api", Signature: "K8S API Server", Duration: "6h"},
+			{Name: "critical", MinPriority: "critical"},
+		},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 数组和逐行事件都能处理；warning 级别的事件没有规则命中
+	warning := `{"priority":"Warning","rule":"Read sensitive file untrusted","output_fields":{"fd.rip":"192.0.2.30"}}`
+	body := "[" + falcoShellEvent + "]\n" + warning + "\n"
+	rec := httptest.NewRecorder()
+	trig.handleEvents(rec, httptest.NewRequest(http.MethodPost, "/falco", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	ipblock, ok := getIPBlock(t, p, "198.51.100.20")
+	if !ok {
+		t.Fatal("expected IPBlock from fd.cip")
+	}
+	if ipblock.Spec.Source != "falco" || ipblock.Spec.Duration != "6h" || ipblock.Spec.Reason != "【Falco告警】Contact K8S API Server From Container (Notice)" {
+		t.Fatalf("unexpected spec %+v", ipblock.Spec)
+	}
+	if want := []string{"Contact K8S API Server From Container", "network", "k8s", "mitre_discovery"}; !reflect.DeepEqual(ipblock.Spec.Tags, want) {
+		t.Fatalf("got tags %v, want %v", ipblock.Spec.Tags, want)
+	}
+	if _, ok := getIPBlock(t, p, "192.0.2.30"); ok {
+		t.Fatal("warning event must not match the critical rule")
+	}
+
+	critical := FalcoEvent{Priority: "Critical", Rule: "Reverse shell", OutputFields: map[string]any{"fd.rip": "192.0.2.31"}}
+	if !trig.HandleEvent(t.Context(), critical) {
+		t.Fatal("expected critical event to ban")
+	}
+	noIP := FalcoEvent{Priority: "Emergency", Rule: "Reverse shell", OutputFields: map[string]any{"proc.name": "sh"}}
+	if trig.HandleEvent(t.Context(), noIP) {
+		t.Fatal("events without a remote address must be ignored")
+	}
+
+	rec = httptest.NewRecorder()
+	trig.handleEvents(rec, httptest.NewRequest(http.MethodPost, "/falco", strings.NewReader("{bad")))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, got %d", rec.Code)
+	}
+}
+
+func TestSeverityLevel(t *testing.T) {
+	if severityLevel("Info") != severityLevel("informational") || severityLevel("crit") != 2 || severityLevel("Emergency") != 0 || severityLevel("bogus") != -1 {
+		t.Fatal("unexpected priority mapping")
+	}
+	if _, err := NewFalcoTrigger(FalcoConfig{Rules: []AlertRule{{Name: "a"}}}, nil); err == nil {
+		t.Fatal("expected missing addr to be rejected")
+	}
+}
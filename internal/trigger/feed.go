@@ -0,0 +1,411 @@
+package trigger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	opsv1 "github/Beatrueman/ipblock-operator/api/v1"
+	"github/Beatrueman/ipblock-operator/internal/policy"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// 威胁情报源格式
+const (
+	FeedFormatText = "text" // 每行一个地址或 CIDR，# 或 ; 之后为注释，如 FireHOL netset
+	FeedFormatDrop = "drop" // Spamhaus DROP：1.10.16.0/20 ; SBL256894
+	FeedFormatCSV  = "csv"  // column 指定地址所在列
+	FeedFormatJSON = "json" // 地址字符串或对象组成的数组，也支持每行一个对象（如 Spamhaus drop_v4.json）
+)
+
+const (
+	defaultFeedInterval   = time.Hour
+	defaultFeedTimeout    = time.Minute
+	defaultFeedMaxEntries = 10000
+	// 写入 spec.source 的前缀，用于找出某个情报源导入的 IPBlock
+	FeedSourcePrefix = "feed/"
+	maxFeedSize      = 64 << 20
+)
+
+// FeedConfig feed 触发器配置，定期下载公开的黑名单，预先封禁已知的恶意网段
+type FeedConfig struct {
+	Interval string       `json:"interval,omitempty"` // 下载间隔，默认 1h
+	Timeout  string       `json:"timeout,omitempty"`  // 单次下载超时，默认 1m
+	Feeds    []FeedSource `json:"feeds"`
+}
+
+// FeedSource 单个情报源
+type FeedSource struct {
+	Name   string `json:"name"`             // IPBlock 的 source 为 feed/<name>
+	URL    string `json:"url"`              // 下载地址
+	Format string `json:"format,omitempty"` // text、drop、csv、json，默认 text
+	Column int    `json:"column,omitempty"` // csv 中地址所在列，从 0 开始
+	Field  string `json:"field,omitempty"`  // json 对象中地址所在字段，默认依次尝试 cidr、ip
+	// 单次导入的最大条目数，默认 10000；超出时放弃本次导入，已有的 IPBlock 保持不变
+	MaxEntries int      `json:"maxEntries,omitempty"`
+	Duration   string   `json:"duration,omitempty"` // 封禁时长，默认永久，条目从情报源消失时解封
+	Reason     string   `json:"reason,omitempty"`   // 默认为【威胁情报】feed/<name>
+	Tags       []string `json:"tags,omitempty"`     // 写入 IPBlock 的标签，如 blackhole
+}
+
+// FeedTrigger 把情报源中的网段导入为 IPBlock：与上次导入比较，新增的创建，消失的先解封再删除。
+// 白名单优先：与白名单重叠的条目不会导入
+type FeedTrigger struct {
+	Pipeline   *Pipeline
+	Config     FeedConfig
+	Whitelist  func() *policy.Whitelist // 返回当前白名单，为空时不过滤
+	HTTPClient *http.Client             // 为空时使用 http.DefaultClient
+
+	interval time.Duration
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	state  map[string]*feedState
+}
+
+// 上次成功下载的缓存，用于条件请求；内容未变化时复用上次的条目
+type feedState struct {
+	etag         string
+	lastModified string
+	entries      []string
+}
+
+// NewFeedTrigger 校验配置
+func NewFeedTrigger(cfg FeedConfig, pipeline *Pipeline) (*FeedTrigger, error) {
+	if len(cfg.Feeds) == 0 {
+		return nil, fmt.Errorf("feed trigger requires at least one feed")
+	}
+	seen := map[string]bool{}
+	for i := range cfg.Feeds {
+		f := &cfg.Feeds[i]
+		if f.Name == "" || seen[f.Name] {
+			return nil, fmt.Errorf("feed #%d requires a unique name", i+1)
+		}
+		seen[f.Name] = true
+		if u, err := url.Parse(f.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid url '%s' of feed '%s'", f.URL, f.Name)
+		}
+		switch f.Format {
+		case "":
+			f.Format = FeedFormatText
+		case FeedFormatText, FeedFormatDrop, FeedFormatCSV, FeedFormatJSON:
+		default:
+			return nil, fmt.Errorf("unknown format '%s' of feed '%s'", f.Format, f.Name)
+		}
+		if f.Column < 0 {
+			return nil, fmt.Errorf("column of feed '%s' must not be negative", f.Name)
+		}
+		if f.MaxEntries <= 0 {
+			f.MaxEntries = defaultFeedMaxEntries
+		}
+		if f.Duration != "" {
+			if _, err := time.ParseDuration(f.Duration); err != nil {
+				return nil, fmt.Errorf("invalid duration of feed '%s': %w", f.Name, err)
+			}
+		}
+		if f.Reason == "" {
+			f.Reason = "【威胁情报】" + FeedSourcePrefix + f.Name
+		}
+	}
+
+	t := &FeedTrigger{Pipeline: pipeline, Config: cfg, state: map[string]*feedState{}}
+	var err error
+	if t.interval, err = parseDurationOr(cfg.Interval, defaultFeedInterval); err != nil {
+		return nil, fmt.Errorf("invalid feed interval: %w", err)
+	}
+	if t.timeout, err = parseDurationOr(cfg.Timeout, defaultFeedTimeout); err != nil {
+		return nil, fmt.Errorf("invalid feed timeout: %w", err)
+	}
+	return t, nil
+}
+
+func (t *FeedTrigger) Name() string {
+	return "feed"
+}
+
+// Start 立即导入一次，之后按间隔导入，直到 Stop 或 ctx 结束
+func (t *FeedTrigger) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx)
+
+	t.mu.Lock()
+	if t.cancel != nil {
+		t.cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t.cancel = cancel
+	t.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			if err := t.Sync(ctx); err != nil {
+				logger.Error(err, "[feed] Import failed")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	logger.Info("[feed] Trigger started", "feeds", len(t.Config.Feeds), "interval", t.interval)
+	return nil
+}
+
+func (t *FeedTrigger) Stop(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		logf.FromContext(ctx).Info("[feed] Stopping importer")
+		t.cancel()
+		t.cancel = nil
+	}
+	return nil
+}
+
+// Sync 依次导入所有情报源，单个情报源失败不影响其他情报源
+func (t *FeedTrigger) Sync(ctx context.Context) error {
+	var errs []error
+	for _, f := range t.Config.Feeds {
+		if err := t.syncFeed(ctx, f); err != nil {
+			errs = append(errs, fmt.Errorf("feed '%s': %w", f.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *FeedTrigger) syncFeed(ctx context.Context, f FeedSource) error {
+	source := FeedSourcePrefix + f.Name
+	logger := logf.FromContext(ctx).WithValues("feed", source)
+
+	t.mu.Lock()
+	state := t.state[f.Name]
+	t.mu.Unlock()
+
+	entries, notModified, err := t.download(ctx, f, state)
+	if err != nil {
+		return err
+	}
+	if notModified {
+		entries = state.entries
+	}
+
+	// 白名单优先，每次导入都重新过滤，白名单变化后重叠的条目会被移除
+	desired := make(map[string]bool, len(entries))
+	whitelisted := 0
+	var whitelist *policy.Whitelist
+	if t.Whitelist != nil {
+		whitelist = t.Whitelist()
+	}
+	for _, e := range entries {
+		if whitelist != nil && whitelist.IsWhitelisted(e) {
+			whitelisted++
+			continue
+		}
+		desired[e] = true
+	}
+	if len(desired) > f.MaxEntries {
+		return fmt.Errorf("%d entries exceed maxEntries %d, import skipped", len(desired), f.MaxEntries)
+	}
+	// 情报源返回空内容多半是出错了，不能据此解封全部条目
+	if len(desired) == 0 && whitelisted == 0 {
+		return fmt.Errorf("no valid entries, import skipped")
+	}
+
+	existing, err := t.Pipeline.listBySource(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	created, removed := 0, 0
+	for ip := range desired {
+		if ipblock, ok := existing[ip]; ok && (ipblock.Status.Phase == "active" || ipblock.Status.Phase == "pending") && !ipblock.Spec.Unblock {
+			continue
+		}
+		t.Pipeline.Submit(ctx, Offender{IP: ip, Duration: f.Duration, Reason: f.Reason, Source: source, Tags: f.Tags})
+		created++
+	}
+	for ip, ipblock := range existing {
+		if desired[ip] {
+			continue
+		}
+		if err := t.remove(ctx, ipblock); err != nil {
+			logger.Error(err, "[feed] Remove IPBlock failed", "ip", ip)
+			continue
+		}
+		removed++
+	}
+	logger.Info("[feed] Imported", "entries", len(desired), "whitelisted", whitelisted, "submitted", created, "removed", removed, "notModified", notModified)
+	return nil
+}
+
+// 仍在封禁中的先设置 unblock 由控制器解封，下次导入时再删除；其他状态直接删除
+func (t *FeedTrigger) remove(ctx context.Context, ipblock *opsv1.IPBlock) error {
+	if ipblock.Status.Phase == "active" {
+		return t.Pipeline.unblock(ctx, ipblock)
+	}
+	return client.IgnoreNotFound(t.Pipeline.Client.Delete(ctx, ipblock))
+}
+
+// 下载并解析情报源，携带 ETag / Last-Modified 发起条件请求
+func (t *FeedTrigger) download(ctx context.Context, f FeedSource, state *feedState) ([]string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if state != nil {
+		if state.etag != "" {
+			req.Header.Set("If-None-Match", state.etag)
+		}
+		if state.lastModified != "" {
+			req.Header.Set("If-Modified-Since", state.lastModified)
+		}
+	}
+	httpClient := t.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && state != nil {
+		return nil, true, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, false, fmt.Errorf("download returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, false, err
+	}
+	entries, err := parseFeed(f, body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	t.mu.Lock()
+	t.state[f.Name] = &feedState{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified"), entries: entries}
+	t.mu.Unlock()
+	return entries, false, nil
+}
+
+// 解析情报源内容，返回去重后的地址和网段，无法解析的条目忽略
+func parseFeed(f FeedSource, body []byte) ([]string, error) {
+	var raw []string
+	switch f.Format {
+	case FeedFormatText, FeedFormatDrop:
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if i := strings.IndexAny(line, "#;"); i >= 0 {
+				line = line[:i]
+			}
+			if fields := strings.Fields(line); len(fields) > 0 {
+				raw = append(raw, fields[0])
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	case FeedFormatCSV:
+		r := csv.NewReader(bytes.NewReader(body))
+		r.Comment = '#'
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		for {
+			record, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("parse csv failed: %w", err)
+			}
+			if f.Column < len(record) {
+				raw = append(raw, record[f.Column])
+			}
+		}
+	case FeedFormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(body))
+		for {
+			var v any
+			if err := dec.Decode(&v); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("parse json failed: %w", err)
+			}
+			raw = appendJSONEntries(raw, v, f.Field)
+		}
+	}
+
+	seen := make(map[string]bool, len(raw))
+	entries := make([]string, 0, len(raw))
+	for _, r := range raw {
+		e, ok := feedEntry(r)
+		if !ok || seen[e] {
+			continue
+		}
+		seen[e] = true
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func appendJSONEntries(raw []string, v any, field string) []string {
+	switch val := v.(type) {
+	case string:
+		return append(raw, val)
+	case []any:
+		for _, item := range val {
+			raw = appendJSONEntries(raw, item, field)
+		}
+	case map[string]any:
+		fields := []string{"cidr", "ip"}
+		if field != "" {
+			fields = []string{field}
+		}
+		for _, name := range fields {
+			if s, ok := val[name].(string); ok {
+				return append(raw, s)
+			}
+		}
+	}
+	return raw
+}
+
+// 单个地址写成地址本身，网段写成网络地址形式，与 IPBlock 的 spec.ip 保持一致便于比较
+func feedEntry(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap().String(), true
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return "", false
+	}
+	prefix = prefix.Masked()
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String(), true
+	}
+	return prefix.String(), true
+}
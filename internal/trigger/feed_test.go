@@ -0,0 +1,156 @@
+package trigger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github/Beatrueman/ipblock-operator/internal/policy"
+)
+
+type feedServer struct {
+	mu          sync.Mutex
+	body        string
+	etag        string
+	conditional int // 命中 If-None-Match 的次数
+}
+
+func (s *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("If-None-Match") == s.etag {
+		s.conditional++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	_, _ = w.Write([]byte(s.body))
+}
+
+func (s *feedServer) set(body, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body, s.etag = body, etag
+}
+
+func TestFeedTriggerSync(t *testing.T) {
+	fs := &feedServer{}
+	fs.set("; Spamhaus DROP List\n192.0.2.0/24 ; SBL1\n198.51.100.0/24 ; SBL2\n10.0.0.0/8 ; SBL3\n203.0.113.7/32\n", `"v1"`)
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	p := newTestPipeline(t)
+	trig, err := NewFeedTrigger(FeedConfig{Feeds: []FeedSource{{Name: "drop", URL: srv.URL, Format: FeedFormatDrop, Tags: []string{"blackhole"}}}}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trig.Whitelist = func() *policy.Whitelist { return policy.NewWhitelist([]string{"10.1.2.3"}) }
+	ctx := context.Background()
+
+	if err := trig.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range []string{"192.0.2.0/24", "198.51.100.0/24", "203.0.113.7"} {
+		ipblock, ok := getIPBlock(t, p, ip)
+		if !ok {
+			t.Fatalf("expected IPBlock for %s", ip)
+		}
+		if ipblock.Spec.Source != "feed/drop" || !reflect.DeepEqual(ipblock.Spec.Tags, []string{"blackhole"}) {
+			t.Fatalf("unexpected spec %+v", ipblock.Spec)
+		}
+	}
+	if _, ok := getIPBlock(t, p, "10.0.0.0/8"); ok {
+		t.Fatal("whitelist must win over feed entries")
+	}
+
+	// 内容未变化时使用条件请求
+	if err := trig.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if fs.conditional != 1 {
+		t.Fatalf("expected a conditional request, got %d", fs.conditional)
+	}
+
+	// 192.0.2.0/24 已封禁，198.51.100.0/24 未封禁
+	active, _ := getIPBlock(t, p, "192.0.2.0/24")
+	active.Status.Phase = "active"
+	if err := p.Client.Status().Update(ctx, active); err != nil {
+		t.Fatal(err)
+	}
+	fs.set("203.0.113.7\n", `"v2"`)
+	if err := trig.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	unblocked, ok := getIPBlock(t, p, "192.0.2.0/24")
+	if !ok || !unblocked.Spec.Unblock {
+		t.Fatalf("expected active entry to be unblocked first, got %+v", unblocked)
+	}
+	if _, ok := getIPBlock(t, p, "198.51.100.0/24"); ok {
+		t.Fatal("expected entry that was never banned to be deleted")
+	}
+
+	// 控制器解封后下次导入删除
+	unblocked.Status.Phase = "expired"
+	if err := p.Client.Status().Update(ctx, unblocked); err != nil {
+		t.Fatal(err)
+	}
+	if err := trig.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := getIPBlock(t, p, "192.0.2.0/24"); ok {
+		t.Fatal("expected unblocked entry to be deleted")
+	}
+	if _, ok := getIPBlock(t, p, "203.0.113.7"); !ok {
+		t.Fatal("expected remaining entry to be kept")
+	}
+}
+
+func TestFeedTriggerSafetyLimits(t *testing.T) {
+	fs := &feedServer{}
+	fs.set("192.0.2.0/24\n198.51.100.0/24\n", `"v1"`)
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	p := newTestPipeline(t)
+	trig, err := NewFeedTrigger(FeedConfig{Feeds: []FeedSource{{Name: "big", URL: srv.URL, MaxEntries: 1}}}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trig.Sync(context.Background()); err == nil {
+		t.Fatal("expected maxEntries to abort the import")
+	}
+	if _, ok := getIPBlock(t, p, "192.0.2.0/24"); ok {
+		t.Fatal("no entries should be imported when maxEntries is exceeded")
+	}
+
+	fs.set("# nothing here\n", `"v2"`)
+	trig.Config.Feeds[0].MaxEntries = 10
+	if err := trig.Sync(context.Background()); err == nil {
+		t.Fatal("expected empty feed to be rejected")
+	}
+}
+
+func TestParseFeedFormats(t *testing.T) {
+	cases := []struct {
+		src  FeedSource
+		body string
+		want []string
+	}{
+		{FeedSource{Format: FeedFormatText}, "# firehol\n192.0.2.1\n192.0.2.0/24\n192.0.2.1/32\nbogus\n", []string{"192.0.2.1", "192.0.2.0/24"}},
+		{FeedSource{Format: FeedFormatCSV, Column: 1}, "id,network,desc\n1,198.51.100.0/24,bad\n2, 2001:db8::/32,worse\n", []string{"198.51.100.0/24", "2001:db8::/32"}},
+		{FeedSource{Format: FeedFormatJSON}, `{"cidr":"192.0.2.0/24","sblid":"SBL1"}` + "\n" + `{"type":"metadata","records":1}`, []string{"192.0.2.0/24"}},
+		{FeedSource{Format: FeedFormatJSON, Field: "network"}, `[{"network":"203.0.113.0/25"},"203.0.113.200"]`, []string{"203.0.113.0/25", "203.0.113.200"}},
+	}
+	for i, c := range cases {
+		got, err := parseFeed(c.src, []byte(c.body))
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("case %d: got %v, want %v", i, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package trigger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// 日志格式
+const (
+	LogFormatRegex = "regex" // pattern 中的命名分组作为字段，必须包含 ip 分组
+	LogFormatNginx = "nginx" // nginx / Apache combined 格式
+	LogFormatJSON  = "json"  // 每行一个 JSON 对象，嵌套字段以点号连接
+)
+
+const (
+	defaultLogPollInterval = time.Second
+	defaultLogIPField      = "ip"
+	defaultLogReason       = "【日志触发】{{.Rule}}: {{.IP}} 在 {{.Window}} 内命中 {{.Count}} 次"
+	// 单行最大长度，超过的行整行跳过
+	maxLogLineSize = 64 << 10
+)
+
+// nginx combined：$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"
+var nginxCombined = regexp.MustCompile(`^(?P<ip>\S+) \S+ (?P<user>\S+) \[(?P<time>[^\]]+)\] "(?P<request>[^"]*)" (?P<status>\d{3}) (?P<bytes>\S+)(?: "(?P<referer>[^"]*)" "(?P<user_agent>[^"]*)")?`)
+
+// LogTailConfig logtail 触发器配置，跟踪日志文件或监听 unix socket，按规则统计每个地址的事件数
+type LogTailConfig struct {
+	Files         []string  `json:"files,omitempty"`         // 跟踪的文件，通过共享卷挂载
+	Socket        string    `json:"socket,omitempty"`        // 监听的 unix socket，放在共享卷上，由 sidecar 按行写入；可与 files 同时配置
+	Format        string    `json:"format,omitempty"`        // regex、nginx、json，配置了 pattern 时默认 regex，否则默认 nginx
+	Pattern       string    `json:"pattern,omitempty"`       // regex 格式的正则，命名分组作为字段
+	IPField       string    `json:"ipField,omitempty"`       // 地址所在字段，默认 ip
+	FromBeginning bool      `json:"fromBeginning,omitempty"` // 从文件开头读取，默认只处理新写入的行
+	PollInterval  string    `json:"pollInterval,omitempty"`  // 文件读到末尾后的轮询间隔，默认 1s
+	Rules         []LogRule `json:"rules"`
+}
+
+// LogRule 封禁规则：窗口内命中 threshold 次即封禁，如 1 分钟内 5 次登录失败封禁 1 小时
+type LogRule struct {
+	Name      string            `json:"name"`
+	Match     string            `json:"match,omitempty"`  // 匹配整行的正则
+	Fields    map[string]string `json:"fields,omitempty"` // 字段名到正则，所有字段都匹配才算命中；缺少字段视为不匹配
+	Threshold int               `json:"threshold"`
+	Window    string            `json:"window"`
+	Duration  string            `json:"duration,omitempty"` // 封禁时长，为空表示永久
+	// 封禁原因模板（text/template），可用 .Rule .IP .Count .Window .Line .Fields
+	Reason string `json:"reason,omitempty"`
+}
+
+// LogReasonData 原因模板的参数，Line 和 Fields 为达到阈值的那一行
+type LogReasonData struct {
+	Rule   string
+	IP     string
+	Count  int
+	Window string
+	Line   string
+	Fields map[string]string
+}
+
+// LogTailTrigger 像 fail2ban 一样直接从日志识别攻击者，不需要 Grafana 参与
+type LogTailTrigger struct {
+	Pipeline *Pipeline
+	Config   LogTailConfig
+
+	pattern  *regexp.Regexp
+	interval time.Duration
+	rules    []compiledLogRule
+	now      func() time.Time
+
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	listener net.Listener // Stop 时同步关闭，保证重建触发器时可以重新监听
+}
+
+type compiledLogRule struct {
+	LogRule
+	match  *regexp.Regexp
+	fields map[string]*regexp.Regexp
+	reason *template.Template
+	counts *slidingWindow
+}
+
+// NewLogTailTrigger 校验配置并编译正则和原因模板
+func NewLogTailTrigger(cfg LogTailConfig, pipeline *Pipeline) (*LogTailTrigger, error) {
+	if cfg.Format == "" {
+		cfg.Format = LogFormatNginx
+		if cfg.Pattern != "" {
+			cfg.Format = LogFormatRegex
+		}
+	}
+	if cfg.IPField == "" {
+		cfg.IPField = defaultLogIPField
+	}
+	if len(cfg.Files) == 0 && cfg.Socket == "" {
+		return nil, fmt.Errorf("logtail trigger requires files or socket")
+	}
+
+	t := &LogTailTrigger{Pipeline: pipeline, Config: cfg, now: time.Now}
+	switch cfg.Format {
+	case LogFormatRegex:
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid logtail pattern: %w", err)
+		}
+		if re.SubexpIndex(cfg.IPField) < 0 {
+			return nil, fmt.Errorf("logtail pattern requires a named group '%s'", cfg.IPField)
+		}
+		t.pattern = re
+	case LogFormatNginx:
+		t.pattern = nginxCombined
+	case LogFormatJSON:
+	default:
+		return nil, fmt.Errorf("unknown logtail format '%s'", cfg.Format)
+	}
+
+	interval, err := parseDurationOr(cfg.PollInterval, defaultLogPollInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid logtail pollInterval: %w", err)
+	}
+	t.interval = interval
+
+	if len(cfg.Rules) == 0 {
+		return nil, fmt.Errorf("logtail trigger requires at least one rule")
+	}
+	for i, r := range cfg.Rules {
+		rule, err := compileLogRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("logtail rule #%d: %w", i+1, err)
+		}
+		t.rules = append(t.rules, rule)
+	}
+	return t, nil
+}
+
+func compileLogRule(r LogRule) (compiledLogRule, error) {
+	rule := compiledLogRule{LogRule: r, fields: make(map[string]*regexp.Regexp, len(r.Fields))}
+	if r.Name == "" {
+		return rule, fmt.Errorf("name is required")
+	}
+	if r.Threshold <= 0 {
+		return rule, fmt.Errorf("threshold of rule '%s' must be positive", r.Name)
+	}
+	window, err := time.ParseDuration(r.Window)
+	if err != nil || window <= 0 {
+		return rule, fmt.Errorf("invalid window '%s' of rule '%s'", r.Window, r.Name)
+	}
+	if r.Duration != "" {
+		if _, err := time.ParseDuration(r.Duration); err != nil {
+			return rule, fmt.Errorf("invalid duration of rule '%s': %w", r.Name, err)
+		}
+	}
+	if r.Match != "" {
+		if rule.match, err = regexp.Compile(r.Match); err != nil {
+			return rule, fmt.Errorf("invalid match of rule '%s': %w", r.Name, err)
+		}
+	}
+	for field, expr := range r.Fields {
+		if rule.fields[field], err = regexp.Compile(expr); err != nil {
+			return rule, fmt.Errorf("invalid field '%s' of rule '%s': %w", field, r.Name, err)
+		}
+	}
+	text := r.Reason
+	if text == "" {
+		text = defaultLogReason
+	}
+	if rule.reason, err = template.New(r.Name).Option("missingkey=zero").Parse(text); err != nil {
+		return rule, fmt.Errorf("invalid reason template of rule '%s': %w", r.Name, err)
+	}
+	rule.counts = newSlidingWindow(window, r.Threshold)
+	return rule, nil
+}
+
+func (l *LogTailTrigger) Name() string {
+	return "logtail"
+}
+
+// Start 为每个文件启动一个跟踪协程，直到 Stop 或 ctx 结束
+func (l *LogTailTrigger) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx)
+
+	l.mu.Lock()
+	if l.cancel != nil {
+		l.cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	l.cancel = cancel
+	l.closeListener()
+	if l.Config.Socket != "" {
+		if err := l.listen(ctx); err != nil {
+			cancel()
+			l.mu.Unlock()
+			return err
+		}
+	}
+	l.mu.Unlock()
+
+	for _, path := range l.Config.Files {
+		go tailFile(ctx, path, tailOptions{
+			Name:          l.Name(),
+			FromBeginning: l.Config.FromBeginning,
+			Interval:      l.interval,
+		}, func(line string) { l.HandleLine(ctx, line) })
+	}
+	logger.Info("[logtail] Trigger started", "files", l.Config.Files, "socket", l.Config.Socket, "format", l.Config.Format, "rules", len(l.rules))
+	return nil
+}
+
+func (l *LogTailTrigger) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cancel != nil {
+		logf.FromContext(ctx).Info("[logtail] Stopping tailers")
+		l.cancel()
+		l.cancel = nil
+	}
+	l.closeListener()
+	return nil
+}
+
+// 调用方需持有锁
+func (l *LogTailTrigger) closeListener() {
+	if l.listener != nil {
+		_ = l.listener.Close()
+		l.listener = nil
+	}
+}
+
+// 监听 unix socket，删除上次运行残留的 socket 文件，调用方需持有锁
+func (l *LogTailTrigger) listen(ctx context.Context) error {
+	path := l.Config.Socket
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove stale socket %s: %w", path, err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	// 关闭 unix 监听时会删除 socket 文件
+	l.listener = ln
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+	// 每个连接按行读取，连接断开后 sidecar 可以重新连接
+	go acceptLines(ctx, ln, l.Name(), func(line string) { l.HandleLine(ctx, line) })
+	return nil
+}
+
+// HandleLine 解析一行日志并按规则计数，达到阈值的地址提交封禁
+func (l *LogTailTrigger) HandleLine(ctx context.Context, line string) {
+	fields, ok := l.parse(line)
+	if !ok {
+		return
+	}
+	ip, ok := offenderIP(fields[l.Config.IPField])
+	if !ok {
+		return
+	}
+	now := l.now()
+	for _, rule := range l.rules {
+		if !rule.matches(line, fields) {
+			continue
+		}
+		count, reached := rule.counts.Add(ip, now)
+		if !reached {
+			continue
+		}
+		l.Pipeline.Submit(ctx, Offender{
+			IP:       ip,
+			Duration: rule.Duration,
+			Reason:   rule.render(LogReasonData{Rule: rule.Name, IP: ip, Count: count, Window: rule.Window, Line: line, Fields: fields}),
+			Source:   l.Name(),
+		})
+	}
+}
+
+// 按格式把一行解析为字段
+func (l *LogTailTrigger) parse(line string) (map[string]string, bool) {
+	if l.Config.Format == LogFormatJSON {
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.UseNumber()
+		var obj map[string]any
+		if err := dec.Decode(&obj); err != nil {
+			return nil, false
+		}
+		fields := make(map[string]string, len(obj))
+		flattenJSON("", obj, fields)
+		return fields, true
+	}
+
+	m := l.pattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	fields := make(map[string]string, len(m))
+	for i, name := range l.pattern.SubexpNames() {
+		if name != "" && m[i] != "" {
+			fields[name] = m[i]
+		}
+	}
+	if l.Config.Format == LogFormatNginx {
+		if parts := strings.Fields(fields["request"]); len(parts) >= 2 {
+			fields["method"], fields["path"] = parts[0], parts[1]
+		}
+	}
+	return fields, true
+}
+
+func flattenJSON(prefix string, obj map[string]any, out map[string]string) {
+	for k, v := range obj {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch val := v.(type) {
+		case map[string]any:
+			flattenJSON(key, val, out)
+		case string:
+			out[key] = val
+		case nil:
+		default:
+			out[key] = fmt.Sprint(val)
+		}
+	}
+}
+
+func (r *compiledLogRule) matches(line string, fields map[string]string) bool {
+	if r.match != nil && !r.match.MatchString(line) {
+		return false
+	}
+	for field, re := range r.fields {
+		v, ok := fields[field]
+		if !ok || !re.MatchString(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *compiledLogRule) render(data LogReasonData) string {
+	var buf bytes.Buffer
+	if err := r.reason.Execute(&buf, data); err != nil {
+		return fmt.Sprintf("【日志触发】%s: %s 在 %s 内命中 %d 次", data.Rule, data.IP, data.Window, data.Count)
+	}
+	return buf.String()
+}
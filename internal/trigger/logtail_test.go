@@ -0,0 +1,252 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	w := newSlidingWindow(time.Minute, 3)
+	start := time.Unix(1700000000, 0)
+
+	w.Add("a", start)
+	w.Add("a", start.Add(10*time.Second))
+	// 第一次事件已滑出窗口
+	if _, reached := w.Add("a", start.Add(61*time.Second)); reached {
+		t.Fatal("expected events outside the window to be dropped")
+	}
+	count, reached := w.Add("a", start.Add(62*time.Second))
+	if !reached || count != 3 {
+		t.Fatalf("expected threshold reached with 3 events, got %d %v", count, reached)
+	}
+	// 达到阈值后重新计数
+	if _, reached := w.Add("a", start.Add(63*time.Second)); reached {
+		t.Fatal("expected counter reset after threshold")
+	}
+}
+
+func TestLogTailNginxRule(t *testing.T) {
+	p := newTestPipeline(t)
+	trig, err := NewLogTailTrigger(LogTailConfig{
+		Files:  []string{"/var/log/nginx/access.log"},
+		Format: LogFormatNginx,
+		Rules: []LogRule{{
+			Name:      "login-bruteforce",
+			Fields:    map[string]string{"method": "^POST$", "path": "^/login", "status": "^401$"},
+			Threshold: 3,
+			Window:    "1m",
+			Duration:  "1h",
+		}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := `203.0.113.5 - - [19/Oct/2026:10:00:00 +0000] "POST /login HTTP/1.1" %d 12 "-" "curl/8.0"`
+	ctx := context.Background()
+	trig.HandleLine(ctx, fmt.Sprintf(line, 401))
+	trig.HandleLine(ctx, fmt.Sprintf(line, 200))
+	trig.HandleLine(ctx, fmt.Sprintf(line, 401))
+	if _, ok := getIPBlock(t, p, "203.0.113.5"); ok {
+		t.Fatal("should not ban below threshold")
+	}
+	trig.HandleLine(ctx, fmt.Sprintf(line, 401))
+
+	ipblock, ok := getIPBlock(t, p, "203.0.113.5")
+	if !ok {
+		t.Fatal("expected IPBlock after threshold")
+	}
+	if ipblock.Spec.Source != "logtail" || ipblock.Spec.Duration != "1h" || ipblock.Spec.Reason != "【日志触发】login-bruteforce: 203.0.113.5 在 1m 内命中 3 次" {
+		t.Fatalf("unexpected spec %+v", ipblock.Spec)
+	}
+}
+
+func TestLogTailRegexAndJSON(t *testing.T) {
+	p := newTestPipeline(t)
+	sshd, err := NewLogTailTrigger(LogTailConfig{
+		Files:   []string{"/var/log/secure"},
+		Pattern: `Failed password for .* from (?P<ip>\S+) port`,
+		Rules:   []LogRule{{Name: "sshd", Threshold: 1, Window: "1m"}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshd.HandleLine(context.Background(), "sshd[1]: Failed password for root from 198.51.100.1 port 22 ssh2")
+	if _, ok := getIPBlock(t, p, "198.51.100.1"); !ok {
+		t.Fatal("expected regex format to extract ip")
+	}
+
+	app, err := NewLogTailTrigger(LogTailConfig{
+		Files:   []string{"/var/log/app.log"},
+		Format:  LogFormatJSON,
+		IPField: "client.ip",
+		Rules:   []LogRule{{Name: "auth", Fields: map[string]string{"event": "^auth_failed$"}, Threshold: 1, Window: "1m", Reason: "{{.Fields.user}} from {{.IP}}"}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.HandleLine(context.Background(), `{"event":"auth_failed","user":"admin","client":{"ip":"198.51.100.2"}}`)
+	ipblock, ok := getIPBlock(t, p, "198.51.100.2")
+	if !ok || ipblock.Spec.Reason != "admin from 198.51.100.2" {
+		t.Fatalf("expected JSON format to extract nested ip, got %+v", ipblock)
+	}
+}
+
+func TestLogTailFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	if err := os.WriteFile(path, []byte("198.51.100.10 old line before start\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestPipeline(t)
+	trig, err := NewLogTailTrigger(LogTailConfig{
+		Files:        []string{path},
+		Pattern:      `^(?P<ip>\S+) `,
+		PollInterval: "10ms",
+		Rules:        []LogRule{{Name: "any", Threshold: 1, Window: "1m"}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := trig.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	appendLine(t, path, "198.51.100.11 appended")
+	// 轮转：移走旧文件并创建新文件
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLine(t, path, "198.51.100.12 after rotation")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, appended := getIPBlock(t, p, "198.51.100.11")
+		_, rotatedLine := getIPBlock(t, p, "198.51.100.12")
+		if appended && rotatedLine {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("lines not picked up: appended=%v rotated=%v", appended, rotatedLine)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := getIPBlock(t, p, "198.51.100.10"); ok {
+		t.Fatal("existing lines should be skipped unless fromBeginning is set")
+	}
+	_ = trig.Stop(context.Background())
+}
+
+func appendLine(t *testing.T, path, line string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogTailFileSkipsLongLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	long := "198.51.100.30 " + strings.Repeat("x", maxLogLineSize) + " 198.51.100.32 tail\n"
+	if err := os.WriteFile(path, []byte(long+"198.51.100.31 after long line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestPipeline(t)
+	trig, err := NewLogTailTrigger(LogTailConfig{
+		Files:         []string{path},
+		Pattern:       `(?P<ip>\d+\.\d+\.\d+\.\d+) `,
+		FromBeginning: true,
+		PollInterval:  "10ms",
+		Rules:         []LogRule{{Name: "any", Threshold: 1, Window: "1m"}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := trig.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := getIPBlock(t, p, "198.51.100.31"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected line after the over-long line to be handled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 超长的行既不截断也不拼接，整行跳过
+	for _, ip := range []string{"198.51.100.30", "198.51.100.32"} {
+		if _, ok := getIPBlock(t, p, ip); ok {
+			t.Fatalf("over-long line should be skipped, got IPBlock for %s", ip)
+		}
+	}
+}
+
+func TestLogTailSocketSkipsLongLines(t *testing.T) {
+	if _, err := NewLogTailTrigger(LogTailConfig{Rules: []LogRule{{Name: "any", Threshold: 1, Window: "1m"}}}, newTestPipeline(t)); err == nil {
+		t.Fatal("expected error without files or socket")
+	}
+
+	p := newTestPipeline(t)
+	socket := filepath.Join(t.TempDir(), "logtail.sock")
+	trig, err := NewLogTailTrigger(LogTailConfig{
+		Socket:  socket,
+		Pattern: `^(?P<ip>\S+) `,
+		Rules:   []LogRule{{Name: "any", Threshold: 1, Window: "1m"}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := trig.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超长的行整行跳过，之后的行照常处理
+	long := "198.51.100.20 " + strings.Repeat("x", maxLogLineSize) + "\n"
+	if _, err := conn.Write([]byte(long + "198.51.100.21 after long line\n")); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := getIPBlock(t, p, "198.51.100.21"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected line after the over-long line to be handled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := getIPBlock(t, p, "198.51.100.20"); ok {
+		t.Fatal("over-long line should be skipped")
+	}
+	if err := trig.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
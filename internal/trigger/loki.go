@@ -0,0 +1,28 @@
+package trigger
+
+// LokiConfig loki 触发器配置，定期执行 LogQL 指标查询，如 sum by (ip) (count_over_time({app="auth"} |= "login failed" | json [5m]))
+type LokiConfig struct {
+	URL      string        `json:"url"`                // Loki 地址，如 http://loki-gateway.monitoring
+	TenantID string        `json:"tenantID,omitempty"` // 多租户部署时的租户，作为 X-Scope-OrgID 请求头
+	Interval string        `json:"interval,omitempty"` // 查询间隔，默认 1m
+	Timeout  string        `json:"timeout,omitempty"`  // 单次查询超时，默认 30s
+	Queries  []PromQLQuery `json:"queries"`            // 查询必须是返回 instant vector 的指标查询
+}
+
+// LokiTrigger 轮询 Loki 的 instant query 接口，适合认证失败等只写入日志、没有指标的事件
+type LokiTrigger struct {
+	*queryTrigger
+	Config LokiConfig
+}
+
+// NewLokiTrigger 校验配置并编译原因和时长模板
+func NewLokiTrigger(cfg LokiConfig, pipeline *Pipeline) (*LokiTrigger, error) {
+	q, err := newQueryTrigger("loki", "LogQL", cfg.URL, "/loki/api/v1/query", cfg.Interval, cfg.Timeout, cfg.Queries, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TenantID != "" {
+		q.header.Set("X-Scope-OrgID", cfg.TenantID)
+	}
+	return &LokiTrigger{queryTrigger: q, Config: cfg}, nil
+}
@@ -0,0 +1,73 @@
+package trigger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLokiTriggerPoll(t *testing.T) {
+	var tenant, query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		tenant = r.Header.Get("X-Scope-OrgID")
+		query = r.URL.Query().Get("query")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"ip":"203.0.113.20"},"value":[1700000000,"12"]},
+			{"metric":{"ip":"203.0.113.21"},"value":[1700000000,"2000"]}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	p := newTestPipeline(t)
+	trig, err := NewLokiTrigger(LokiConfig{
+		URL:      srv.URL + "/",
+		TenantID: "ops",
+		Queries: []PromQLQuery{{
+			Name:     "auth-failures",
+			Query:    `sum by (ip) (count_over_time({app="auth"} |= "login failed" | json [5m]))`,
+			Duration: `{{if gt .Value 1000.0}}24h{{else}}1h{{end}}`,
+			Reason:   "{{.IP}} 5 分钟内认证失败 {{.Value}} 次",
+		}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trig.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if tenant != "ops" || query != trig.Config.Queries[0].Query {
+		t.Fatalf("unexpected request tenant=%q query=%q", tenant, query)
+	}
+	low, ok := getIPBlock(t, p, "203.0.113.20")
+	if !ok || low.Spec.Duration != "1h" || low.Spec.Source != "loki" || low.Spec.Reason != "203.0.113.20 5 分钟内认证失败 12 次" {
+		t.Fatalf("unexpected spec %+v", low)
+	}
+	high, ok := getIPBlock(t, p, "203.0.113.21")
+	if !ok || high.Spec.Duration != "24h" {
+		t.Fatalf("expected duration template to pick 24h, got %+v", high)
+	}
+}
+
+func TestLokiTriggerQueryError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "parse error at line 1, col 5: syntax error", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	trig, err := NewLokiTrigger(LokiConfig{URL: srv.URL, Queries: []PromQLQuery{{Name: "bad", Query: "sum("}}}, newTestPipeline(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trig.Poll(context.Background()); err == nil {
+		t.Fatal("expected error for failed query")
+	}
+	if _, err := NewLokiTrigger(LokiConfig{URL: srv.URL, Queries: []PromQLQuery{{Name: "q", Query: "x", Duration: "{{.Value"}}}, nil); err == nil {
+		t.Fatal("expected invalid duration template to be rejected")
+	}
+}
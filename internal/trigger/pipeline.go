@@ -0,0 +1,221 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	opsv1 "github/Beatrueman/ipblock-operator/api/v1"
+	utils "github/Beatrueman/ipblock-operator/internal/utils"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// IPBlock 默认创建在 default 命名空间，与 Operator 读取 ConfigMap 的位置一致
+const defaultNamespace = "default"
+
+// Offender 触发器识别出的待封禁地址
+type Offender struct {
+	IP       string
+	Duration string // 封禁时长，为空表示永久
+	Reason   string
+	Source   string   // 触发器名称，写入 spec.source，也用作日志前缀
+	Tags     []string // 创建 IPBlock 时写入 spec.tags
+}
+
+// Pipeline 把触发器识别出的地址写成 IPBlock，所有触发器共用：
+// CR 不存在时创建；处于 pending、expired 时 patch trigger 重新封禁；active、skipped、failed 时不打扰
+type Pipeline struct {
+	Client    client.Client
+	Debouncer utils.Debouncer // 防抖，防止同个 IP 短时间内多次触发，生成多个相同 IP 的 CR
+	IPLocker  *utils.IPLock   // 防止竞争
+	Namespace string          // 为空时使用 default
+}
+
+// Submit 创建或 patch 一个地址对应的 IPBlock
+func (p *Pipeline) Submit(ctx context.Context, o Offender) {
+	logger := logf.FromContext(ctx)
+	prefix := "[" + o.Source + "]"
+
+	p.IPLocker.Lock(o.IP)
+	defer p.IPLocker.Unlock(o.IP)
+
+	// 防抖，避免重复创建或 patch
+	if p.Debouncer != nil && !p.Debouncer.ShouldAllow(o.IP) {
+		logger.Info(prefix+" Skip duplicate IPBlock within TTL", "ip", o.IP)
+		return
+	}
+
+	namespace := p.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	crName := utils.GenCRName(o.IP)
+	var existing opsv1.IPBlock
+
+	err := p.Client.Get(context.Background(), client.ObjectKey{
+		Name:      crName,
+		Namespace: namespace,
+	}, &existing)
+
+	if err != nil && !apierrors.IsNotFound(err) {
+		logger.Error(err, prefix+" Error checking IPBlock existence", "ip", o.IP)
+		return
+	}
+
+	// CR 已存在
+	if err == nil {
+		phase := existing.Status.Phase
+
+		switch phase {
+		// 不打扰的状态
+		case "active", "skipped", "failed":
+			logger.Info(prefix+" Skip patch, IPBlock phase does not allow re-trigger",
+				"ip", o.IP,
+				"phase", phase)
+			return
+		// 允许重新触发的状态，状态流转
+		case "pending", "expired":
+			if !existing.Spec.Trigger {
+				logger.Info(prefix+" IPBlock exists, patch to trigger reconciling",
+					"ip", o.IP, "phase", phase)
+
+				patch := client.MergeFrom(existing.DeepCopy())
+				existing.Spec.Trigger = true
+				existing.Spec.Reason = o.Reason
+				existing.Spec.Duration = o.Duration
+
+				if err := p.Client.Patch(context.Background(), &existing, patch); err != nil {
+					logger.Error(err, prefix+" Patch existing IPBlock failed", "ip", o.IP)
+				} else {
+					logger.Info(prefix+" Patched existing IPBlock to trigger re-ban", "ip", o.IP)
+				}
+			} else {
+				logger.Info(prefix+" Skip patch, trigger already set",
+					"ip", o.IP,
+					"phase", phase)
+			}
+		default:
+			logger.Info(prefix+" Skip patch, unknown Phase",
+				"ip", o.IP,
+				"phase", phase)
+		}
+		return
+	}
+
+	// CR 不存在：创建新的
+	ipblock := &opsv1.IPBlock{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      crName,
+			Namespace: namespace,
+		},
+		Spec: opsv1.IPBlockSpec{
+			IP:       o.IP,
+			Trigger:  true,
+			Reason:   o.Reason,
+			Source:   o.Source,
+			Duration: o.Duration,
+			Tags:     o.Tags,
+		},
+	}
+
+	if err := p.Client.Create(context.Background(), ipblock); err != nil {
+		logger.Error(err, prefix+" Create IPBlock error", "ip", o.IP)
+	} else {
+		logger.Info(prefix+" Created IPBlock successfully", "ip", o.IP)
+	}
+}
+
+// Unblock 解封触发器之前创建的 IPBlock：处于 active、pending 的设置 unblock，由控制器解封。
+// 只处理 spec.source 与 source 一致的 IPBlock，不解封其他来源的封禁
+func (p *Pipeline) Unblock(ctx context.Context, ip, source string) error {
+	logger := logf.FromContext(ctx)
+	prefix := "[" + source + "]"
+
+	p.IPLocker.Lock(ip)
+	defer p.IPLocker.Unlock(ip)
+
+	namespace := p.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	var existing opsv1.IPBlock
+	if err := p.Client.Get(ctx, client.ObjectKey{Name: utils.GenCRName(ip), Namespace: namespace}, &existing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if existing.Spec.Source != source {
+		logger.Info(prefix+" Skip unblock, IPBlock created by another source", "ip", ip, "source", existing.Spec.Source)
+		return nil
+	}
+	if err := p.unblock(ctx, &existing); err != nil {
+		return err
+	}
+	logger.Info(prefix+" Requested unblock", "ip", ip, "phase", existing.Status.Phase)
+	return nil
+}
+
+// 由 source 创建的 IPBlock，按地址索引
+func (p *Pipeline) listBySource(ctx context.Context, source string) (map[string]*opsv1.IPBlock, error) {
+	namespace := p.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	var list opsv1.IPBlockList
+	if err := p.Client.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("list IPBlocks failed: %w", err)
+	}
+	existing := map[string]*opsv1.IPBlock{}
+	for i := range list.Items {
+		if list.Items[i].Spec.Source == source {
+			existing[list.Items[i].Spec.IP] = &list.Items[i]
+		}
+	}
+	return existing, nil
+}
+
+func (p *Pipeline) unblock(ctx context.Context, ipblock *opsv1.IPBlock) error {
+	if ipblock.Spec.Unblock || (ipblock.Status.Phase != "active" && ipblock.Status.Phase != "pending") {
+		return nil
+	}
+	patch := client.MergeFrom(ipblock.DeepCopy())
+	ipblock.Spec.Unblock = true
+	return p.Client.Patch(ctx, ipblock, patch)
+}
+
+// 解析触发器中识别出的地址，可以是地址、CIDR 或带端口的地址
+func offenderIP(v string) (string, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", false
+	}
+	if host, _, err := net.SplitHostPort(v); err == nil {
+		v = host
+	}
+	if addr, err := netip.ParseAddr(v); err == nil {
+		return addr.Unmap().String(), true
+	}
+	if prefix, err := netip.ParsePrefix(v); err == nil {
+		return prefix.Masked().String(), true
+	}
+	return "", false
+}
+
+func parseDurationOr(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive")
+	}
+	return d, nil
+}
@@ -0,0 +1,24 @@
+package trigger
+
+// PrometheusConfig prometheus 触发器配置，定期执行 PromQL 查询，把结果序列中的地址标签作为封禁对象
+type PrometheusConfig struct {
+	URL      string        `json:"url"`                // Prometheus HTTP API 地址，如 http://prometheus:9090
+	Interval string        `json:"interval,omitempty"` // 查询间隔，默认 1m
+	Timeout  string        `json:"timeout,omitempty"`  // 单次查询超时，默认 30s
+	Queries  []PromQLQuery `json:"queries"`
+}
+
+// PrometheusTrigger 不依赖告警规则，直接轮询 Prometheus，适合"5 分钟内 401 超过 1000 次的地址"这类不值得单独配置告警的检测
+type PrometheusTrigger struct {
+	*queryTrigger
+	Config PrometheusConfig
+}
+
+// NewPrometheusTrigger 校验配置并编译原因和时长模板
+func NewPrometheusTrigger(cfg PrometheusConfig, pipeline *Pipeline) (*PrometheusTrigger, error) {
+	q, err := newQueryTrigger("prometheus", "PromQL", cfg.URL, "/api/v1/query", cfg.Interval, cfg.Timeout, cfg.Queries, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	return &PrometheusTrigger{queryTrigger: q, Config: cfg}, nil
+}
@@ -0,0 +1,153 @@
+package trigger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	opsv1 "github/Beatrueman/ipblock-operator/api/v1"
+	utils "github/Beatrueman/ipblock-operator/internal/utils"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestPipeline(t *testing.T, objs ...client.Object) *Pipeline {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := opsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).WithStatusSubresource(&opsv1.IPBlock{}).Build()
+	return &Pipeline{Client: c, IPLocker: utils.NewIPLock()}
+}
+
+func getIPBlock(t *testing.T, p *Pipeline, ip string) (*opsv1.IPBlock, bool) {
+	t.Helper()
+	var ipblock opsv1.IPBlock
+	err := p.Client.Get(context.Background(), client.ObjectKey{Name: utils.GenCRName(ip), Namespace: "default"}, &ipblock)
+	if err != nil {
+		return nil, false
+	}
+	return &ipblock, true
+}
+
+func TestPipelineCreatesOrPatches(t *testing.T) {
+	expired := &opsv1.IPBlock{
+		ObjectMeta: metav1.ObjectMeta{Name: utils.GenCRName("192.0.2.2"), Namespace: "default"},
+		Spec:       opsv1.IPBlockSpec{IP: "192.0.2.2", Reason: "old"},
+		Status:     opsv1.IPBlockStatus{Phase: "expired"},
+	}
+	active := &opsv1.IPBlock{
+		ObjectMeta: metav1.ObjectMeta{Name: utils.GenCRName("192.0.2.3"), Namespace: "default"},
+		Spec:       opsv1.IPBlockSpec{IP: "192.0.2.3", Reason: "old"},
+		Status:     opsv1.IPBlockStatus{Phase: "active"},
+	}
+	p := newTestPipeline(t, expired, active)
+
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		p.Submit(context.Background(), Offender{IP: ip, Duration: "1h", Reason: "new", Source: "test"})
+	}
+
+	created, ok := getIPBlock(t, p, "192.0.2.1")
+	if !ok || !created.Spec.Trigger || created.Spec.Source != "test" || created.Spec.Duration != "1h" {
+		t.Fatalf("expected created IPBlock, got %+v", created)
+	}
+	patched, _ := getIPBlock(t, p, "192.0.2.2")
+	if !patched.Spec.Trigger || patched.Spec.Reason != "new" {
+		t.Fatalf("expected expired IPBlock to be re-triggered, got %+v", patched.Spec)
+	}
+	untouched, _ := getIPBlock(t, p, "192.0.2.3")
+	if untouched.Spec.Trigger || untouched.Spec.Reason != "old" {
+		t.Fatalf("expected active IPBlock untouched, got %+v", untouched.Spec)
+	}
+}
+
+func TestPrometheusTriggerPoll(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		queries = append(queries, r.URL.Query().Get("query"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"client_ip":"198.51.100.7"},"value":[1700000000,"1500"]},
+			{"metric":{"client_ip":"198.51.100.8:443"},"value":[1700000000,"1200"]},
+			{"metric":{"client_ip":"198.51.100.9"},"value":[1700000000,"20"]},
+			{"metric":{"client_ip":"not-an-ip"},"value":[1700000000,"5000"]}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	threshold := 1000.0
+	p := newTestPipeline(t)
+	trig, err := NewPrometheusTrigger(PrometheusConfig{
+		URL: srv.URL,
+		Queries: []PromQLQuery{{
+			Name:      "http-401",
+			Query:     `sum by (client_ip) (increase(http_requests_total{code="401"}[5m]))`,
+			IPLabel:   "client_ip",
+			Threshold: &threshold,
+			Duration:  "30m",
+			Reason:    "{{.Name}}: {{.IP}} 5 分钟内 401 {{.Value}} 次",
+		}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trig.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queries) != 1 || queries[0] != trig.queries[0].Query {
+		t.Fatalf("unexpected queries %v", queries)
+	}
+	ipblock, ok := getIPBlock(t, p, "198.51.100.7")
+	if !ok {
+		t.Fatal("expected IPBlock for 198.51.100.7")
+	}
+	if ipblock.Spec.Reason != "http-401: 198.51.100.7 5 分钟内 401 1500 次" || ipblock.Spec.Duration != "30m" || ipblock.Spec.Source != "prometheus" {
+		t.Fatalf("unexpected spec %+v", ipblock.Spec)
+	}
+	if _, ok := getIPBlock(t, p, "198.51.100.8"); !ok {
+		t.Fatal("expected port to be stripped from label value")
+	}
+	if _, ok := getIPBlock(t, p, "198.51.100.9"); ok {
+		t.Fatal("series below threshold should not be banned")
+	}
+}
+
+func TestPrometheusTriggerRejectsNonVector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1700000000,"1"]}}`))
+	}))
+	defer srv.Close()
+
+	trig, err := NewPrometheusTrigger(PrometheusConfig{URL: srv.URL, Queries: []PromQLQuery{{Name: "q", Query: "vector(1)"}}}, newTestPipeline(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trig.Poll(context.Background()); err == nil {
+		t.Fatal("expected error for scalar result")
+	}
+}
+
+func TestNewPrometheusTriggerValidation(t *testing.T) {
+	cases := []PrometheusConfig{
+		{URL: "", Queries: []PromQLQuery{{Name: "q", Query: "up"}}},
+		{URL: "http://prom:9090"},
+		{URL: "http://prom:9090", Queries: []PromQLQuery{{Name: "q"}}},
+		{URL: "http://prom:9090", Interval: "-1m", Queries: []PromQLQuery{{Name: "q", Query: "up"}}},
+		{URL: "http://prom:9090", Queries: []PromQLQuery{{Name: "q", Query: "up", Reason: "{{.Name"}}},
+	}
+	for i, cfg := range cases {
+		if _, err := NewPrometheusTrigger(cfg, nil); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package trigger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	defaultQueryInterval = time.Minute
+	defaultQueryTimeout  = 30 * time.Second
+	defaultQueryIPLabel  = "ip"
+)
+
+// PromQLQuery 单条查询，返回的每个序列对应一个地址；Loki 的 LogQL 指标查询使用相同的配置
+type PromQLQuery struct {
+	Name      string   `json:"name"`
+	Query     string   `json:"query"`               // 结果需为 instant vector
+	IPLabel   string   `json:"ipLabel,omitempty"`   // 地址所在标签，默认 ip；带端口的值（如 instance）会去掉端口
+	Threshold *float64 `json:"threshold,omitempty"` // 序列值大于阈值才封禁，未设置时封禁所有返回的序列
+	// 封禁时长，为空表示永久；可以是模板，如 {{if gt .Value 1000.0}}24h{{else}}1h{{end}}
+	Duration string `json:"duration,omitempty"`
+	// 封禁原因模板（text/template），可用 .Name .Query .IP .Value .Labels
+	Reason string `json:"reason,omitempty"`
+}
+
+// PromReasonData 原因和时长模板的参数
+type PromReasonData struct {
+	Name   string
+	Query  string
+	IP     string
+	Value  float64
+	Labels map[string]string
+}
+
+// queryTrigger 定期调用 Prometheus 兼容的 instant query 接口，把结果序列中的地址标签作为封禁对象。
+// prometheus 和 loki 触发器共用
+type queryTrigger struct {
+	Pipeline   *Pipeline
+	HTTPClient *http.Client // 为空时使用 http.DefaultClient
+
+	name     string      // 触发器名称，也写入 spec.source
+	kind     string      // 查询语言，用于默认原因
+	endpoint string      // 查询接口的完整地址
+	header   http.Header // 每次查询附带的请求头
+	queries  []compiledQuery
+	interval time.Duration
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+type compiledQuery struct {
+	PromQLQuery
+	reason   *template.Template
+	duration *template.Template // 为空时 Duration 是固定值
+}
+
+// baseURL 为服务地址，path 为查询接口路径
+func newQueryTrigger(name, kind, baseURL, path, interval, timeout string, queries []PromQLQuery, pipeline *Pipeline) (*queryTrigger, error) {
+	if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s url '%s'", name, baseURL)
+	}
+	endpoint := strings.TrimRight(baseURL, "/") + path
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("%s trigger requires at least one query", name)
+	}
+	q := &queryTrigger{Pipeline: pipeline, name: name, kind: kind, endpoint: endpoint, header: http.Header{}}
+	var err error
+	if q.interval, err = parseDurationOr(interval, defaultQueryInterval); err != nil {
+		return nil, fmt.Errorf("invalid %s interval: %w", name, err)
+	}
+	if q.timeout, err = parseDurationOr(timeout, defaultQueryTimeout); err != nil {
+		return nil, fmt.Errorf("invalid %s timeout: %w", name, err)
+	}
+
+	for i, query := range queries {
+		if query.Name == "" || query.Query == "" {
+			return nil, fmt.Errorf("%s query #%d requires name and query", name, i+1)
+		}
+		if query.IPLabel == "" {
+			query.IPLabel = defaultQueryIPLabel
+		}
+		c := compiledQuery{PromQLQuery: query}
+		if strings.Contains(query.Duration, "{{") {
+			if c.duration, err = template.New(query.Name).Option("missingkey=zero").Parse(query.Duration); err != nil {
+				return nil, fmt.Errorf("invalid duration template of %s query '%s': %w", name, query.Name, err)
+			}
+		} else if query.Duration != "" {
+			if _, err := time.ParseDuration(query.Duration); err != nil {
+				return nil, fmt.Errorf("invalid duration of %s query '%s': %w", name, query.Name, err)
+			}
+		}
+		text := query.Reason
+		if text == "" {
+			text = "【" + kind + "触发】{{.Name}} 当前值 {{.Value}}"
+		}
+		if c.reason, err = template.New(query.Name).Option("missingkey=zero").Parse(text); err != nil {
+			return nil, fmt.Errorf("invalid reason template of %s query '%s': %w", name, query.Name, err)
+		}
+		q.queries = append(q.queries, c)
+	}
+	return q, nil
+}
+
+func (q *queryTrigger) Name() string {
+	return q.name
+}
+
+// Start 立即查询一次，之后按间隔轮询，直到 Stop 或 ctx 结束
+func (q *queryTrigger) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx)
+
+	q.mu.Lock()
+	if q.cancel != nil {
+		q.cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	q.cancel = cancel
+	q.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(q.interval)
+		defer ticker.Stop()
+		for {
+			if err := q.Poll(ctx); err != nil {
+				logger.Error(err, "["+q.name+"] Poll failed")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	logger.Info("["+q.name+"] Trigger started", "url", q.endpoint, "queries", len(q.queries), "interval", q.interval)
+	return nil
+}
+
+func (q *queryTrigger) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.cancel != nil {
+		logf.FromContext(ctx).Info("[" + q.name + "] Stopping poller")
+		q.cancel()
+		q.cancel = nil
+	}
+	return nil
+}
+
+// Poll 依次执行所有查询并提交结果，单条查询失败不影响其他查询
+func (q *queryTrigger) Poll(ctx context.Context) error {
+	logger := logf.FromContext(ctx)
+	var errs []error
+	for _, query := range q.queries {
+		samples, err := q.query(ctx, query.Query)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("query '%s': %w", query.Name, err))
+			continue
+		}
+		for _, s := range samples {
+			if query.Threshold != nil && s.Value <= *query.Threshold {
+				continue
+			}
+			ip, ok := offenderIP(s.Labels[query.IPLabel])
+			if !ok {
+				logger.Info("["+q.name+"] Skip series without a valid address", "query", query.Name, "label", query.IPLabel, "value", s.Labels[query.IPLabel])
+				continue
+			}
+			data := PromReasonData{Name: query.Name, Query: query.Query, IP: ip, Value: s.Value, Labels: s.Labels}
+			duration, err := query.renderDuration(data)
+			if err != nil {
+				logger.Error(err, "["+q.name+"] Invalid duration, skip", "query", query.Name, "ip", ip)
+				continue
+			}
+			q.Pipeline.Submit(ctx, Offender{
+				IP:       ip,
+				Duration: duration,
+				Reason:   q.renderReason(query, data),
+				Source:   q.name,
+			})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (q *queryTrigger) renderReason(query compiledQuery, data PromReasonData) string {
+	var buf bytes.Buffer
+	if err := query.reason.Execute(&buf, data); err != nil {
+		return fmt.Sprintf("【%s触发】%s 当前值 %v", q.kind, data.Name, data.Value)
+	}
+	return buf.String()
+}
+
+// 渲染时长模板，结果为空表示永久
+func (c *compiledQuery) renderDuration(data PromReasonData) (string, error) {
+	if c.duration == nil {
+		return c.Duration, nil
+	}
+	var buf bytes.Buffer
+	if err := c.duration.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	d := strings.TrimSpace(buf.String())
+	if d == "" {
+		return "", nil
+	}
+	if _, err := time.ParseDuration(d); err != nil {
+		return "", err
+	}
+	return d, nil
+}
+
+type promSample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// 调用 instant query 接口，只接受 vector 类型的结果
+func (q *queryTrigger) query(ctx context.Context, query string) ([]promSample, error) {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	u := q.endpoint + "?" + url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range q.header {
+		req.Header[k] = v
+	}
+	httpClient := q.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Metric map[string]string `json:"metric"`
+				Value  [2]any            `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("%s returned %d: %s", q.name, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("%s returned %d: %s", q.name, resp.StatusCode, result.Error)
+	}
+	if result.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("query must return an instant vector, got %s", result.Data.ResultType)
+	}
+
+	samples := make([]promSample, 0, len(result.Data.Result))
+	for _, r := range result.Data.Result {
+		s, ok := r.Value[1].(string)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, promSample{Labels: r.Metric, Value: v})
+	}
+	return samples, nil
+}
@@ -0,0 +1,240 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Suricata EVE 输出到 unix socket 的方式，对应 eve-log 的 filetype: unix_stream / unix_dgram
+const (
+	SuricataSocketStream = "stream"
+	SuricataSocketDgram  = "dgram"
+)
+
+const suricataSource = "suricata"
+
+// SuricataConfig suricata 触发器配置，读取 EVE JSON 中的 alert 事件，文件和 socket 可以同时配置
+type SuricataConfig struct {
+	Files         []string `json:"files,omitempty"`         // 跟踪的 eve.json 文件
+	Socket        string   `json:"socket,omitempty"`        // 监听的 unix socket，Suricata 作为客户端写入
+	SocketType    string   `json:"socketType,omitempty"`    // stream、dgram，默认 stream
+	FromBeginning bool     `json:"fromBeginning,omitempty"` // 从文件开头读取，默认只处理新写入的事件
+	PollInterval  string   `json:"pollInterval,omitempty"`  // 文件读到末尾后的轮询间隔，默认 1s
+	// 攻击者地址：src_ip 或 dest_ip；为空时优先使用告警的 source（规则带 target 关键字时），否则使用 src_ip
+	IPField string      `json:"ipField,omitempty"`
+	Rules   []AlertRule `json:"rules"`
+}
+
+// SuricataEvent EVE JSON 中触发器关心的字段
+type SuricataEvent struct {
+	EventType string         `json:"event_type"`
+	SrcIP     string         `json:"src_ip"`
+	DestIP    string         `json:"dest_ip"`
+	Alert     *SuricataAlert `json:"alert,omitempty"`
+}
+
+type SuricataAlert struct {
+	Action      string `json:"action"`
+	SignatureID int64  `json:"signature_id"`
+	Signature   string `json:"signature"`
+	Category    string `json:"category"`
+	Severity    int    `json:"severity"`
+	Source      *struct {
+		IP string `json:"ip"`
+	} `json:"source,omitempty"`
+}
+
+// SuricataTrigger 按签名、sid、类别和严重级别过滤 Suricata 告警，命中规则的来源地址提交封禁
+type SuricataTrigger struct {
+	Pipeline *Pipeline
+	Config   SuricataConfig
+
+	interval time.Duration
+	alerts   *alertRules
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	closers []io.Closer // 监听的 socket，Stop 时同步关闭，保证重建触发器时可以重新监听
+}
+
+// NewSuricataTrigger 校验配置并编译规则
+func NewSuricataTrigger(cfg SuricataConfig, pipeline *Pipeline) (*SuricataTrigger, error) {
+	if len(cfg.Files) == 0 && cfg.Socket == "" {
+		return nil, fmt.Errorf("suricata trigger requires files or socket")
+	}
+	if cfg.SocketType == "" {
+		cfg.SocketType = SuricataSocketStream
+	}
+	if cfg.SocketType != SuricataSocketStream && cfg.SocketType != SuricataSocketDgram {
+		return nil, fmt.Errorf("unknown suricata socketType '%s'", cfg.SocketType)
+	}
+	switch cfg.IPField {
+	case "", "src_ip", "dest_ip":
+	default:
+		return nil, fmt.Errorf("suricata ipField must be src_ip or dest_ip")
+	}
+
+	t := &SuricataTrigger{Pipeline: pipeline, Config: cfg}
+	var err error
+	if t.interval, err = parseDurationOr(cfg.PollInterval, defaultLogPollInterval); err != nil {
+		return nil, fmt.Errorf("invalid suricata pollInterval: %w", err)
+	}
+	rules, err := compileAlertRules(suricataSource, cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	t.alerts = &alertRules{pipeline: pipeline, source: suricataSource, rules: rules, now: time.Now}
+	return t, nil
+}
+
+func (t *SuricataTrigger) Name() string {
+	return suricataSource
+}
+
+// Start 为每个文件启动跟踪协程，并在配置了 socket 时开始监听，直到 Stop 或 ctx 结束
+func (t *SuricataTrigger) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx)
+
+	t.mu.Lock()
+	if t.cancel != nil {
+		t.cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t.cancel = cancel
+	t.closeSockets()
+	if t.Config.Socket != "" {
+		if err := t.listen(ctx); err != nil {
+			cancel()
+			t.mu.Unlock()
+			return err
+		}
+	}
+	t.mu.Unlock()
+
+	for _, path := range t.Config.Files {
+		go tailFile(ctx, path, tailOptions{
+			Name:          t.Name(),
+			FromBeginning: t.Config.FromBeginning,
+			Interval:      t.interval,
+		}, func(line string) { t.HandleEVE(ctx, []byte(line)) })
+	}
+	logger.Info("[suricata] Trigger started", "files", t.Config.Files, "socket", t.Config.Socket, "rules", len(t.alerts.rules))
+	return nil
+}
+
+func (t *SuricataTrigger) Stop(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		logf.FromContext(ctx).Info("[suricata] Stopping trigger")
+		t.cancel()
+		t.cancel = nil
+	}
+	t.closeSockets()
+	return nil
+}
+
+// 调用方需持有锁
+func (t *SuricataTrigger) closeSockets() {
+	for _, c := range t.closers {
+		_ = c.Close()
+	}
+	t.closers = nil
+}
+
+// HandleEVE 处理一条 EVE 事件，非 alert 事件直接忽略；返回是否提交了封禁
+func (t *SuricataTrigger) HandleEVE(ctx context.Context, data []byte) bool {
+	var ev SuricataEvent
+	if err := json.Unmarshal(data, &ev); err != nil || ev.EventType != "alert" || ev.Alert == nil {
+		return false
+	}
+	a := securityAlert{
+		IP:          t.attacker(ev),
+		Signature:   ev.Alert.Signature,
+		SignatureID: ev.Alert.SignatureID,
+		Category:    ev.Alert.Category,
+		Severity:    ev.Alert.Severity,
+	}
+	reason := fmt.Sprintf("【Suricata告警】%s (sid %d, severity %d)", a.Signature, a.SignatureID, a.Severity)
+	return t.alerts.handle(ctx, a, reason)
+}
+
+func (t *SuricataTrigger) attacker(ev SuricataEvent) string {
+	switch t.Config.IPField {
+	case "src_ip":
+		return ev.SrcIP
+	case "dest_ip":
+		return ev.DestIP
+	}
+	if ev.Alert.Source != nil && ev.Alert.Source.IP != "" {
+		return ev.Alert.Source.IP
+	}
+	return ev.SrcIP
+}
+
+// 监听 unix socket，删除上次运行残留的 socket 文件，调用方需持有锁
+func (t *SuricataTrigger) listen(ctx context.Context) error {
+	path := t.Config.Socket
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove stale socket %s: %w", path, err)
+	}
+
+	if t.Config.SocketType == SuricataSocketDgram {
+		conn, err := net.ListenPacket("unixgram", path)
+		if err != nil {
+			return err
+		}
+		t.closers = append(t.closers, conn, unlinker(path))
+		go func() {
+			<-ctx.Done()
+			_ = conn.Close()
+		}()
+		go t.readDatagrams(ctx, conn)
+		return nil
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	// 关闭 unix 监听时会删除 socket 文件
+	t.closers = append(t.closers, ln)
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+	// 超长的事件（带 payload、http body 等）整行跳过，不断开连接
+	go acceptLines(ctx, ln, t.Name(), func(line string) { t.HandleEVE(ctx, []byte(line)) })
+	return nil
+}
+
+// unixgram 关闭时不会删除 socket 文件
+type unlinker string
+
+func (u unlinker) Close() error {
+	return os.Remove(string(u))
+}
+
+// 每个数据报是一条事件
+func (t *SuricataTrigger) readDatagrams(ctx context.Context, conn net.PacketConn) {
+	buf := make([]byte, maxLogLineSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				logf.FromContext(ctx).Error(err, "[suricata] Read socket failed")
+			}
+			return
+		}
+		t.HandleEVE(ctx, buf[:n])
+	}
+}
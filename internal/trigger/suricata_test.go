@@ -0,0 +1,132 @@
+package trigger
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func eveAlert(src, signature string, sid, severity int) string {
+	return `{"timestamp":"2026-10-19T08:00:00.000000+0000","event_type":"alert","src_ip":"` + src + `","src_port":51234,"dest_ip":"10.0.0.5","dest_port":22,"proto":"TCP",` +
+		`"alert":{"action":"allowed","gid":1,"signature_id":` + strconv.Itoa(sid) + `,"rev":1,"signature":"` + signature + `","category":"Attempted Information Leak","severity":` + strconv.Itoa(severity) + `}}`
+}
+
+func TestSuricataTriggerRules(t *testing.T) {
+	p := newTestPipeline(t)
+	trig, err := NewSuricataTrigger(SuricataConfig{
+		Files: []string{"/var/log/suricata/eve.json"},
+		Rules: []AlertRule{
+			{Name: "noisy", Signature: "^SURICATA STREAM", Ignore: true},
+			{Name: "scan", Signature: "^ET SCAN", Threshold: 2, Window: "1m", Duration: "1h", Tags: []string{"ids"}},
+			{Name: "high", MaxSeverity: 1, Duration: "24h"},
+		},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if trig.HandleEVE(ctx, []byte(`{"event_type":"flow","src_ip":"192.0.2.9"}`)) {
+		t.Fatal("non-alert events must be ignored")
+	}
+	if trig.HandleEVE(ctx, []byte(eveAlert("192.0.2.10", "SURICATA STREAM ESTABLISHED packet out of window", 2210020, 1))) {
+		t.Fatal("ignored signature must not ban even when severity matches a later rule")
+	}
+	if trig.HandleEVE(ctx, []byte(eveAlert("192.0.2.11", "ET INFO Observed DNS Query", 2027757, 3))) {
+		t.Fatal("low severity alert without matching rule must not ban")
+	}
+
+	scan := eveAlert("192.0.2.12", "ET SCAN Potential SSH Scan", 2001219, 2)
+	if trig.HandleEVE(ctx, []byte(scan)) {
+		t.Fatal("threshold not reached yet")
+	}
+	if !trig.HandleEVE(ctx, []byte(scan)) {
+		t.Fatal("expected ban after reaching threshold")
+	}
+	ipblock, ok := getIPBlock(t, p, "192.0.2.12")
+	if !ok {
+		t.Fatal("expected IPBlock for scanner")
+	}
+	if ipblock.Spec.Source != "suricata" || ipblock.Spec.Duration != "1h" || ipblock.Spec.Reason != "【Suricata告警】ET SCAN Potential SSH Scan (sid 2001219, severity 2)" {
+		t.Fatalf("unexpected spec %+v", ipblock.Spec)
+	}
+	if want := []string{"ids", "ET SCAN Potential SSH Scan", "sid:2001219"}; !reflect.DeepEqual(ipblock.Spec.Tags, want) {
+		t.Fatalf("got tags %v, want %v", ipblock.Spec.Tags, want)
+	}
+
+	// 规则带 target 关键字时使用 alert.source
+	targeted := `{"event_type":"alert","src_ip":"10.0.0.5","dest_ip":"198.51.100.7","alert":{"signature_id":1,"signature":"GPL ATTACK_RESPONSE id check returned root","severity":1,"source":{"ip":"198.51.100.7","port":80},"target":{"ip":"10.0.0.5","port":4444}}}`
+	if !trig.HandleEVE(ctx, []byte(targeted)) {
+		t.Fatal("expected high severity alert to ban")
+	}
+	if ipblock, ok := getIPBlock(t, p, "198.51.100.7"); !ok || ipblock.Spec.Duration != "24h" {
+		t.Fatalf("expected alert source to be banned, got %+v", ipblock)
+	}
+	if _, ok := getIPBlock(t, p, "10.0.0.5"); ok {
+		t.Fatal("alert target must not be banned")
+	}
+}
+
+func TestSuricataTriggerSocket(t *testing.T) {
+	p := newTestPipeline(t)
+	socket := filepath.Join(t.TempDir(), "eve.sock")
+	trig, err := NewSuricataTrigger(SuricataConfig{
+		Socket: socket,
+		Rules:  []AlertRule{{Name: "all"}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := trig.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超长的事件（如带 payload）整行跳过，同一连接上后续的事件照常处理
+	long := `{"event_type":"alert","payload":"` + strings.Repeat("A", maxLogLineSize) + `"}` + "\n"
+	if _, err := conn.Write([]byte(long + eveAlert("203.0.113.5", "ET EXPLOIT Apache log4j RCE Attempt", 2034647, 1) + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := getIPBlock(t, p, "203.0.113.5"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected alert read from socket to be banned")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := trig.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSuricataConfigValidation(t *testing.T) {
+	cases := []SuricataConfig{
+		{Rules: []AlertRule{{Name: "a"}}},
+		{Files: []string{"eve.json"}},
+		{Files: []string{"eve.json"}, SocketType: "tcp", Rules: []AlertRule{{Name: "a"}}},
+		{Files: []string{"eve.json"}, IPField: "flow_id", Rules: []AlertRule{{Name: "a"}}},
+		{Files: []string{"eve.json"}, Rules: []AlertRule{{Name: "a", Signature: "("}}},
+		{Files: []string{"eve.json"}, Rules: []AlertRule{{Name: "a", MinPriority: "loud"}}},
+		{Files: []string{"eve.json"}, Rules: []AlertRule{{Signature: "ET"}}},
+	}
+	for i, c := range cases {
+		if _, err := NewSuricataTrigger(c, nil); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package trigger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github/Beatrueman/ipblock-operator/internal/engine"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	syslogSource        = "syslog"
+	defaultSyslogReason = "【Syslog触发】{{.Rule}}: {{.Message}}"
+	// TCP / TLS 连接空闲超过该时间后关闭，发送端会自动重连
+	syslogIdleTimeout = 10 * time.Minute
+)
+
+// SyslogConfig syslog 触发器配置，接收防火墙、WAF 等设备发送的 syslog，至少配置一种监听方式
+type SyslogConfig struct {
+	UDP string           `json:"udp,omitempty"` // UDP 监听地址，如 ":5514"
+	TCP string           `json:"tcp,omitempty"` // TCP 监听地址
+	TLS *SyslogTLSConfig `json:"tls,omitempty"`
+	// 允许的发送端地址或网段，为空时接受任意来源；UDP 来源可以伪造，对公网开放时建议使用双向 TLS
+	AllowedSources []string     `json:"allowedSources,omitempty"`
+	Rules          []SyslogRule `json:"rules"`
+}
+
+// SyslogTLSConfig syslog over TLS（RFC 5425）的监听配置
+type SyslogTLSConfig struct {
+	Addr       string           `json:"addr"`       // TLS 监听地址，如 ":6514"
+	CertSecret engine.SecretRef `json:"certSecret"` // kubernetes.io/tls 类型的 Secret，服务端证书
+	// 校验客户端证书的 CA，设置后要求双向 TLS，key 默认 ca.crt
+	ClientCASecret *engine.SecretKeyRef `json:"clientCASecret,omitempty"`
+
+	// 由 Operator 从 Secret 读取后填入
+	Cert     []byte `json:"-"`
+	Key      []byte `json:"-"`
+	ClientCA []byte `json:"-"`
+}
+
+// SyslogRule 匹配规则：pattern 中的命名分组 ip 为待封禁地址，可选的 duration 分组为封禁时长
+type SyslogRule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`               // 匹配 MSG 的正则，必须包含命名分组 ip
+	Hostname    string `json:"hostname,omitempty"`    // 匹配发送端主机名的正则
+	AppName     string `json:"appName,omitempty"`     // 匹配 APP-NAME（RFC 3164 的 TAG）的正则
+	MinSeverity string `json:"minSeverity,omitempty"` // 级别不低于该值才匹配，如 warning
+	Threshold   int    `json:"threshold,omitempty"`   // 窗口内同一地址命中次数，默认 1
+	Window      string `json:"window,omitempty"`      // 默认 1m
+	// 封禁时长，为空表示永久；duration 分组匹配到合法的时长（如 1h、3600 秒）时优先使用分组的值
+	Duration string   `json:"duration,omitempty"`
+	Tags     []string `json:"tags,omitempty"` // 附加到 IPBlock 的标签
+	// 封禁原因模板（text/template），可用 .Rule .IP .Count .Window .Hostname .AppName .Message .Fields
+	Reason string `json:"reason,omitempty"`
+}
+
+// SyslogReasonData 原因模板的参数，消息为达到阈值的那一条
+type SyslogReasonData struct {
+	Rule     string
+	IP       string
+	Count    int
+	Window   string
+	Hostname string
+	AppName  string
+	Message  string
+	Fields   map[string]string
+}
+
+// SyslogTrigger 监听 UDP / TCP / TLS，按规则从 syslog 消息中提取地址并提交封禁
+type SyslogTrigger struct {
+	Pipeline *Pipeline
+	Config   SyslogConfig
+
+	tlsConfig *tls.Config
+	allowed   []netip.Prefix
+	rules     []compiledSyslogRule
+	now       func() time.Time
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	closers []io.Closer // 监听的 socket，Stop 时同步关闭，保证重建触发器时可以重新监听
+}
+
+type compiledSyslogRule struct {
+	SyslogRule
+	window      string
+	pattern     *regexp.Regexp
+	hostname    *regexp.Regexp
+	appName     *regexp.Regexp
+	minSeverity int // -1 不限制
+	reason      *template.Template
+	counts      *slidingWindow
+}
+
+// NewSyslogTrigger 校验配置、编译规则并加载 TLS 证书
+func NewSyslogTrigger(cfg SyslogConfig, pipeline *Pipeline) (*SyslogTrigger, error) {
+	if cfg.UDP == "" && cfg.TCP == "" && cfg.TLS == nil {
+		return nil, fmt.Errorf("syslog trigger requires udp, tcp or tls")
+	}
+	t := &SyslogTrigger{Pipeline: pipeline, Config: cfg, now: time.Now}
+
+	if cfg.TLS != nil {
+		if cfg.TLS.Addr == "" {
+			return nil, fmt.Errorf("syslog tls requires addr")
+		}
+		cert, err := tls.X509KeyPair(cfg.TLS.Cert, cfg.TLS.Key)
+		if err != nil {
+			return nil, fmt.Errorf("load syslog tls certificate failed: %w", err)
+		}
+		t.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+		if len(cfg.TLS.ClientCA) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(cfg.TLS.ClientCA) {
+				return nil, fmt.Errorf("no valid certificate found in syslog client CA")
+			}
+			t.tlsConfig.ClientCAs = pool
+			t.tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	for _, src := range cfg.AllowedSources {
+		prefix, err := netip.ParsePrefix(src)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(src)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid syslog allowedSources entry '%s'", src)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		t.allowed = append(t.allowed, prefix.Masked())
+	}
+
+	if len(cfg.Rules) == 0 {
+		return nil, fmt.Errorf("syslog trigger requires at least one rule")
+	}
+	for i, r := range cfg.Rules {
+		rule, err := compileSyslogRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("syslog rule #%d: %w", i+1, err)
+		}
+		t.rules = append(t.rules, rule)
+	}
+	return t, nil
+}
+
+func compileSyslogRule(r SyslogRule) (compiledSyslogRule, error) {
+	rule := compiledSyslogRule{SyslogRule: r, minSeverity: -1}
+	if r.Name == "" {
+		return rule, fmt.Errorf("name is required")
+	}
+	var err error
+	if rule.pattern, err = regexp.Compile(r.Pattern); err != nil {
+		return rule, fmt.Errorf("invalid pattern of rule '%s': %w", r.Name, err)
+	}
+	if rule.pattern.SubexpIndex("ip") < 0 {
+		return rule, fmt.Errorf("pattern of rule '%s' requires a named group 'ip'", r.Name)
+	}
+	if r.Hostname != "" {
+		if rule.hostname, err = regexp.Compile(r.Hostname); err != nil {
+			return rule, fmt.Errorf("invalid hostname of rule '%s': %w", r.Name, err)
+		}
+	}
+	if r.AppName != "" {
+		if rule.appName, err = regexp.Compile(r.AppName); err != nil {
+			return rule, fmt.Errorf("invalid appName of rule '%s': %w", r.Name, err)
+		}
+	}
+	if r.MinSeverity != "" {
+		if rule.minSeverity = severityLevel(r.MinSeverity); rule.minSeverity < 0 {
+			return rule, fmt.Errorf("unknown minSeverity '%s' of rule '%s'", r.MinSeverity, r.Name)
+		}
+	}
+	if r.Threshold < 0 {
+		return rule, fmt.Errorf("threshold of rule '%s' must not be negative", r.Name)
+	}
+	window, err := parseDurationOr(r.Window, defaultAlertWindow)
+	if err != nil || window <= 0 {
+		return rule, fmt.Errorf("invalid window '%s' of rule '%s'", r.Window, r.Name)
+	}
+	rule.window = window.String()
+	if r.Duration != "" {
+		if _, err := time.ParseDuration(r.Duration); err != nil {
+			return rule, fmt.Errorf("invalid duration of rule '%s': %w", r.Name, err)
+		}
+	}
+	text := r.Reason
+	if text == "" {
+		text = defaultSyslogReason
+	}
+	if rule.reason, err = template.New(r.Name).Option("missingkey=zero").Parse(text); err != nil {
+		return rule, fmt.Errorf("invalid reason template of rule '%s': %w", r.Name, err)
+	}
+	rule.counts = newSlidingWindow(window, r.Threshold)
+	return rule, nil
+}
+
+func (t *SyslogTrigger) Name() string {
+	return syslogSource
+}
+
+// Start 同步打开所有监听，任一失败时关闭已打开的监听并返回错误
+func (t *SyslogTrigger) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.closeSockets()
+	ctx, cancel := context.WithCancel(ctx)
+	t.cancel = cancel
+
+	if err := t.listen(ctx); err != nil {
+		cancel()
+		t.cancel = nil
+		t.closeSockets()
+		return err
+	}
+	logger.Info("[syslog] Trigger started", "udp", t.Config.UDP, "tcp", t.Config.TCP, "tls", t.tlsConfig != nil, "rules", len(t.rules))
+	return nil
+}
+
+func (t *SyslogTrigger) Stop(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		logf.FromContext(ctx).Info("[syslog] Stopping listeners")
+		t.cancel()
+		t.cancel = nil
+	}
+	t.closeSockets()
+	return nil
+}
+
+// 调用方需持有锁
+func (t *SyslogTrigger) closeSockets() {
+	for _, c := range t.closers {
+		_ = c.Close()
+	}
+	t.closers = nil
+}
+
+// 调用方需持有锁
+func (t *SyslogTrigger) listen(ctx context.Context) error {
+	if t.Config.UDP != "" {
+		conn, err := net.ListenPacket("udp", t.Config.UDP)
+		if err != nil {
+			return fmt.Errorf("listen syslog udp: %w", err)
+		}
+		t.closers = append(t.closers, conn)
+		go closeOnDone(ctx, conn)
+		go t.readDatagrams(ctx, conn)
+	}
+	if t.Config.TCP != "" {
+		ln, err := net.Listen("tcp", t.Config.TCP)
+		if err != nil {
+			return fmt.Errorf("listen syslog tcp: %w", err)
+		}
+		t.closers = append(t.closers, ln)
+		go closeOnDone(ctx, ln)
+		go t.accept(ctx, ln)
+	}
+	if t.tlsConfig != nil {
+		ln, err := tls.Listen("tcp", t.Config.TLS.Addr, t.tlsConfig)
+		if err != nil {
+			return fmt.Errorf("listen syslog tls: %w", err)
+		}
+		t.closers = append(t.closers, ln)
+		go closeOnDone(ctx, ln)
+		go t.accept(ctx, ln)
+	}
+	return nil
+}
+
+func closeOnDone(ctx context.Context, c io.Closer) {
+	<-ctx.Done()
+	_ = c.Close()
+}
+
+// 每个数据报是一条消息
+func (t *SyslogTrigger) readDatagrams(ctx context.Context, conn net.PacketConn) {
+	buf := make([]byte, maxLogLineSize)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				logf.FromContext(ctx).Error(err, "[syslog] Read udp failed")
+			}
+			return
+		}
+		if !t.allowedSource(from) {
+			logf.FromContext(ctx).V(1).Info("[syslog] Dropped datagram from disallowed source", "remote", from.String())
+			continue
+		}
+		t.HandleMessage(ctx, buf[:n])
+	}
+}
+
+func (t *SyslogTrigger) accept(ctx context.Context, ln net.Listener) {
+	logger := logf.FromContext(ctx)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				logger.Error(err, "[syslog] Accept failed")
+			}
+			return
+		}
+		// TLS 连接在握手前即按来源过滤
+		if !t.allowedSource(conn.RemoteAddr()) {
+			logger.Info("[syslog] Rejected connection from disallowed source", "remote", conn.RemoteAddr().String())
+			_ = conn.Close()
+			continue
+		}
+		go t.serve(ctx, conn)
+	}
+}
+
+// 未配置 allowedSources 时接受任意来源
+func (t *SyslogTrigger) allowedSource(addr net.Addr) bool {
+	if len(t.allowed) == 0 {
+		return true
+	}
+	if addr == nil {
+		return false
+	}
+	addrPort, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := addrPort.Addr().Unmap()
+	for _, prefix := range t.allowed {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 一个连接上可以连续发送多条消息，Stop 或空闲超时后关闭
+func (t *SyslogTrigger) serve(ctx context.Context, conn net.Conn) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReaderSize(conn, 64<<10)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(syslogIdleTimeout))
+		frame, err := readSyslogFrame(reader)
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				logf.FromContext(ctx).Info("[syslog] Connection closed", "remote", conn.RemoteAddr().String(), "reason", err.Error())
+			}
+			return
+		}
+		if len(frame) > 0 {
+			t.HandleMessage(ctx, frame)
+		}
+	}
+}
+
+// HandleMessage 解析一条 syslog 消息并按规则计数，达到阈值的地址提交封禁；返回提交的封禁数
+func (t *SyslogTrigger) HandleMessage(ctx context.Context, raw []byte) int {
+	msg := ParseSyslog(string(bytes.TrimSpace(raw)))
+	now := t.now()
+	submitted := 0
+	for i := range t.rules {
+		rule := &t.rules[i]
+		fields, ok := rule.match(msg)
+		if !ok {
+			continue
+		}
+		ip, ok := offenderIP(fields["ip"])
+		if !ok {
+			continue
+		}
+		count, reached := rule.counts.Add(ip, now)
+		if !reached {
+			continue
+		}
+		t.Pipeline.Submit(ctx, Offender{
+			IP:       ip,
+			Duration: rule.duration(fields["duration"]),
+			Reason: rule.render(SyslogReasonData{
+				Rule: rule.Name, IP: ip, Count: count, Window: rule.window,
+				Hostname: msg.Hostname, AppName: msg.AppName, Message: msg.Message, Fields: fields,
+			}),
+			Source: syslogSource,
+			Tags:   rule.Tags,
+		})
+		submitted++
+	}
+	return submitted
+}
+
+// 所有条件都满足时返回 pattern 命名分组的值
+func (r *compiledSyslogRule) match(msg SyslogMessage) (map[string]string, bool) {
+	if r.minSeverity >= 0 && (msg.Severity < 0 || msg.Severity > r.minSeverity) {
+		return nil, false
+	}
+	if r.hostname != nil && !r.hostname.MatchString(msg.Hostname) {
+		return nil, false
+	}
+	if r.appName != nil && !r.appName.MatchString(msg.AppName) {
+		return nil, false
+	}
+	m := r.pattern.FindStringSubmatch(msg.Message)
+	if m == nil {
+		return nil, false
+	}
+	fields := make(map[string]string, len(m))
+	for i, name := range r.pattern.SubexpNames() {
+		if name != "" && m[i] != "" {
+			fields[name] = m[i]
+		}
+	}
+	return fields, true
+}
+
+// duration 分组支持 Go 时长格式和秒数，无法解析时使用规则的 duration
+func (r *compiledSyslogRule) duration(v string) string {
+	if v == "" {
+		return r.Duration
+	}
+	if secs, err := strconv.ParseUint(v, 10, 32); err == nil && secs > 0 {
+		return (time.Duration(secs) * time.Second).String()
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d.String()
+	}
+	return r.Duration
+}
+
+func (r *compiledSyslogRule) render(data SyslogReasonData) string {
+	var buf bytes.Buffer
+	if err := r.reason.Execute(&buf, data); err != nil {
+		return fmt.Sprintf("【Syslog触发】%s: %s", data.Rule, data.Message)
+	}
+	return buf.String()
+}
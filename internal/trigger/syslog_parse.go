@@ -0,0 +1,205 @@
+package trigger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyslogMessage 解析后的 syslog 消息，RFC 5424 和 RFC 3164 共用；无法识别的部分整体作为 Message
+type SyslogMessage struct {
+	Facility       int // 没有 PRI 时为 -1
+	Severity       int // 没有 PRI 时为 -1
+	Timestamp      string
+	Hostname       string
+	AppName        string // RFC 3164 为 TAG
+	ProcID         string
+	MsgID          string
+	StructuredData string // 原始的 STRUCTURED-DATA，仅 RFC 5424
+	Message        string
+}
+
+// ParseSyslog 解析一条 syslog 消息，尽量宽松以兼容各类设备的非标准输出
+func ParseSyslog(raw string) SyslogMessage {
+	raw = strings.TrimRight(raw, "\r\n\x00")
+	msg := SyslogMessage{Facility: -1, Severity: -1, Message: raw}
+
+	end := strings.IndexByte(raw, '>')
+	if !strings.HasPrefix(raw, "<") || end < 2 || end > 4 {
+		return msg
+	}
+	pri, err := strconv.Atoi(raw[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return msg
+	}
+	msg.Facility, msg.Severity = pri/8, pri%8
+
+	rest := raw[end+1:]
+	if strings.HasPrefix(rest, "1 ") {
+		parse5424(rest[2:], &msg)
+	} else {
+		parse3164(rest, &msg)
+	}
+	return msg
+}
+
+// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]，"-" 表示空值
+func parse5424(rest string, msg *SyslogMessage) {
+	parts := strings.SplitN(rest, " ", 6)
+	if len(parts) < 6 {
+		msg.Message = rest
+		return
+	}
+	nilValue := func(s string) string {
+		if s == "-" {
+			return ""
+		}
+		return s
+	}
+	msg.Timestamp = nilValue(parts[0])
+	msg.Hostname = nilValue(parts[1])
+	msg.AppName = nilValue(parts[2])
+	msg.ProcID = nilValue(parts[3])
+	msg.MsgID = nilValue(parts[4])
+
+	sd, text := splitStructuredData(parts[5])
+	msg.StructuredData = nilValue(sd)
+	msg.Message = strings.TrimPrefix(text, "\ufeff")
+}
+
+// 按 [id param="value"]... 切分出 STRUCTURED-DATA，参数值中的 \] 和 \" 为转义
+func splitStructuredData(s string) (string, string) {
+	if strings.HasPrefix(s, "-") {
+		return "-", strings.TrimPrefix(s[1:], " ")
+	}
+	if !strings.HasPrefix(s, "[") {
+		return "", s
+	}
+	inQuote, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			inQuote = !inQuote
+		case c == ']' && !inQuote:
+			if i+1 == len(s) || s[i+1] != '[' {
+				return s[:i+1], strings.TrimPrefix(s[i+1:], " ")
+			}
+		}
+	}
+	return s, ""
+}
+
+// Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG，时间戳也接受 RFC 3339；缺少的部分跳过
+func parse3164(rest string, msg *SyslogMessage) {
+	hasTimestamp := false
+	if len(rest) > len(time.Stamp) && rest[len(time.Stamp)] == ' ' {
+		if _, err := time.Parse(time.Stamp, rest[:len(time.Stamp)]); err == nil {
+			msg.Timestamp, rest, hasTimestamp = rest[:len(time.Stamp)], rest[len(time.Stamp)+1:], true
+		}
+	}
+	if !hasTimestamp {
+		if token, after, ok := strings.Cut(rest, " "); ok {
+			if _, err := time.Parse(time.RFC3339Nano, token); err == nil {
+				msg.Timestamp, rest, hasTimestamp = token, after, true
+			}
+		}
+	}
+	// 有时间戳时下一个字段是主机名，除非它已经是 TAG
+	if hasTimestamp {
+		if token, after, ok := strings.Cut(rest, " "); ok && !isSyslogTag(token) {
+			msg.Hostname, rest = token, after
+		}
+	}
+	if token, after, ok := strings.Cut(rest, " "); ok && isSyslogTag(token) {
+		tag := strings.TrimSuffix(token, ":")
+		if i := strings.IndexByte(tag, '['); i > 0 && strings.HasSuffix(tag, "]") {
+			msg.ProcID = tag[i+1 : len(tag)-1]
+			tag = tag[:i]
+		}
+		msg.AppName, rest = tag, after
+	}
+	msg.Message = rest
+}
+
+// TAG 形如 sshd: 或 sshd[123]:
+func isSyslogTag(token string) bool {
+	if len(token) < 2 || !strings.HasSuffix(token, ":") {
+		return false
+	}
+	tag := strings.TrimSuffix(token, ":")
+	if i := strings.IndexByte(tag, '['); i >= 0 {
+		if i == 0 || !strings.HasSuffix(tag, "]") {
+			return false
+		}
+		if _, err := strconv.Atoi(tag[i+1 : len(tag)-1]); err != nil {
+			return false
+		}
+		tag = tag[:i]
+	}
+	return !strings.ContainsAny(tag, ":[]")
+}
+
+var errSyslogFrame = errors.New("invalid syslog frame")
+
+// readSyslogFrame 读取 TCP / TLS 上的一条消息（RFC 6587）：以数字开头时按 "长度 消息" 读取，
+// 否则读到换行为止；超过 maxLogLineSize 的消息丢弃，返回空
+func readSyslogFrame(r *bufio.Reader) ([]byte, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] >= '1' && b[0] <= '9' {
+		n := 0
+		for i := 0; ; i++ {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c == ' ' {
+				break
+			}
+			if c < '0' || c > '9' || i >= 9 {
+				return nil, fmt.Errorf("%w: bad octet count", errSyslogFrame)
+			}
+			n = n*10 + int(c-'0')
+		}
+		if n > maxLogLineSize {
+			_, err := io.CopyN(io.Discard, r, int64(n))
+			return nil, err
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	var frame []byte
+	dropped := false // 超长，丢弃到换行为止
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !dropped {
+			if len(frame)+len(chunk) <= maxLogLineSize {
+				frame = append(frame, chunk...)
+			} else {
+				frame, dropped = nil, true
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && (len(frame) == 0 || !errors.Is(err, io.EOF)) {
+			return nil, err
+		}
+		return frame, nil
+	}
+}
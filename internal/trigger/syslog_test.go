@@ -0,0 +1,266 @@
+package trigger
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want SyslogMessage
+	}{
+		{
+			`<165>1 2026-10-19T22:14:15.003Z fw01 asa 1234 ID47 [exampleSDID@32473 iut="3" eventSource="App\]lication"] ` + "\ufeff" + `Deny tcp src outside:192.0.2.1/4444`,
+			SyslogMessage{Facility: 20, Severity: 5, Timestamp: "2026-10-19T22:14:15.003Z", Hostname: "fw01", AppName: "asa", ProcID: "1234", MsgID: "ID47",
+				StructuredData: `[exampleSDID@32473 iut="3" eventSource="App\]lication"]`, Message: "Deny tcp src outside:192.0.2.1/4444"},
+		},
+		{
+			"<13>1 - - - - - -\n",
+			SyslogMessage{Facility: 1, Severity: 5},
+		},
+		{
+			"<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8",
+			SyslogMessage{Facility: 4, Severity: 2, Timestamp: "Oct 11 22:14:15", Hostname: "mymachine", AppName: "su", ProcID: "230", Message: "'su root' failed for lonvick on /dev/pts/8"},
+		},
+		{
+			"<86>Oct  9 08:01:02 sshd: Failed password for root from 198.51.100.3 port 22",
+			SyslogMessage{Facility: 10, Severity: 6, Timestamp: "Oct  9 08:01:02", AppName: "sshd", Message: "Failed password for root from 198.51.100.3 port 22"},
+		},
+		{
+			"<12>2026-10-19T08:00:00+08:00 waf01 blocked 203.0.113.9",
+			SyslogMessage{Facility: 1, Severity: 4, Timestamp: "2026-10-19T08:00:00+08:00", Hostname: "waf01", Message: "blocked 203.0.113.9"},
+		},
+		{
+			"blocked 203.0.113.9",
+			SyslogMessage{Facility: -1, Severity: -1, Message: "blocked 203.0.113.9"},
+		},
+	}
+	for i, c := range cases {
+		if got := ParseSyslog(c.raw); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("case %d:\n got %+v\nwant %+v", i, got, c.want)
+		}
+	}
+}
+
+func TestReadSyslogFrame(t *testing.T) {
+	long := strings.Repeat("x", maxLogLineSize+1)
+	input := "11 <13>1 hello<13>line one\n" + long + "\n<13>line two\n99999 " + strings.Repeat("y", 99999) + "<13>tail"
+	r := bufio.NewReaderSize(strings.NewReader(input), 4096)
+	var frames []string
+	for {
+		frame, err := readSyslogFrame(r)
+		if err != nil {
+			break
+		}
+		frames = append(frames, string(frame))
+	}
+	want := []string{"<13>1 hello", "<13>line one\n", "", "<13>line two\n", "", "<13>tail"}
+	if !reflect.DeepEqual(frames, want) {
+		t.Fatalf("got %q, want %q", frames, want)
+	}
+	if _, err := readSyslogFrame(bufio.NewReader(strings.NewReader("12x <13>bad"))); err == nil {
+		t.Fatal("expected bad octet count to fail")
+	}
+}
+
+func TestSyslogTriggerRules(t *testing.T) {
+	p := newTestPipeline(t)
+	trig, err := NewSyslogTrigger(SyslogConfig{
+		UDP: "127.0.0.1:0",
+		Rules: []SyslogRule{
+			{
+				Name:     "asa-deny",
+				AppName:  "^%ASA",
+				Pattern:  `Deny \w+ src \w+:(?P<ip>[0-9.]+)/\d+`,
+				Duration: "1h",
+				Tags:     []string{"firewall"},
+			},
+			{
+				Name:        "waf",
+				Hostname:    "^waf",
+				MinSeverity: "warning",
+				Pattern:     `client=(?P<ip>\S+) ban=(?P<duration>\S+)`,
+				Threshold:   2,
+				Window:      "1m",
+				Reason:      "【WAF】{{.Hostname}} 在 {{.Window}} 内拦截 {{.IP}} {{.Count}} 次",
+			},
+		},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	asa := "<164>Oct 19 10:00:00 fw01 %ASA-4-106023: Deny tcp src outside:192.0.2.40/51234 dst inside:10.0.0.5/22 by access-group \"outside\""
+	if trig.HandleMessage(ctx, []byte(asa)) != 1 {
+		t.Fatal("expected ASA deny to ban")
+	}
+	ipblock, ok := getIPBlock(t, p, "192.0.2.40")
+	if !ok || ipblock.Spec.Source != "syslog" || ipblock.Spec.Duration != "1h" || !reflect.DeepEqual(ipblock.Spec.Tags, []string{"firewall"}) {
+		t.Fatalf("unexpected IPBlock %+v", ipblock)
+	}
+	if !strings.HasPrefix(ipblock.Spec.Reason, "【Syslog触发】asa-deny: Deny tcp src outside:192.0.2.40/51234") {
+		t.Fatalf("unexpected reason %q", ipblock.Spec.Reason)
+	}
+
+	waf := "<12>1 2026-10-19T10:00:00Z waf01 modsec - - - client=198.51.100.8 ban=3600"
+	if trig.HandleMessage(ctx, []byte(waf)) != 0 {
+		t.Fatal("threshold not reached yet")
+	}
+	if trig.HandleMessage(ctx, []byte("<14>1 2026-10-19T10:00:01Z waf01 modsec - - - client=198.51.100.8 ban=3600")) != 0 {
+		t.Fatal("info severity must not match minSeverity warning")
+	}
+	if trig.HandleMessage(ctx, []byte(waf)) != 1 {
+		t.Fatal("expected ban after reaching threshold")
+	}
+	ipblock, ok = getIPBlock(t, p, "198.51.100.8")
+	if !ok || ipblock.Spec.Duration != "1h0m0s" || ipblock.Spec.Reason != "【WAF】waf01 在 1m0s 内拦截 198.51.100.8 2 次" {
+		t.Fatalf("unexpected IPBlock %+v", ipblock)
+	}
+}
+
+func TestSyslogTriggerTransports(t *testing.T) {
+	p := newTestPipeline(t)
+	trig, err := NewSyslogTrigger(SyslogConfig{
+		UDP:   "127.0.0.1:0",
+		TCP:   "127.0.0.1:0",
+		Rules: []SyslogRule{{Name: "blocked", Pattern: `blocked (?P<ip>\S+)`}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// TCP：一个连接上混合使用两种分帧方式
+	server, client := net.Pipe()
+	go trig.serve(ctx, server)
+	octet := "<12>1 - - - - - - blocked 203.0.113.2"
+	go func() {
+		_, _ = client.Write([]byte("<12>waf: blocked 203.0.113.1\n" + strconv.Itoa(len(octet)) + " " + octet))
+		_ = client.Close()
+	}()
+
+	// UDP：每个数据报一条消息
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go trig.readDatagrams(ctx, conn)
+	sender, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if _, err := sender.Write([]byte("<12>Oct 19 10:00:00 fw blocked 203.0.113.3")); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		for {
+			if _, ok := getIPBlock(t, p, ip); ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected IPBlock for %s", ip)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Start / Stop 同步打开和关闭监听
+	if err := trig.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(trig.closers) != 2 {
+		t.Fatalf("expected udp and tcp listeners, got %d", len(trig.closers))
+	}
+	if err := trig.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if trig.closers != nil {
+		t.Fatal("expected listeners to be closed on Stop")
+	}
+}
+
+func TestSyslogAllowedSources(t *testing.T) {
+	p := newTestPipeline(t)
+	trig, err := NewSyslogTrigger(SyslogConfig{
+		UDP:            "127.0.0.1:0",
+		TCP:            "127.0.0.1:0",
+		AllowedSources: []string{"192.0.2.0/24", "2001:db8::1"},
+		Rules:          []SyslogRule{{Name: "blocked", Pattern: `blocked (?P<ip>\S+)`}},
+	}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"192.0.2.10:514":          true,
+		"[::ffff:192.0.2.10]:514": true,
+		"[2001:db8::1]:514":       true,
+		"198.51.100.1:514":        false,
+		"[2001:db8::2]:514":       false,
+		"127.0.0.1:514":           false,
+	}
+	for addr, want := range cases {
+		if got := trig.allowedSource(net.UDPAddrFromAddrPort(netip.MustParseAddrPort(addr))); got != want {
+			t.Errorf("allowedSource(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := trig.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer trig.Stop(ctx)
+
+	// 不在允许列表中的数据报和连接被丢弃
+	for _, c := range trig.closers {
+		var network, addr string
+		switch l := c.(type) {
+		case net.PacketConn:
+			network, addr = "udp", l.LocalAddr().String()
+		case net.Listener:
+			network, addr = "tcp", l.Addr().String()
+		}
+		conn, err := net.Dial(network, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = conn.Write([]byte("<12>fw: blocked 203.0.113.9\n"))
+		_ = conn.Close()
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := getIPBlock(t, p, "203.0.113.9"); ok {
+		t.Fatal("messages from disallowed sources should be dropped")
+	}
+}
+
+func TestSyslogConfigValidation(t *testing.T) {
+	rule := SyslogRule{Name: "a", Pattern: `(?P<ip>\S+)`}
+	cases := []SyslogConfig{
+		{Rules: []SyslogRule{rule}},
+		{UDP: ":5514"},
+		{UDP: ":5514", Rules: []SyslogRule{{Name: "a", Pattern: `\S+`}}},
+		{UDP: ":5514", Rules: []SyslogRule{{Name: "a", Pattern: `(?P<ip>\S+)`, MinSeverity: "loud"}}},
+		{UDP: ":5514", Rules: []SyslogRule{{Name: "a", Pattern: `(?P<ip>\S+)`, Reason: "{{.Bad"}}},
+		{TLS: &SyslogTLSConfig{Addr: ":6514", Cert: []byte("bad"), Key: []byte("bad")}, Rules: []SyslogRule{rule}},
+		{UDP: ":5514", AllowedSources: []string{"10.0.0.0/33"}, Rules: []SyslogRule{rule}},
+	}
+	for i, c := range cases {
+		if _, err := NewSyslogTrigger(c, nil); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
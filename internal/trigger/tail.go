@@ -0,0 +1,186 @@
+package trigger
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// tailOptions 文件跟踪参数，Name 为日志前缀使用的触发器名称
+type tailOptions struct {
+	Name          string
+	FromBeginning bool
+	Interval      time.Duration
+}
+
+// 跟踪文件：读到末尾后轮询，文件被轮转（inode 变化）或截断时重新打开并从头读取
+func tailFile(ctx context.Context, path string, opts tailOptions, handle func(line string)) {
+	logger := logf.FromContext(ctx).WithValues("file", path)
+
+	var (
+		f       *os.File
+		reader  *bufio.Reader
+		offset  int64
+		partial []byte
+		tooLong bool // 当前行超过 maxLogLineSize，读到行尾后整行跳过
+	)
+	// 首次打开时默认从末尾开始，轮转后的新文件从头读取
+	seekEnd := !opts.FromBeginning
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+
+	for {
+		if f == nil {
+			var err error
+			if f, offset, err = openLog(path, seekEnd); err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					logger.Error(err, "["+opts.Name+"] Open log file failed")
+				}
+				if !sleepCtx(ctx, opts.Interval) {
+					return
+				}
+				continue
+			}
+			reader = bufio.NewReader(f)
+			partial, tooLong = partial[:0], false
+			seekEnd = false
+		}
+
+		chunk, err := reader.ReadSlice('\n')
+		offset += int64(len(chunk))
+		if !tooLong && len(partial)+len(chunk) <= maxLogLineSize {
+			partial = append(partial, chunk...)
+		} else {
+			tooLong = true
+		}
+		if err == nil {
+			if !tooLong {
+				handle(strings.TrimRight(string(partial), "\r\n"))
+			}
+			partial, tooLong = partial[:0], false
+			continue
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			logger.Error(err, "["+opts.Name+"] Read log file failed")
+		}
+
+		if rotated(f, path, offset) {
+			logger.Info("[" + opts.Name + "] Log file rotated, reopening")
+			_ = f.Close()
+			f = nil
+			continue
+		}
+		if !sleepCtx(ctx, opts.Interval) {
+			return
+		}
+	}
+}
+
+// readLines 按行读取直到 EOF，超过 maxLogLineSize 的行整行跳过，不中断后续读取
+func readLines(r io.Reader, handle func(line string)) error {
+	reader := bufio.NewReader(r)
+	var (
+		line    []byte
+		tooLong bool
+	)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong && len(line)+len(chunk) <= maxLogLineSize {
+			line = append(line, chunk...)
+		} else {
+			tooLong = true
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if !tooLong && len(line) > 0 {
+			handle(strings.TrimRight(string(line), "\r\n"))
+		}
+		line, tooLong = line[:0], false
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// acceptLines 接受 socket 连接并按行读取，每个连接一个协程，Stop 或 ctx 结束时关闭；
+// Name 为日志前缀使用的触发器名称
+func acceptLines(ctx context.Context, ln net.Listener, name string, handle func(line string)) {
+	logger := logf.FromContext(ctx)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error(err, "["+name+"] Accept failed")
+			}
+			return
+		}
+		go func() {
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				select {
+				case <-ctx.Done():
+				case <-done:
+				}
+				_ = conn.Close()
+			}()
+			if err := readLines(conn, handle); err != nil && ctx.Err() == nil {
+				logger.Error(err, "["+name+"] Read socket failed")
+			}
+		}()
+	}
+}
+
+func openLog(path string, seekEnd bool) (*os.File, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	var offset int64
+	if seekEnd {
+		if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+			_ = f.Close()
+			return nil, 0, err
+		}
+	}
+	return f, offset, nil
+}
+
+// 路径指向了新文件，或文件比已读取的部分短
+func rotated(f *os.File, path string, offset int64) bool {
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	opened, err := f.Stat()
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(current, opened) || current.Size() < offset
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
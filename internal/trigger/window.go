@@ -0,0 +1,63 @@
+package trigger
+
+import (
+	"sync"
+	"time"
+)
+
+// 单条规则最多跟踪的地址数，超出后新地址不计数，防止扫描流量撑爆内存
+const maxWindowKeys = 100000
+
+// slidingWindow 按地址统计滑动窗口内的事件数，达到阈值时清空该地址的计数
+type slidingWindow struct {
+	window    time.Duration
+	threshold int
+
+	mu     sync.Mutex
+	events map[string][]time.Time
+	lastGC time.Time
+}
+
+func newSlidingWindow(window time.Duration, threshold int) *slidingWindow {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &slidingWindow{window: window, threshold: threshold, events: make(map[string][]time.Time)}
+}
+
+// Add 记录一次事件，窗口内的事件数达到阈值时返回 true
+func (w *slidingWindow) Add(key string, now time.Time) (int, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cutoff := now.Add(-w.window)
+	if now.Sub(w.lastGC) > w.window {
+		w.gc(cutoff)
+		w.lastGC = now
+	}
+
+	events, ok := w.events[key]
+	if !ok && len(w.events) >= maxWindowKeys {
+		return 0, false
+	}
+	i := 0
+	for i < len(events) && !events[i].After(cutoff) {
+		i++
+	}
+	events = append(events[i:], now)
+	if len(events) >= w.threshold {
+		delete(w.events, key)
+		return len(events), true
+	}
+	w.events[key] = events
+	return len(events), false
+}
+
+// 删除窗口内已没有事件的地址，调用方需持有锁
+func (w *slidingWindow) gc(cutoff time.Time) {
+	for key, events := range w.events {
+		if !events[len(events)-1].After(cutoff) {
+			delete(w.events, key)
+		}
+	}
+}
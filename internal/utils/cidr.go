@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"net"
+	"strings"
+)
+
+// NormalizeCIDR 将单个 IP 转换为 /32（IPv4）或 /128（IPv6）形式的 CIDR，
+// 本身是 CIDR 的返回其网络地址形式，无法解析时原样返回
+func NormalizeCIDR(ip string) string {
+	ip = strings.TrimSpace(ip)
+	if _, ipNet, err := net.ParseCIDR(ip); err == nil {
+		return ipNet.String()
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if parsed.To4() != nil {
+		return parsed.String() + "/32"
+	}
+	return parsed.String() + "/128"
+}